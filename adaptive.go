@@ -0,0 +1,323 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// adaptivePending is the number of hashes an AdaptiveFilter collects
+// before merging them into its sorted set, which bounds both the linear
+// scan of Test and the number of merges.
+const adaptivePending = 64
+
+// AdaptiveFilter is a set that starts out exact and becomes a Bloom filter
+// once that is smaller, like the sparse mode of HyperLogLog.
+//
+// While it holds few items it stores a 64-bit hash of each in a sorted
+// slice, so Test only reports false positives on a collision of those
+// hashes, and memory grows with the number of items rather than being the
+// full bit array sized for n. Once the hashes would take more memory than
+// the bit array, they are added to a Bloom filter of the size New(n, p)
+// would allocate, which the filter uses from then on.
+//
+// It is safe for concurrent use by multiple goroutines.
+type AdaptiveFilter struct {
+	mutex sync.RWMutex
+	m, k  uint64
+	count uint64
+
+	// sorted holds the distinct hashes of the exact set in increasing
+	// order, and pending those not merged yet. Both are nil once bloom is
+	// set.
+	sorted  []uint64
+	pending []uint64
+	bloom   *BloomFilter
+}
+
+// NewAdaptive creates an AdaptiveFilter for `n` items with a false
+// positive probability of `p` once it becomes a Bloom filter.
+//
+// It returns an error if the probability is not in the range (0,1).
+func NewAdaptive(n uint64, p float64) (*AdaptiveFilter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+
+	m := max(OptimalM(n, p), 1)
+	return &AdaptiveFilter{m: m, k: max(OptimalK(m, max(n, 1)), 1)}, nil
+}
+
+// adaptiveHash returns the hash of item kept by the exact set: the first
+// word of its murmur3-128 digest.
+func adaptiveHash(item []byte) uint64 {
+	return hasher.SchemeMurmur.Sum(item)[0]
+}
+
+// adaptiveDigest returns the digest from which the Bloom filter of an
+// AdaptiveFilter derives an item's locations. It depends only on the hash
+// kept by the exact set, so the set can be converted without the items;
+// the second word is the hash remixed by the splitmix64 finalizer.
+func adaptiveDigest(h uint64) hasher.Digest {
+	z := h
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return hasher.Digest{h, z ^ z>>31}
+}
+
+// Add inserts an item into the filter.
+func (af *AdaptiveFilter) Add(item []byte) {
+	h := adaptiveHash(item)
+
+	af.mutex.Lock()
+	defer af.mutex.Unlock()
+
+	af.count++
+	if af.bloom != nil {
+		af.bloom.addDigest(adaptiveDigest(h))
+		return
+	}
+	if af.contains(h) {
+		return
+	}
+
+	af.pending = append(af.pending, h)
+	if len(af.pending) == adaptivePending {
+		af.merge()
+	}
+	if uint64(len(af.sorted)+len(af.pending)) > (af.m+63)/64 {
+		af.convert()
+	}
+}
+
+// Test checks whether an item is possibly in the filter. While the filter
+// is exact it only returns true for items that were added, barring a
+// collision of their 64-bit hashes.
+func (af *AdaptiveFilter) Test(item []byte) bool {
+	h := adaptiveHash(item)
+
+	af.mutex.RLock()
+	defer af.mutex.RUnlock()
+
+	if af.bloom != nil {
+		return af.bloom.testDigest(adaptiveDigest(h))
+	}
+	return af.contains(h)
+}
+
+func (af *AdaptiveFilter) contains(h uint64) bool {
+	if _, ok := slices.BinarySearch(af.sorted, h); ok {
+		return true
+	}
+	return slices.Contains(af.pending, h)
+}
+
+// merge moves the pending hashes into the sorted set.
+func (af *AdaptiveFilter) merge() {
+	slices.Sort(af.pending)
+	merged := make([]uint64, 0, len(af.sorted)+len(af.pending))
+	i, j := 0, 0
+	for i < len(af.sorted) && j < len(af.pending) {
+		if af.sorted[i] < af.pending[j] {
+			merged = append(merged, af.sorted[i])
+			i++
+		} else {
+			merged = append(merged, af.pending[j])
+			j++
+		}
+	}
+	merged = append(merged, af.sorted[i:]...)
+	af.sorted = append(merged, af.pending[j:]...)
+	af.pending = af.pending[:0]
+}
+
+// convert adds the exact set to a new Bloom filter and switches to it.
+func (af *AdaptiveFilter) convert() {
+	bf := NewWithParams(af.m, af.k)
+	for _, hashes := range [][]uint64{af.sorted, af.pending} {
+		for _, h := range hashes {
+			bf.addDigest(adaptiveDigest(h))
+		}
+	}
+	bf.count = af.count
+	af.bloom, af.sorted, af.pending = bf, nil, nil
+}
+
+// Exact reports whether the filter still holds the exact set of hashes
+// rather than a Bloom filter.
+func (af *AdaptiveFilter) Exact() bool {
+	af.mutex.RLock()
+	defer af.mutex.RUnlock()
+
+	return af.bloom == nil
+}
+
+// Count returns the number of Add calls, including duplicates.
+func (af *AdaptiveFilter) Count() uint64 {
+	af.mutex.RLock()
+	defer af.mutex.RUnlock()
+
+	return af.count
+}
+
+// M returns the size of the bit array of the Bloom filter the filter
+// becomes, or has become.
+func (af *AdaptiveFilter) M() uint64 {
+	return af.m
+}
+
+// K returns the number of hash functions of the Bloom filter the filter
+// becomes, or has become.
+func (af *AdaptiveFilter) K() uint64 {
+	return af.k
+}
+
+// MemoryUsage returns the memory held by the exact set or the bit array
+// in bytes.
+func (af *AdaptiveFilter) MemoryUsage() int {
+	af.mutex.RLock()
+	defer af.mutex.RUnlock()
+
+	if af.bloom != nil {
+		return af.bloom.MemoryUsage()
+	}
+	return (cap(af.sorted) + cap(af.pending)) * 8
+}
+
+const (
+	adaptiveExact byte = 0
+	adaptiveBloom byte = 1
+)
+
+// MarshalBinary serializes the filter, recording its representation in
+// the first byte:
+//
+//	Offset  Size (bytes)  Description
+//	------  ------------- ----------------------------------------------
+//	0       1             representation: 0 for the exact set, 1 for the
+//	                      Bloom filter
+//
+// The exact set follows as the 24-byte header of the BloomFilter format,
+// giving the parameters of the Bloom filter it becomes and the count, then
+// the number of hashes as a uvarint and the hashes in increasing order,
+// each as the uvarint gap from the previous one. The Bloom filter follows
+// in the BloomFilter format.
+//
+// Both headers record the adaptive hashing scheme, which UnmarshalBinary
+// rejects: the Bloom filter's bits are placed from the hash kept by the
+// exact set, so read as a plain filter it would report false negatives.
+func (af *AdaptiveFilter) MarshalBinary() ([]byte, error) {
+	af.mutex.Lock()
+	defer af.mutex.Unlock()
+
+	if af.bloom != nil {
+		buf, err := af.bloom.AppendBinary([]byte{adaptiveBloom})
+		if err != nil {
+			return nil, err
+		}
+		setScheme(buf[1:], hasher.SchemeAdaptive)
+		return buf, nil
+	}
+
+	af.merge()
+	buf := make([]byte, 1+headerSize, 1+headerSize+binary.MaxVarintLen64*(1+len(af.sorted)))
+	buf[0] = adaptiveExact
+	putHeader(buf[1:], header{m: af.m, k: af.k, count: af.count, scheme: hasher.SchemeAdaptive, probe: defaultProbe})
+	buf = binary.AppendUvarint(buf, uint64(len(af.sorted)))
+	prev := uint64(0)
+	for _, h := range af.sorted {
+		buf = binary.AppendUvarint(buf, h-prev)
+		prev = h
+	}
+	return buf, nil
+}
+
+// UnmarshalAdaptive decodes a filter encoded by
+// (*AdaptiveFilter).MarshalBinary, in whichever representation it was
+// written. Errors wrap the sentinel errors of UnmarshalOptions.
+func UnmarshalAdaptive(data []byte) (*AdaptiveFilter, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: no representation", ErrTruncated)
+	}
+
+	switch data[0] {
+	case adaptiveBloom:
+		data = append([]byte(nil), data[1:]...)
+		if len(data) >= headerSize {
+			if _, scheme, _ := unpackK(binary.LittleEndian.Uint64(data[8:16])); scheme != hasher.SchemeAdaptive {
+				return nil, fmt.Errorf("%w: scheme %s", ErrUnknownScheme, scheme)
+			}
+			setScheme(data, hasher.SchemeMurmur)
+		}
+		bf, err := UnmarshalBinary(data)
+		if err != nil {
+			return nil, err
+		}
+		return &AdaptiveFilter{m: bf.m, k: bf.k, count: bf.count, bloom: bf}, nil
+	case adaptiveExact:
+		return unmarshalExact(data[1:])
+	}
+	return nil, fmt.Errorf("%w: representation %d", ErrUnknownScheme, data[0])
+}
+
+// setScheme replaces the hashing scheme recorded in the header at the start
+// of data.
+func setScheme(data []byte, scheme hasher.Scheme) {
+	k, _, p := unpackK(binary.LittleEndian.Uint64(data[8:16]))
+	binary.LittleEndian.PutUint64(data[8:16], packK(k, scheme, p))
+}
+
+func unmarshalExact(data []byte) (*AdaptiveFilter, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTruncated, len(data))
+	}
+	var h header
+	h.m = binary.LittleEndian.Uint64(data[0:8])
+	h.k, h.scheme, h.probe = unpackK(binary.LittleEndian.Uint64(data[8:16]))
+	h.count = binary.LittleEndian.Uint64(data[16:24])
+	if h.m == 0 || h.k == 0 {
+		return nil, fmt.Errorf("%w: m = %d, k = %d", ErrInvalidParams, h.m, h.k)
+	}
+	if h.k > DefaultMaxK {
+		return nil, &LimitError{Field: "k", Value: h.k, Limit: DefaultMaxK}
+	}
+	if h.scheme != hasher.SchemeAdaptive || h.probe != defaultProbe {
+		return nil, fmt.Errorf("%w: scheme %s, probing %#x", ErrUnknownScheme, h.scheme, uint8(h.probe))
+	}
+
+	data = data[headerSize:]
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return nil, fmt.Errorf("%w: hash count", ErrTruncated)
+	}
+	data = data[size:]
+	// Each hash takes at least one byte, which bounds the allocation.
+	if n > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: %d hashes in %d bytes", ErrLengthMismatch, n, len(data))
+	}
+
+	af := &AdaptiveFilter{m: h.m, k: h.k, count: h.count, sorted: make([]uint64, n)}
+	prev := uint64(0)
+	for i := range af.sorted {
+		gap, size := binary.Uvarint(data)
+		if size <= 0 {
+			return nil, fmt.Errorf("%w: hash %d", ErrTruncated, i)
+		}
+		if (gap == 0 && i > 0) || prev+gap < prev {
+			return nil, fmt.Errorf("%w: hashes not in increasing order at %d", ErrInvalidParams, i)
+		}
+		prev += gap
+		af.sorted[i] = prev
+		data = data[size:]
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrLengthMismatch, len(data))
+	}
+	if uint64(len(af.sorted)) > (af.m+63)/64 {
+		af.convert()
+	}
+	return af, nil
+}
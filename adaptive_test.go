@@ -0,0 +1,213 @@
+package bitbloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestAdaptiveFilter_Exact(t *testing.T) {
+	af, err := NewAdaptive(10000, 0.01)
+	if err != nil {
+		t.Fatalf("NewAdaptive failed: %v", err)
+	}
+	if _, err := NewAdaptive(10000, 1); err == nil {
+		t.Error("Expected error for p = 1")
+	}
+
+	for i := range 100 {
+		af.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	af.Add([]byte("item-0"))
+	if !af.Exact() {
+		t.Fatal("Expected 100 items to stay exact")
+	}
+	if af.Count() != 101 {
+		t.Errorf("Expected count 101, got %d", af.Count())
+	}
+	for i := range 100 {
+		if !af.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d", i)
+		}
+	}
+	for i := range 100000 {
+		if af.Test(fmt.Appendf(nil, "other-%d", i)) {
+			t.Fatalf("False positive for other-%d in exact mode", i)
+		}
+	}
+
+	bloomBytes := int((af.M() + 63) / 64 * 8)
+	if af.MemoryUsage() >= bloomBytes {
+		t.Errorf("Expected the exact set to use less than %d bytes, got %d", bloomBytes, af.MemoryUsage())
+	}
+}
+
+func TestAdaptiveFilter_Convert(t *testing.T) {
+	af, _ := NewAdaptive(10000, 0.01)
+	limit := int((af.M() + 63) / 64)
+
+	for i := range limit {
+		af.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	if !af.Exact() {
+		t.Fatalf("Expected %d hashes to stay exact", limit)
+	}
+	af.Add([]byte("one more"))
+	if af.Exact() {
+		t.Fatal("Expected the filter to become a Bloom filter")
+	}
+	if af.MemoryUsage() != limit*8 || af.Count() != uint64(limit)+1 {
+		t.Errorf("Unexpected memory %d or count %d", af.MemoryUsage(), af.Count())
+	}
+
+	for i := range 10000 {
+		af.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	for i := range 10000 {
+		if !af.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d", i)
+		}
+	}
+	if !af.Test([]byte("one more")) {
+		t.Error("Expected items added before the switch to remain")
+	}
+
+	falsePositives := 0
+	for i := range 100000 {
+		if af.Test(fmt.Appendf(nil, "other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 100000; rate > 0.015 {
+		t.Errorf("False positive rate %f exceeds 1.5%%", rate)
+	}
+}
+
+func TestAdaptiveFilter_Serialization(t *testing.T) {
+	af, _ := NewAdaptive(10000, 0.01)
+	for i := range 100 {
+		af.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+
+	for _, exact := range []bool{true, false} {
+		if !exact {
+			for i := range 5000 {
+				af.Add(fmt.Appendf(nil, "more-%d", i))
+			}
+		}
+		data, err := af.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		decoded, err := UnmarshalAdaptive(data)
+		if err != nil {
+			t.Fatalf("UnmarshalAdaptive failed: %v", err)
+		}
+		if decoded.Exact() != exact || decoded.Count() != af.Count() ||
+			decoded.M() != af.M() || decoded.K() != af.K() {
+			t.Errorf("Expected exact=%v count=%d, got exact=%v count=%d",
+				exact, af.Count(), decoded.Exact(), decoded.Count())
+		}
+		for i := range 100 {
+			if !decoded.Test(fmt.Appendf(nil, "item-%d", i)) {
+				t.Fatalf("exact=%v: false negative for item-%d after decoding", exact, i)
+			}
+		}
+		if exact && decoded.Test([]byte("other")) {
+			t.Error("Expected the decoded exact set to stay exact")
+		}
+
+		for n := range min(len(data), 40) {
+			if _, err := UnmarshalAdaptive(data[:n]); err == nil {
+				t.Errorf("exact=%v: expected error decoding %d of %d bytes", exact, n, len(data))
+			}
+		}
+	}
+
+	if _, err := UnmarshalAdaptive([]byte{7}); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme for an unknown representation, got %v", err)
+	}
+}
+
+func TestAdaptiveFilter_NotAPlainFilter(t *testing.T) {
+	af, _ := NewAdaptive(1000, 0.01)
+	for i := range 1000 {
+		af.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	if af.Exact() {
+		t.Fatal("Expected a Bloom filter")
+	}
+	data, _ := af.MarshalBinary()
+
+	// The embedded filter places bits from the adaptive hash, so it must
+	// not decode as a murmur3 filter that would miss the items.
+	if _, err := UnmarshalBinary(data[1:]); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme decoding the embedded filter, got %v", err)
+	}
+	decoded, err := UnmarshalAdaptive(data)
+	if err != nil {
+		t.Fatalf("UnmarshalAdaptive failed: %v", err)
+	}
+	for i := range 1000 {
+		if !decoded.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d after decoding", i)
+		}
+	}
+	if again, _ := decoded.MarshalBinary(); !bytes.Equal(again, data) {
+		t.Error("Expected re-encoding to give the same bytes")
+	}
+
+	plain, _ := NewWithParams(af.M(), af.K()).MarshalBinary()
+	if _, err := UnmarshalAdaptive(append([]byte{adaptiveBloom}, plain...)); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme for a plain filter, got %v", err)
+	}
+}
+
+func TestAdaptiveFilter_UnmarshalErrors(t *testing.T) {
+	af, _ := NewAdaptive(1000, 0.01)
+	af.Add([]byte("a"))
+	af.Add([]byte("b"))
+	data, _ := af.MarshalBinary()
+
+	if _, err := UnmarshalAdaptive(append(data, 0)); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("Expected ErrLengthMismatch for trailing bytes, got %v", err)
+	}
+
+	// Repeat the first hash: a zero gap.
+	dup := append([]byte(nil), data[:1+headerSize]...)
+	dup = append(dup, 2, 5, 0)
+	if _, err := UnmarshalAdaptive(dup); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected ErrInvalidParams for a repeated hash, got %v", err)
+	}
+
+	huge := append([]byte(nil), data[:1+headerSize]...)
+	huge = append(huge, 0xff, 0xff, 0xff, 0xff, 0x0f)
+	if _, err := UnmarshalAdaptive(huge); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("Expected ErrLengthMismatch for a huge hash count, got %v", err)
+	}
+}
+
+func TestAdaptiveFilter_Concurrent(t *testing.T) {
+	af, _ := NewAdaptive(2000, 0.01)
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				item := fmt.Appendf(nil, "g%d-%d", g, i)
+				af.Add(item)
+				if !af.Test(item) {
+					t.Errorf("False negative for %s", item)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if af.Exact() || af.Count() != 2000 {
+		t.Errorf("Expected a Bloom filter holding 2000 adds, got exact=%v count=%d", af.Exact(), af.Count())
+	}
+}
@@ -0,0 +1,412 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// BIP37 limits, beyond which nodes reject a filterload message.
+const (
+	BIP37MaxFilterBytes = 36000
+	BIP37MaxHashFuncs   = 50
+)
+
+// bip37SeedMultiplier spaces the murmur3 seeds of the hash functions.
+const bip37SeedMultiplier = 0xfba4c795
+
+// bip37Ln2Squared is Bitcoin Core's LN2SQUARED, spelled out so that filter
+// sizes are computed from the same double.
+const bip37Ln2Squared = 0.4804530139182014246671025263266649717305529515945455
+
+// BIP37Flags controls how a BIP37 filter is updated when a transaction
+// output matches, as the nFlags field of filterload.
+type BIP37Flags uint8
+
+const (
+	// BIP37UpdateNone never updates the filter.
+	BIP37UpdateNone BIP37Flags = 0
+	// BIP37UpdateAll adds the outpoint of every matching output.
+	BIP37UpdateAll BIP37Flags = 1
+	// BIP37UpdateP2PubKeyOnly adds the outpoint of matching outputs only
+	// if they are pay-to-pubkey or bare multisig.
+	BIP37UpdateP2PubKeyOnly BIP37Flags = 2
+
+	bip37UpdateMask BIP37Flags = 3
+)
+
+// BIP37Filter is a Bloom filter compatible with Bitcoin's connection Bloom
+// filtering (BIP37), as sent by SPV clients in filterload messages.
+//
+// Hash function i is murmur3-32 seeded with i*0xFBA4C795 + tweak, reduced
+// modulo the number of bits, and bit n is stored in byte n/8 at position
+// n%8.
+//
+// It is safe for concurrent use by multiple goroutines.
+type BIP37Filter struct {
+	mutex     sync.RWMutex
+	data      []byte
+	hashFuncs uint32
+	tweak     uint32
+	flags     BIP37Flags
+}
+
+// NewBIP37 creates a BIP37 filter sized for `n` elements with a false
+// positive probability of `p`, as Bitcoin Core's CBloomFilter does: the size
+// is capped at 36,000 bytes and the number of hash functions at 50. The
+// tweak randomizes the hash functions and should be chosen at random for
+// privacy.
+//
+// Unlike Bitcoin Core the filter is at least one byte, so that a tiny filter
+// cannot divide by zero.
+func NewBIP37(n uint32, p float64, tweak uint32, flags BIP37Flags) (*BIP37Filter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+	if !validBIP37Flags(flags) {
+		return nil, fmt.Errorf("invalid BIP37 flags %d", flags)
+	}
+	n = max(n, 1)
+
+	// The bits per element are divided as integers before scaling by ln 2,
+	// as in Core, so that the number of hash functions matches.
+	numBits := uint64(-1 / bip37Ln2Squared * float64(n) * math.Log(p))
+	numBytes := max(min(numBits, BIP37MaxFilterBytes*8)/8, 1)
+	hashFuncs := min(uint32(float64(numBytes*8/uint64(n))*math.Ln2), BIP37MaxHashFuncs)
+
+	return &BIP37Filter{
+		data:      make([]byte, numBytes),
+		hashFuncs: max(hashFuncs, 1),
+		tweak:     tweak,
+		flags:     flags,
+	}, nil
+}
+
+func (f *BIP37Filter) location(i uint32, item []byte) uint32 {
+	h := hasher.Sum32(item, i*bip37SeedMultiplier+f.tweak)
+	return h % uint32(len(f.data)*8)
+}
+
+// Add inserts an item into the filter.
+func (f *BIP37Filter) Add(item []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.add(item)
+}
+
+func (f *BIP37Filter) add(item []byte) {
+	for i := range f.hashFuncs {
+		n := f.location(i, item)
+		f.data[n>>3] |= 1 << (n & 7)
+	}
+}
+
+// Test checks whether an item is possibly in the filter.
+func (f *BIP37Filter) Test(item []byte) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.test(item)
+}
+
+func (f *BIP37Filter) test(item []byte) bool {
+	for i := range f.hashFuncs {
+		n := f.location(i, item)
+		if f.data[n>>3]&(1<<(n&7)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// HashFuncs returns the number of hash functions, nHashFuncs.
+func (f *BIP37Filter) HashFuncs() uint32 {
+	return f.hashFuncs
+}
+
+// Tweak returns the seed tweak, nTweak.
+func (f *BIP37Filter) Tweak() uint32 {
+	return f.tweak
+}
+
+// Flags returns the update flags, nFlags.
+func (f *BIP37Filter) Flags() BIP37Flags {
+	return f.flags
+}
+
+// NumBytes returns the size of the filter in bytes.
+func (f *BIP37Filter) NumBytes() int {
+	return len(f.data)
+}
+
+// BIP37OutPoint returns the serialization of an outpoint that BIP37 filters
+// match and insert: the 32-byte transaction hash in internal byte order
+// followed by the little-endian output index.
+func BIP37OutPoint(txid [32]byte, index uint32) []byte {
+	return binary.LittleEndian.AppendUint32(txid[:], index)
+}
+
+// MatchOutput tests each data element pushed by an output's scriptPubKey
+// and reports whether any of them is in the filter. On a match the filter is
+// updated according to its flags, so that transactions later spending the
+// output also match:
+//
+//   - BIP37UpdateNone leaves the filter unchanged.
+//   - BIP37UpdateAll inserts the outpoint.
+//   - BIP37UpdateP2PubKeyOnly inserts the outpoint only if the script is
+//     pay-to-pubkey or bare multisig.
+func (f *BIP37Filter) MatchOutput(txid [32]byte, index uint32, script []byte) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	matched := false
+	for _, push := range scriptPushes(script) {
+		if len(push) > 0 && f.test(push) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	switch f.flags & bip37UpdateMask {
+	case BIP37UpdateAll:
+		f.add(BIP37OutPoint(txid, index))
+	case BIP37UpdateP2PubKeyOnly:
+		if isPayToPubKey(script) || isBareMultisig(script) {
+			f.add(BIP37OutPoint(txid, index))
+		}
+	}
+	return true
+}
+
+// MatchOutPoint reports whether an outpoint spent by a transaction input is
+// in the filter.
+func (f *BIP37Filter) MatchOutPoint(txid [32]byte, index uint32) bool {
+	return f.Test(BIP37OutPoint(txid, index))
+}
+
+// MarshalBinary encodes the filter as the body of a filterload message:
+//
+//	Size (bytes)  Description
+//	------------- ----------------------------------------------
+//	1-5           filter length in bytes (CompactSize)
+//	n             filter bytes
+//	4             nHashFuncs
+//	4             nTweak
+//	1             nFlags
+//
+// Integers are little-endian.
+func (f *BIP37Filter) MarshalBinary() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	buf := appendCompactSize(make([]byte, 0, len(f.data)+12), uint64(len(f.data)))
+	buf = append(buf, f.data...)
+	buf = binary.LittleEndian.AppendUint32(buf, f.hashFuncs)
+	buf = binary.LittleEndian.AppendUint32(buf, f.tweak)
+	return append(buf, byte(f.flags)), nil
+}
+
+// UnmarshalBIP37 decodes the body of a filterload message, rejecting filters
+// larger than 36,000 bytes or with more than 50 hash functions as Bitcoin
+// nodes do, and flags NewBIP37 would reject. The data is copied.
+func UnmarshalBIP37(data []byte) (*BIP37Filter, error) {
+	size, n, err := readCompactSize(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > BIP37MaxFilterBytes {
+		return nil, fmt.Errorf("BIP37 filter too large: %d bytes", size)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("BIP37 filter is empty")
+	}
+	data = data[n:]
+	if uint64(len(data)) != size+9 {
+		return nil, fmt.Errorf("invalid filterload length %d for a %d byte filter", len(data)+n, size)
+	}
+
+	f := &BIP37Filter{
+		data:      append([]byte(nil), data[:size]...),
+		hashFuncs: binary.LittleEndian.Uint32(data[size:]),
+		tweak:     binary.LittleEndian.Uint32(data[size+4:]),
+		flags:     BIP37Flags(data[size+8]),
+	}
+	if f.hashFuncs == 0 || f.hashFuncs > BIP37MaxHashFuncs {
+		return nil, fmt.Errorf("invalid number of BIP37 hash functions %d", f.hashFuncs)
+	}
+	if !validBIP37Flags(f.flags) {
+		return nil, fmt.Errorf("%w: BIP37 flags %d", ErrInvalidParams, f.flags)
+	}
+	return f, nil
+}
+
+// validBIP37Flags reports whether flags is one of the update modes.
+func validBIP37Flags(flags BIP37Flags) bool {
+	return flags&^bip37UpdateMask == 0 && flags&bip37UpdateMask != bip37UpdateMask
+}
+
+// appendCompactSize appends Bitcoin's variable length integer encoding.
+func appendCompactSize(dst []byte, v uint64) []byte {
+	switch {
+	case v < 0xfd:
+		return append(dst, byte(v))
+	case v <= math.MaxUint16:
+		return binary.LittleEndian.AppendUint16(append(dst, 0xfd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.LittleEndian.AppendUint32(append(dst, 0xfe), uint32(v))
+	}
+	return binary.LittleEndian.AppendUint64(append(dst, 0xff), v)
+}
+
+// readCompactSize decodes a CompactSize integer, rejecting non-canonical
+// encodings.
+func readCompactSize(data []byte) (v uint64, n int, err error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("reading CompactSize: unexpected end of data")
+	}
+
+	var minimum uint64
+	switch data[0] {
+	case 0xfd:
+		n, minimum = 3, 0xfd
+	case 0xfe:
+		n, minimum = 5, 0x10000
+	case 0xff:
+		n, minimum = 9, 0x100000000
+	default:
+		return uint64(data[0]), 1, nil
+	}
+	if len(data) < n {
+		return 0, 0, fmt.Errorf("reading CompactSize: unexpected end of data")
+	}
+
+	var buf [8]byte
+	copy(buf[:], data[1:n])
+	v = binary.LittleEndian.Uint64(buf[:])
+	if v < minimum {
+		return 0, 0, fmt.Errorf("non-canonical CompactSize")
+	}
+	return v, n, nil
+}
+
+// Script opcodes used to find data pushes and classify outputs.
+const (
+	opPushData1     = 0x4c
+	opPushData2     = 0x4d
+	opPushData4     = 0x4e
+	op1             = 0x51
+	op16            = 0x60
+	opCheckSig      = 0xac
+	opCheckMultisig = 0xae
+)
+
+// scriptPushes returns the data elements pushed by a script, in order. A
+// truncated push ends the parse, as in Bitcoin Core's GetOp.
+func scriptPushes(script []byte) [][]byte {
+	var pushes [][]byte
+	for ops := scriptOps(script); len(ops) > 0; ops = ops[1:] {
+		if ops[0].data != nil {
+			pushes = append(pushes, ops[0].data)
+		}
+	}
+	return pushes
+}
+
+type scriptOp struct {
+	opcode byte
+	data   []byte // non-nil for pushes, possibly empty
+}
+
+// scriptOps parses a script into opcodes, stopping at the first malformed
+// push.
+func scriptOps(script []byte) []scriptOp {
+	var ops []scriptOp
+	for len(script) > 0 {
+		opcode := script[0]
+		script = script[1:]
+		if opcode > opPushData4 {
+			ops = append(ops, scriptOp{opcode: opcode})
+			continue
+		}
+
+		var size uint64
+		switch opcode {
+		case opPushData1:
+			if len(script) < 1 {
+				return ops
+			}
+			size, script = uint64(script[0]), script[1:]
+		case opPushData2:
+			if len(script) < 2 {
+				return ops
+			}
+			size, script = uint64(binary.LittleEndian.Uint16(script)), script[2:]
+		case opPushData4:
+			if len(script) < 4 {
+				return ops
+			}
+			size, script = uint64(binary.LittleEndian.Uint32(script)), script[4:]
+		default:
+			size = uint64(opcode)
+		}
+		if uint64(len(script)) < size {
+			return ops
+		}
+		ops = append(ops, scriptOp{opcode: opcode, data: script[:size:size]})
+		script = script[size:]
+	}
+	return ops
+}
+
+func isPubKey(data []byte) bool {
+	switch len(data) {
+	case 33:
+		return data[0] == 0x02 || data[0] == 0x03
+	case 65:
+		return data[0] == 0x04
+	}
+	return false
+}
+
+// isPayToPubKey reports whether script is <pubkey> OP_CHECKSIG.
+func isPayToPubKey(script []byte) bool {
+	ops := scriptOps(script)
+	return len(ops) == 2 && isPubKey(ops[0].data) && ops[1].opcode == opCheckSig &&
+		len(ops[0].data)+2 == len(script)
+}
+
+// isBareMultisig reports whether script is
+// OP_m <pubkey>... OP_n OP_CHECKMULTISIG with 1 <= m <= n <= 16.
+func isBareMultisig(script []byte) bool {
+	ops := scriptOps(script)
+	if len(ops) < 4 || ops[len(ops)-1].opcode != opCheckMultisig {
+		return false
+	}
+
+	first, last := ops[0], ops[len(ops)-2]
+	if first.data != nil || last.data != nil ||
+		first.opcode < op1 || first.opcode > op16 || last.opcode < op1 || last.opcode > op16 {
+		return false
+	}
+	required, keys := int(first.opcode-op1+1), int(last.opcode-op1+1)
+	if required > keys || keys != len(ops)-3 {
+		return false
+	}
+
+	// Reject scripts truncated mid-push, which scriptOps silently drops.
+	size := 3
+	for _, op := range ops[1 : len(ops)-2] {
+		if !isPubKey(op.data) {
+			return false
+		}
+		size += 1 + len(op.data)
+	}
+	return size == len(script)
+}
@@ -0,0 +1,183 @@
+package bitbloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Vectors from Bitcoin Core's bloom_create_insert_serialize tests.
+func TestBIP37_CoreVectors(t *testing.T) {
+	tests := []struct {
+		tweak uint32
+		want  string
+	}{
+		{0, "03614e9b050000000000000001"},
+		{2147483649, "03ce4299050000000100008001"},
+	}
+	for _, tt := range tests {
+		f, err := NewBIP37(3, 0.01, tt.tweak, BIP37UpdateAll)
+		if err != nil {
+			t.Fatalf("NewBIP37 failed: %v", err)
+		}
+
+		for _, s := range []string{
+			"99108ad8ed9bb6274d3980bab5a85c048f0950c8",
+			"b5a2c786d9ef4658287ced5914b37a1b4aa32eee",
+			"b9300670b4c5366e95b2699e8b18bc75e5f729c5",
+		} {
+			item, _ := hex.DecodeString(s)
+			f.Add(item)
+			if !f.Test(item) {
+				t.Errorf("tweak %d: expected %s to be present", tt.tweak, s)
+			}
+		}
+
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		if got := hex.EncodeToString(data); got != tt.want {
+			t.Errorf("tweak %d: serialized %s, want %s", tt.tweak, got, tt.want)
+		}
+	}
+}
+
+func TestNewBIP37_Caps(t *testing.T) {
+	f, err := NewBIP37(1000000, 0.00001, 0, BIP37UpdateNone)
+	if err != nil {
+		t.Fatalf("NewBIP37 failed: %v", err)
+	}
+	if f.NumBytes() != BIP37MaxFilterBytes {
+		t.Errorf("Expected %d bytes, got %d", BIP37MaxFilterBytes, f.NumBytes())
+	}
+
+	f, _ = NewBIP37(1, 1e-30, 0, BIP37UpdateNone)
+	if f.HashFuncs() != BIP37MaxHashFuncs {
+		t.Errorf("Expected %d hash functions, got %d", BIP37MaxHashFuncs, f.HashFuncs())
+	}
+
+	// Core divides the bits per element as integers: 808/80 = 10, giving
+	// 6 hash functions where 10.1*ln 2 would give 7.
+	f, _ = NewBIP37(80, 0.0077, 0, BIP37UpdateNone)
+	if f.NumBytes() != 101 || f.HashFuncs() != 6 {
+		t.Errorf("Expected 101 bytes and 6 hash functions as in Core, got %d and %d", f.NumBytes(), f.HashFuncs())
+	}
+	data, _ := f.MarshalBinary()
+	if got := hex.EncodeToString(data[len(data)-9:]); got != "060000000000000000" {
+		t.Errorf("Expected nHashFuncs 6 in the filterload, got %s", got)
+	}
+
+	if _, err := NewBIP37(10, 0.01, 0, 3); err == nil {
+		t.Error("Expected error for invalid flags")
+	}
+}
+
+func TestUnmarshalBIP37(t *testing.T) {
+	f, _ := NewBIP37(10, 0.01, 42, BIP37UpdateP2PubKeyOnly)
+	f.Add([]byte("foo"))
+	data, _ := f.MarshalBinary()
+
+	decoded, err := UnmarshalBIP37(data)
+	if err != nil {
+		t.Fatalf("UnmarshalBIP37 failed: %v", err)
+	}
+	if decoded.Tweak() != 42 || decoded.Flags() != BIP37UpdateP2PubKeyOnly || decoded.HashFuncs() != f.HashFuncs() {
+		t.Errorf("Parameters not preserved: %+v", decoded)
+	}
+	if !decoded.Test([]byte("foo")) {
+		t.Error("Expected foo to be present after round trip")
+	}
+
+	tooLarge := appendCompactSize(nil, BIP37MaxFilterBytes+1)
+	tooLarge = append(tooLarge, make([]byte, BIP37MaxFilterBytes+10)...)
+	tooManyHashes := []byte{0x01, 0xff, 51, 0, 0, 0, 0, 0, 0, 0, 0}
+	for name, data := range map[string][]byte{
+		"empty":            nil,
+		"truncated":        data[:len(data)-1],
+		"too large":        tooLarge,
+		"too many hashes":  tooManyHashes,
+		"non-canonical":    {0xfd, 0x01, 0x00, 0xff, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		"zero hash funcs":  {0x01, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		"zero byte filter": {0x00, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if _, err := UnmarshalBIP37(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	for _, flags := range []byte{3, 4, 0x80} {
+		bad := append(data[:len(data)-1:len(data)-1], flags)
+		if _, err := UnmarshalBIP37(bad); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("Expected ErrInvalidParams for flags %d, got %v", flags, err)
+		}
+	}
+}
+
+func TestAppendCompactSize(t *testing.T) {
+	for v, want := range map[uint64]string{
+		0xfc:        "fc",
+		0xfd:        "fdfd00",
+		0xffff:      "fdffff",
+		0x10000:     "fe00000100",
+		0x100000000: "ff0000000001000000",
+	} {
+		got := appendCompactSize(nil, v)
+		if hex.EncodeToString(got) != want {
+			t.Errorf("appendCompactSize(%#x) = %x, want %s", v, got, want)
+		}
+		if back, n, err := readCompactSize(got); err != nil || back != v || n != len(got) {
+			t.Errorf("readCompactSize(%x) = %d, %d, %v", got, back, n, err)
+		}
+	}
+}
+
+func TestBIP37_MatchOutputFlags(t *testing.T) {
+	pubKey := append([]byte{0x02}, bytes.Repeat([]byte{0xab}, 32)...)
+	pubKeyHash := bytes.Repeat([]byte{0xcd}, 20)
+
+	p2pk := append(append([]byte{33}, pubKey...), opCheckSig)
+	multisig := append(append([]byte{op1, 33}, pubKey...), op1, opCheckMultisig)
+	p2pkh := append(append([]byte{0x76, 0xa9, 20}, pubKeyHash...), 0x88, opCheckSig)
+
+	var txid [32]byte
+	txid[0] = 1
+
+	tests := []struct {
+		name    string
+		flags   BIP37Flags
+		script  []byte
+		element []byte
+		update  bool
+	}{
+		{"none", BIP37UpdateNone, p2pk, pubKey, false},
+		{"all p2pkh", BIP37UpdateAll, p2pkh, pubKeyHash, true},
+		{"p2pubkey p2pk", BIP37UpdateP2PubKeyOnly, p2pk, pubKey, true},
+		{"p2pubkey multisig", BIP37UpdateP2PubKeyOnly, multisig, pubKey, true},
+		{"p2pubkey p2pkh", BIP37UpdateP2PubKeyOnly, p2pkh, pubKeyHash, false},
+	}
+	for _, tt := range tests {
+		f, _ := NewBIP37(10, 0.0001, 7, tt.flags)
+		if f.MatchOutput(txid, 3, tt.script) {
+			t.Errorf("%s: unexpected match on empty filter", tt.name)
+		}
+
+		f.Add(tt.element)
+		if !f.MatchOutput(txid, 3, tt.script) {
+			t.Errorf("%s: expected output to match", tt.name)
+		}
+		if got := f.MatchOutPoint(txid, 3); got != tt.update {
+			t.Errorf("%s: MatchOutPoint = %v, want %v", tt.name, got, tt.update)
+		}
+	}
+}
+
+func TestBIP37OutPoint(t *testing.T) {
+	var txid [32]byte
+	txid[31] = 0xff
+	got := BIP37OutPoint(txid, 0x01020304)
+	if len(got) != 36 || got[31] != 0xff || !bytes.Equal(got[32:], []byte{4, 3, 2, 1}) {
+		t.Errorf("Unexpected outpoint serialization %x", got)
+	}
+}
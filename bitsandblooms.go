@@ -0,0 +1,127 @@
+package bitbloom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// NewBitsAndBlooms creates a Bloom filter that hashes items the same way as
+// github.com/bits-and-blooms/bloom/v3, so it can be exchanged with that
+// library using WriteBitsAndBlooms and ReadBitsAndBlooms.
+//
+// Like bloom.New, m and k are raised to at least 1.
+func NewBitsAndBlooms(m, k uint64) *BloomFilter {
+	bf, _ := newBloomFilterWithScheme(max(m, 1), max(k, 1), hasher.SchemeBitsAndBlooms)
+	return bf
+}
+
+// NewBitsAndBloomsWithEstimates is the equivalent of bloom.NewWithEstimates:
+// it creates a bits-and-blooms compatible filter sized for `n` items with a
+// false positive probability of `p`.
+func NewBitsAndBloomsWithEstimates(n uint64, p float64) (*BloomFilter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+
+	m := OptimalM(n, p)
+	return NewBitsAndBlooms(m, OptimalK(m, n)), nil
+}
+
+// WriteBitsAndBlooms writes the filter in the format of the WriteTo method
+// of github.com/bits-and-blooms/bloom/v3, with the default big-endian bitset
+// byte order:
+//
+//	Offset  Size (bytes)  Description
+//	------  ------------- ----------------------------------------------
+//	0       8             m: total number of bits in the filter
+//	8       8             k: number of hash functions used
+//	16      8             bitset length in bits (equal to m)
+//	24      8 * w         bitset data (w = ceil(m / 64)) 64-bit words
+//
+// All fields are big-endian. The filter must have been created with
+// NewBitsAndBlooms or read with ReadBitsAndBlooms, since filters using
+// another hashing scheme would answer differently once imported.
+//
+// The item count is not part of the format and is lost.
+func (bf *BloomFilter) WriteBitsAndBlooms(w io.Writer) (int64, error) {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	if bf.scheme != hasher.SchemeBitsAndBlooms {
+		return 0, fmt.Errorf("cannot export a filter using the %s hashing scheme in bits-and-blooms format", bf.scheme)
+	}
+	if bf.probe != 0 {
+		return 0, fmt.Errorf("cannot export a partitioned filter in bits-and-blooms format")
+	}
+
+	bw := bufio.NewWriter(w)
+	var buf [8]byte
+	for _, v := range []uint64{bf.m, bf.k, bf.m} {
+		binary.BigEndian.PutUint64(buf[:], v)
+		bw.Write(buf[:])
+	}
+	data := bf.storage.Words()
+	for _, word := range data {
+		binary.BigEndian.PutUint64(buf[:], word)
+		bw.Write(buf[:])
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(24 + 8*len(data)), nil
+}
+
+// ReadBitsAndBlooms reads a filter written by the WriteTo method of
+// github.com/bits-and-blooms/bloom/v3 or by WriteBitsAndBlooms. The returned
+// filter uses the bits-and-blooms hashing scheme, so it answers Test exactly
+// as the original does.
+//
+// As the format does not record the number of items added, the count of the
+// returned filter is estimated from the number of bits set.
+func ReadBitsAndBlooms(r io.Reader) (*BloomFilter, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading bits-and-blooms header: %w", err)
+	}
+
+	m := binary.BigEndian.Uint64(header[0:8])
+	k := binary.BigEndian.Uint64(header[8:16])
+	length := binary.BigEndian.Uint64(header[16:24])
+
+	if m == 0 || k == 0 {
+		return nil, fmt.Errorf("invalid parameters in bits-and-blooms data")
+	}
+	if length != m {
+		return nil, fmt.Errorf("bits-and-blooms bitset length %d does not match m = %d", length, m)
+	}
+
+	// Read the words before allocating the filter, so a corrupt m cannot
+	// trigger a huge allocation without the data to back it.
+	words := (m + 63) / 64
+	if words > (1<<63)/8 {
+		return nil, fmt.Errorf("bits-and-blooms bitset too large: m = %d", m)
+	}
+	var data bytes.Buffer
+	n, err := io.CopyN(&data, r, int64(words*8))
+	if err != nil {
+		return nil, fmt.Errorf("reading bits-and-blooms bitset: read %d of %d bytes: %w", n, words*8, err)
+	}
+
+	bf := NewBitsAndBlooms(m, k)
+	raw := data.Bytes()
+	bitsetData := bf.storage.Words()
+	var setBits uint64
+	for i := range bitsetData {
+		bitsetData[i] = binary.BigEndian.Uint64(raw[i*8:])
+		setBits += uint64(bits.OnesCount64(bitsetData[i]))
+	}
+	bf.count = uint64(estimateCardinality(m, k, setBits) + 0.5)
+
+	return bf, nil
+}
@@ -0,0 +1,132 @@
+package bitbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The golden files in testdata/bitsandblooms were written by
+// github.com/bits-and-blooms/bloom/v3 v3.7.1. Each <name>.bin holds a filter
+// with "key-0" .. "key-<n-1>" added, and <name>.probes holds the library's
+// Test result for "probe-0" .. "probe-1999" as a string of 0s and 1s.
+var bitsAndBloomsGolden = []struct {
+	name  string
+	build func() *BloomFilter
+	keys  int
+}{
+	{"estimates", func() *BloomFilter {
+		bf, _ := NewBitsAndBloomsWithEstimates(1000, 0.01)
+		return bf
+	}, 1000},
+	{"small", func() *BloomFilter { return NewBitsAndBlooms(100, 3) }, 30},
+	{"empty", func() *BloomFilter { return NewBitsAndBlooms(0, 0) }, 0},
+}
+
+func readGolden(t *testing.T, name string) ([]byte, string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "bitsandblooms", name+".bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	probes, err := os.ReadFile(filepath.Join("testdata", "bitsandblooms", name+".probes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, strings.TrimSpace(string(probes))
+}
+
+func TestReadBitsAndBlooms_Golden(t *testing.T) {
+	for _, tt := range bitsAndBloomsGolden {
+		t.Run(tt.name, func(t *testing.T) {
+			data, probes := readGolden(t, tt.name)
+
+			bf, err := ReadBitsAndBlooms(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ReadBitsAndBlooms failed: %v", err)
+			}
+			for i := 0; i < tt.keys; i++ {
+				if !bf.Test([]byte(fmt.Sprintf("key-%d", i))) {
+					t.Fatalf("Expected key-%d to be present", i)
+				}
+			}
+			for i, want := range probes {
+				if got := bf.Test([]byte(fmt.Sprintf("probe-%d", i))); got != (want == '1') {
+					t.Errorf("probe-%d: expected %v, got %v", i, want == '1', got)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteBitsAndBlooms_Golden(t *testing.T) {
+	for _, tt := range bitsAndBloomsGolden {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := readGolden(t, tt.name)
+
+			bf := tt.build()
+			for i := 0; i < tt.keys; i++ {
+				bf.Add([]byte(fmt.Sprintf("key-%d", i)))
+			}
+
+			var buf bytes.Buffer
+			n, err := bf.WriteBitsAndBlooms(&buf)
+			if err != nil {
+				t.Fatalf("WriteBitsAndBlooms failed: %v", err)
+			}
+			if n != int64(buf.Len()) {
+				t.Errorf("Reported %d bytes written, wrote %d", n, buf.Len())
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Error("Exported filter differs from the one built by bits-and-blooms")
+			}
+		})
+	}
+}
+
+func TestBitsAndBlooms_SchemeSurvivesMarshal(t *testing.T) {
+	data, _ := readGolden(t, "small")
+	bf, _ := ReadBitsAndBlooms(bytes.NewReader(data))
+
+	encoded, _ := bf.MarshalBinary()
+	decoded, err := UnmarshalBinary(encoded)
+	if err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := decoded.WriteBitsAndBlooms(&buf); err != nil {
+		t.Fatalf("WriteBitsAndBlooms after round trip failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("Filter changed across a MarshalBinary round trip")
+	}
+}
+
+func TestBitsAndBlooms_Errors(t *testing.T) {
+	if _, err := NewWithParams(100, 3).WriteBitsAndBlooms(&bytes.Buffer{}); err == nil {
+		t.Error("Expected error exporting a filter with the murmur3 scheme")
+	}
+
+	data, _ := readGolden(t, "small")
+	if _, err := ReadBitsAndBlooms(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+
+	// Header claiming an enormous bitset but carrying no data.
+	huge := make([]byte, 24)
+	binary.BigEndian.PutUint64(huge[0:8], 1<<40)
+	binary.BigEndian.PutUint64(huge[8:16], 3)
+	binary.BigEndian.PutUint64(huge[16:24], 1<<40)
+	if _, err := ReadBitsAndBlooms(bytes.NewReader(huge)); err == nil {
+		t.Error("Expected error for missing bitset data")
+	}
+
+	if err := NewWithParams(100, 3).Merge(NewBitsAndBlooms(100, 3)); err == nil {
+		t.Error("Expected error merging filters with different schemes")
+	}
+}
@@ -0,0 +1,282 @@
+package bitset
+
+import (
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+// The set operations combine the words two bitsets have in common, so
+// bitsets of different sizes can be combined: bits of other beyond the
+// receiver's size are ignored, and And treats the receiver's words beyond
+// other's as intersected with nothing.
+//
+// Changes cannot express cleared bits, so when changes are tracked And,
+// AndNot, Xor and a shrinking Resize are recorded as Clear is: versions up
+// to the current one can no longer be brought up to date, and every word
+// with a bit set counts as changed in the next version.
+
+// Or sets every bit that is set in other, leaving the union.
+func (bs *BitSet) Or(other *BitSet) {
+	src := other.data[:min(len(bs.data), len(other.data))]
+	if bs.stamps != nil {
+		for i, w := range src {
+			if i == len(bs.data)-1 {
+				w &= bs.lastMask()
+			}
+			bs.OrWord(i, w)
+		}
+		return
+	}
+
+	bs.own()
+	orWords(bs.data, src)
+	bs.trim()
+}
+
+// And unsets every bit that is not set in other, leaving the intersection.
+func (bs *BitSet) And(other *BitSet) {
+	bs.own()
+	n := min(len(bs.data), len(other.data))
+	andWords(bs.data, other.data[:n])
+	clear(bs.data[n:])
+	bs.restamp()
+}
+
+// AndNot unsets every bit that is set in other, leaving the difference.
+func (bs *BitSet) AndNot(other *BitSet) {
+	bs.own()
+	andNotWords(bs.data, other.data[:min(len(bs.data), len(other.data))])
+	bs.restamp()
+}
+
+// Xor flips every bit that is set in other, leaving the symmetric
+// difference.
+func (bs *BitSet) Xor(other *BitSet) {
+	bs.own()
+	xorWords(bs.data, other.data[:min(len(bs.data), len(other.data))])
+	bs.trim()
+	bs.restamp()
+}
+
+// IntersectionCount returns the number of bits set in both bs and other,
+// without modifying either.
+func (bs *BitSet) IntersectionCount(other *BitSet) uint {
+	n := min(len(bs.data), len(other.data))
+	return andCount(bs.data[:n], other.data[:n])
+}
+
+// UnionCount returns the number of bits set in bs, other or both, without
+// modifying either.
+func (bs *BitSet) UnionCount(other *BitSet) uint {
+	a, b := bs.data, other.data
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	return orCount(a[:len(b)], b) + popCount(a[len(b):])
+}
+
+// NextSet returns the position of the first bit set at or after pos, and
+// false if there is none.
+func (bs *BitSet) NextSet(pos uint64) (uint64, bool) {
+	if pos >= bs.size {
+		return 0, false
+	}
+	i := pos / 64
+	if w := bs.data[i] >> (pos % 64); w != 0 {
+		return pos + uint64(bits.TrailingZeros64(w)), true
+	}
+	for i++; i < uint64(len(bs.data)); i++ {
+		if w := bs.data[i]; w != 0 {
+			return i*64 + uint64(bits.TrailingZeros64(w)), true
+		}
+	}
+	return 0, false
+}
+
+// All returns an iterator over the positions of the bits set, in
+// increasing order. The bitset must not be modified during iteration.
+func (bs *BitSet) All() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		for i, w := range bs.data {
+			for w != 0 {
+				if !yield(uint64(i)*64 + uint64(bits.TrailingZeros64(w))) {
+					return
+				}
+				w &= w - 1
+			}
+		}
+	}
+}
+
+// Resize changes the size of the bitset to size bits. Growing adds unset
+// bits; shrinking drops the bits at or beyond the new size.
+func (bs *BitSet) Resize(size uint64) {
+	shrunk := size < bs.size
+	words := int((size + 63) / 64)
+
+	bs.own()
+	bs.data = resizeWords(bs.data, words)
+	bs.size = size
+	bs.trim()
+	if bs.stamps != nil {
+		bs.stamps = resizeWords(bs.stamps, words)
+		if shrunk {
+			bs.restamp()
+		}
+	}
+}
+
+// resizeWords returns s with n elements, zeroing any it gains.
+func resizeWords(s []uint64, n int) []uint64 {
+	if n <= len(s) {
+		return s[:n]
+	}
+	old := len(s)
+	s = slices.Grow(s, n-old)[:n]
+	clear(s[old:])
+	return s
+}
+
+// lastMask returns the mask of the bits of the last word that lie within
+// the size.
+func (bs *BitSet) lastMask() uint64 {
+	if bs.size%64 == 0 {
+		return ^uint64(0)
+	}
+	return 1<<(bs.size%64) - 1
+}
+
+// trim unsets the bits of the last word beyond the size.
+func (bs *BitSet) trim() {
+	if len(bs.data) > 0 {
+		bs.data[len(bs.data)-1] &= bs.lastMask()
+	}
+}
+
+// restamp records an operation that may have cleared bits, as Clear does,
+// if changes are tracked.
+func (bs *BitSet) restamp() {
+	if bs.stamps == nil {
+		return
+	}
+	for i, w := range bs.data {
+		if w != 0 {
+			bs.stamps[i] = bs.closed + 1
+		} else {
+			bs.stamps[i] = 0
+		}
+	}
+	bs.cleared = bs.closed
+}
+
+// The word loops below are unrolled by four. Reslicing each block to a
+// constant length lets the compiler drop the bounds checks inside it.
+
+func orWords(dst, src []uint64) {
+	i := 0
+	for ; i+4 <= len(src); i += 4 {
+		d, s := dst[i:i+4:i+4], src[i:i+4:i+4]
+		d[0] |= s[0]
+		d[1] |= s[1]
+		d[2] |= s[2]
+		d[3] |= s[3]
+	}
+	for ; i < len(src); i++ {
+		dst[i] |= src[i]
+	}
+}
+
+func andWords(dst, src []uint64) {
+	i := 0
+	for ; i+4 <= len(src); i += 4 {
+		d, s := dst[i:i+4:i+4], src[i:i+4:i+4]
+		d[0] &= s[0]
+		d[1] &= s[1]
+		d[2] &= s[2]
+		d[3] &= s[3]
+	}
+	for ; i < len(src); i++ {
+		dst[i] &= src[i]
+	}
+}
+
+func andNotWords(dst, src []uint64) {
+	i := 0
+	for ; i+4 <= len(src); i += 4 {
+		d, s := dst[i:i+4:i+4], src[i:i+4:i+4]
+		d[0] &^= s[0]
+		d[1] &^= s[1]
+		d[2] &^= s[2]
+		d[3] &^= s[3]
+	}
+	for ; i < len(src); i++ {
+		dst[i] &^= src[i]
+	}
+}
+
+func xorWords(dst, src []uint64) {
+	i := 0
+	for ; i+4 <= len(src); i += 4 {
+		d, s := dst[i:i+4:i+4], src[i:i+4:i+4]
+		d[0] ^= s[0]
+		d[1] ^= s[1]
+		d[2] ^= s[2]
+		d[3] ^= s[3]
+	}
+	for ; i < len(src); i++ {
+		dst[i] ^= src[i]
+	}
+}
+
+// andCount returns the number of bits set in both a and b, which have the
+// same length.
+func andCount(a, b []uint64) uint {
+	var c0, c1, c2, c3 int
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		x, y := a[i:i+4:i+4], b[i:i+4:i+4]
+		c0 += bits.OnesCount64(x[0] & y[0])
+		c1 += bits.OnesCount64(x[1] & y[1])
+		c2 += bits.OnesCount64(x[2] & y[2])
+		c3 += bits.OnesCount64(x[3] & y[3])
+	}
+	for ; i < len(a); i++ {
+		c0 += bits.OnesCount64(a[i] & b[i])
+	}
+	return uint(c0 + c1 + c2 + c3)
+}
+
+// orCount returns the number of bits set in a or b, which have the same
+// length.
+func orCount(a, b []uint64) uint {
+	var c0, c1, c2, c3 int
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		x, y := a[i:i+4:i+4], b[i:i+4:i+4]
+		c0 += bits.OnesCount64(x[0] | y[0])
+		c1 += bits.OnesCount64(x[1] | y[1])
+		c2 += bits.OnesCount64(x[2] | y[2])
+		c3 += bits.OnesCount64(x[3] | y[3])
+	}
+	for ; i < len(a); i++ {
+		c0 += bits.OnesCount64(a[i] | b[i])
+	}
+	return uint(c0 + c1 + c2 + c3)
+}
+
+func popCount(a []uint64) uint {
+	var c0, c1, c2, c3 int
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		x := a[i : i+4 : i+4]
+		c0 += bits.OnesCount64(x[0])
+		c1 += bits.OnesCount64(x[1])
+		c2 += bits.OnesCount64(x[2])
+		c3 += bits.OnesCount64(x[3])
+	}
+	for ; i < len(a); i++ {
+		c0 += bits.OnesCount64(a[i])
+	}
+	return uint(c0 + c1 + c2 + c3)
+}
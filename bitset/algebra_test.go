@@ -0,0 +1,199 @@
+package bitset
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// naive returns the positions set in bs by probing every bit.
+func naive(bs *BitSet) []uint64 {
+	var got []uint64
+	for i := range bs.Size() {
+		if bs.Get(i) {
+			got = append(got, i)
+		}
+	}
+	return got
+}
+
+func randomBitSet(r *rand.Rand, size uint64) *BitSet {
+	bs := New(size)
+	for range size / 3 {
+		bs.Set(r.Uint64N(size))
+	}
+	return bs
+}
+
+func TestBitSet_Algebra(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	// Sizes straddle the unrolled blocks and partial last words.
+	for _, sizes := range [][2]uint64{{640, 640}, {700, 300}, {300, 700}, {1, 65}, {257, 257}} {
+		a, b := randomBitSet(r, sizes[0]), randomBitSet(r, sizes[1])
+
+		var and, or, andNot, xor []uint64
+		for i := range a.Size() {
+			x, y := a.Get(i), b.Get(i)
+			if x && y {
+				and = append(and, i)
+			}
+			if x || y {
+				or = append(or, i)
+			}
+			if x && !y {
+				andNot = append(andNot, i)
+			}
+			if x != y {
+				xor = append(xor, i)
+			}
+		}
+
+		var union uint
+		for i := range max(a.Size(), b.Size()) {
+			if a.Get(i) || b.Get(i) {
+				union++
+			}
+		}
+		if got := a.IntersectionCount(b); got != uint(len(and)) {
+			t.Errorf("%v: expected intersection count %d, got %d", sizes, len(and), got)
+		}
+		if got := a.UnionCount(b); got != union {
+			t.Errorf("%v: expected union count %d, got %d", sizes, union, got)
+		}
+
+		for _, tc := range []struct {
+			name string
+			op   func(*BitSet, *BitSet)
+			want []uint64
+		}{
+			{"Or", (*BitSet).Or, or},
+			{"And", (*BitSet).And, and},
+			{"AndNot", (*BitSet).AndNot, andNot},
+			{"Xor", (*BitSet).Xor, xor},
+		} {
+			c := New(a.Size())
+			c.SetData(slices.Clone(a.Data()))
+			tc.op(c, b)
+			if got := naive(c); !slices.Equal(got, tc.want) {
+				t.Errorf("%v: %s gave %v, want %v", sizes, tc.name, got, tc.want)
+			}
+			if c.Count() != uint(len(tc.want)) {
+				t.Errorf("%v: %s left bits beyond the size: count %d, want %d", sizes, tc.name, c.Count(), len(tc.want))
+			}
+		}
+	}
+}
+
+func TestBitSet_AlgebraShared(t *testing.T) {
+	a, b := New(128), New(128)
+	a.Set(1)
+	b.Set(2)
+	shared := a.Share()
+
+	a.Or(b)
+	a.Xor(b)
+	a.And(b)
+	if shared[0] != 1<<1 {
+		t.Errorf("Shared words changed: %x", shared)
+	}
+	if a.Count() != 0 {
+		t.Errorf("Expected an empty set, got %d bits", a.Count())
+	}
+}
+
+func TestBitSet_AlgebraTracked(t *testing.T) {
+	a, b := New(256), New(256)
+	a.Set(1)
+	a.Set(200)
+	b.Set(70)
+	a.Track()
+	a.Changes(0, func(int, uint64) {})
+
+	a.Or(b)
+	changes := func(since uint64) map[int]uint64 {
+		got := make(map[int]uint64)
+		a.Changes(since, func(i int, w uint64) { got[i] = w })
+		return got
+	}
+	if got := changes(1); len(got) != 1 || got[1] != 1<<6 {
+		t.Errorf("Expected Or to change only word 1, got %v", got)
+	}
+
+	a.AndNot(b)
+	if a.Cleared() != 2 {
+		t.Errorf("Expected AndNot to be recorded as a clear after version 2, got %d", a.Cleared())
+	}
+	if got := changes(0); len(got) != 2 || got[0] != 1<<1 || got[3] != 1<<8 {
+		t.Errorf("Expected the remaining words changed since the clear, got %v", got)
+	}
+}
+
+func TestBitSet_NextSetAndAll(t *testing.T) {
+	bs := New(300)
+	want := []uint64{0, 63, 64, 130, 299}
+	for _, pos := range want {
+		bs.Set(pos)
+	}
+
+	var got []uint64
+	for pos, ok := bs.NextSet(0); ok; pos, ok = bs.NextSet(pos + 1) {
+		got = append(got, pos)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("NextSet visited %v, want %v", got, want)
+	}
+	if pos, ok := bs.NextSet(65); !ok || pos != 130 {
+		t.Errorf("Expected NextSet(65) = 130, got %d, %v", pos, ok)
+	}
+	if _, ok := bs.NextSet(1000); ok {
+		t.Error("Expected no bit beyond the size")
+	}
+
+	if got := slices.Collect(bs.All()); !slices.Equal(got, want) {
+		t.Errorf("All yielded %v, want %v", got, want)
+	}
+	for pos := range bs.All() {
+		if pos != 0 {
+			t.Errorf("Expected to stop after the first bit, got %d", pos)
+		}
+		break
+	}
+}
+
+func TestBitSet_Resize(t *testing.T) {
+	bs := New(200)
+	bs.Set(10)
+	bs.Set(150)
+	bs.Set(199)
+
+	bs.Resize(100)
+	if bs.Size() != 100 || !slices.Equal(naive(bs), []uint64{10}) {
+		t.Errorf("Expected only bit 10 in 100 bits, got %v", naive(bs))
+	}
+
+	// Growing again exposes no stale bits.
+	bs.Resize(200)
+	if bs.Size() != 200 || bs.Count() != 1 || bs.Get(150) || bs.Get(199) {
+		t.Errorf("Expected growing to add unset bits, got %v", naive(bs))
+	}
+	bs.Set(199)
+	if !bs.Get(199) {
+		t.Error("Expected to set a bit added by growing")
+	}
+
+	tracked := New(128)
+	tracked.Track()
+	tracked.Set(100)
+	tracked.Changes(0, func(int, uint64) {})
+	tracked.Resize(256)
+	tracked.Set(250)
+	var changed []int
+	tracked.Changes(1, func(i int, _ uint64) { changed = append(changed, i) })
+	if !slices.Equal(changed, []int{3}) {
+		t.Errorf("Expected word 3 changed after growing, got %v", changed)
+	}
+	tracked.Resize(64)
+	if tracked.Cleared() != tracked.Version() || tracked.Count() != 0 {
+		t.Errorf("Expected shrinking to be recorded as a clear, cleared %d version %d", tracked.Cleared(), tracked.Version())
+	}
+}
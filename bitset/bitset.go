@@ -0,0 +1,198 @@
+/*
+Package bitset provides the bitsets that back bitbloom filters.
+
+BitSet is a dense array of 64-bit words with word-level set algebra (Or,
+And, AndNot, Xor and their counts), iteration over set bits, and optional
+change tracking for replication. Sparse is a compressed, Roaring-style
+bitset for large sets with few bits set.
+
+Bit i of a BitSet is bit i%64 of word i/64, the layout of the words written
+by bitbloom's MarshalBinary.
+*/
+package bitset
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// BitSet is a set of bits of a given size. It is not safe for concurrent
+// modification.
+type BitSet struct {
+	data []uint64
+	size uint64
+
+	// stamps holds, once Track has been called, the version in which each
+	// word last changed. Changes made now belong to version closed+1.
+	stamps  []uint64
+	closed  uint64
+	cleared uint64
+
+	// shared is set when data has been handed out by Share and must be
+	// copied before it is modified.
+	shared bool
+}
+
+// New returns a bitset of size bits, all unset.
+func New(size uint64) *BitSet {
+	words := (size + 63) / 64
+	return &BitSet{
+		data: make([]uint64, words),
+		size: size,
+	}
+}
+
+// NewFromData returns a bitset of size bits using data, which must hold
+// exactly ceil(size/64) words. The slice is not copied.
+func NewFromData(data []uint64, size uint64) (*BitSet, error) {
+	bs := &BitSet{size: size}
+	if err := bs.SetData(data); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// Set sets the bit at pos. Positions at or beyond the size are ignored.
+func (bs *BitSet) Set(pos uint64) {
+	if pos >= bs.size {
+		return
+	}
+	word := pos / 64
+	bit := pos % 64
+	if bs.data[word]&(1<<bit) != 0 {
+		return
+	}
+	bs.own()
+	if bs.stamps != nil {
+		bs.stamps[word] = bs.closed + 1
+	}
+	bs.data[word] |= 1 << bit
+}
+
+// OrWord sets the bits of mask in word i.
+func (bs *BitSet) OrWord(i int, mask uint64) {
+	if mask&^bs.data[i] == 0 {
+		return
+	}
+	bs.own()
+	if bs.stamps != nil {
+		bs.stamps[i] = bs.closed + 1
+	}
+	bs.data[i] |= mask
+}
+
+// Get reports whether the bit at pos is set. Positions at or beyond the
+// size are unset.
+func (bs *BitSet) Get(pos uint64) bool {
+	if pos >= bs.size {
+		return false
+	}
+	word := pos / 64
+	bit := pos % 64
+	return (bs.data[word] & (1 << bit)) != 0
+}
+
+// Count returns the number of bits set.
+func (bs *BitSet) Count() uint {
+	count := uint(0)
+	for _, word := range bs.data {
+		count += uint(bits.OnesCount64(word))
+	}
+	return count
+}
+
+// Size returns the number of bits.
+func (bs *BitSet) Size() uint64 {
+	return bs.size
+}
+
+// Data returns the bitset's words without copying. Writes to them modify
+// the bitset but are not recorded as changes.
+func (bs *BitSet) Data() []uint64 {
+	return bs.data
+}
+
+// SetData replaces the bitset's words with data, which must hold exactly
+// ceil(size/64) words. The slice is not copied.
+func (bs *BitSet) SetData(data []uint64) error {
+	expectedWords := (bs.size + 63) / 64
+	if uint64(len(data)) != expectedWords {
+		return fmt.Errorf("invalid data length: expected %d words, got %d",
+			expectedWords, len(data))
+	}
+	bs.data = data
+	bs.shared = false
+	return nil
+}
+
+// Track starts recording which words change, so they can be listed by
+// Changes. Words with bits already set are recorded as changed in the
+// first version. Calling Track again has no effect.
+func (bs *BitSet) Track() {
+	if bs.stamps != nil {
+		return
+	}
+	bs.stamps = make([]uint64, len(bs.data))
+	for i, w := range bs.data {
+		if w != 0 {
+			bs.stamps[i] = 1
+		}
+	}
+}
+
+// Version returns the last version closed by Changes, or 0 if none has
+// been.
+func (bs *BitSet) Version() uint64 {
+	return bs.closed
+}
+
+// Changes calls fn with the index and value of every word that changed
+// after version since, then closes the current version and returns it.
+// Changes made afterwards belong to the next version. Track must have been
+// called.
+func (bs *BitSet) Changes(since uint64, fn func(i int, w uint64)) uint64 {
+	for i, stamp := range bs.stamps {
+		if stamp > since {
+			fn(i, bs.data[i])
+		}
+	}
+	bs.closed++
+	return bs.closed
+}
+
+// Cleared returns the last version closed before the most recent Clear,
+// or 0.
+func (bs *BitSet) Cleared() uint64 {
+	return bs.cleared
+}
+
+// Share returns the bitset's words for read-only use. The next change to
+// the bitset copies them first, so the returned slice never changes.
+func (bs *BitSet) Share() []uint64 {
+	bs.shared = true
+	return bs.data
+}
+
+// own copies data if it is shared, before it is modified.
+func (bs *BitSet) own() {
+	if bs.shared {
+		bs.data = append([]uint64(nil), bs.data...)
+		bs.shared = false
+	}
+}
+
+// Clear unsets every bit. Changes cannot express the cleared bits, so the
+// version is recorded as Cleared and the changes recorded so far are
+// discarded.
+func (bs *BitSet) Clear() {
+	if bs.shared {
+		bs.data = make([]uint64, len(bs.data))
+		bs.shared = false
+	} else {
+		clear(bs.data)
+	}
+	if bs.stamps != nil {
+		clear(bs.stamps)
+		bs.cleared = bs.closed
+	}
+}
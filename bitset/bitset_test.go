@@ -187,3 +187,89 @@ func TestBitSet_OverflowSafe(t *testing.T) {
 		t.Errorf("Out-of-bound set/get should be ignored and return false")
 	}
 }
+
+func TestNewFromData(t *testing.T) {
+	bs, err := NewFromData([]uint64{0, 1}, 100)
+	if err != nil {
+		t.Fatalf("NewFromData failed: %v", err)
+	}
+	if !bs.Get(64) || bs.Count() != 1 {
+		t.Errorf("Expected only bit 64 to be set")
+	}
+
+	if _, err := NewFromData([]uint64{0}, 100); err == nil {
+		t.Error("Expected error for wrong word count")
+	}
+}
+
+func TestBitSet_Changes(t *testing.T) {
+	bs := New(256)
+	bs.Set(3)
+	bs.Track()
+
+	changes := func(since uint64) map[int]uint64 {
+		got := make(map[int]uint64)
+		bs.Changes(since, func(i int, w uint64) { got[i] = w })
+		return got
+	}
+
+	if got := changes(0); len(got) != 1 || got[0] != 1<<3 {
+		t.Errorf("Expected bits set before Track in version 1, got %v", got)
+	}
+	if bs.Version() != 1 {
+		t.Errorf("Expected version 1, got %d", bs.Version())
+	}
+
+	bs.Set(3) // already set, not a change
+	bs.Set(130)
+	bs.OrWord(0, 1<<5)
+	if got := changes(1); len(got) != 2 || got[0] != 1<<3|1<<5 || got[2] != 1<<2 {
+		t.Errorf("Expected words 0 and 2 changed in version 2, got %v", got)
+	}
+	if got := changes(2); len(got) != 0 {
+		t.Errorf("Expected no changes after version 2, got %v", got)
+	}
+	if got := changes(0); len(got) != 2 {
+		t.Errorf("Expected every changed word since version 0, got %v", got)
+	}
+}
+
+func TestBitSet_Share(t *testing.T) {
+	bs := New(128)
+	bs.Set(1)
+	shared := bs.Share()
+
+	bs.Set(1) // no change, no copy
+	bs.Set(70)
+	bs.OrWord(0, 1<<2)
+	if shared[0] != 1<<1 || shared[1] != 0 {
+		t.Errorf("Shared words changed: %x", shared)
+	}
+	if !bs.Get(70) || !bs.Get(2) {
+		t.Error("Expected changes after sharing to be applied")
+	}
+
+	shared = bs.Share()
+	bs.Clear()
+	if shared[0] == 0 || bs.Count() != 0 {
+		t.Error("Expected Clear to leave the shared words intact")
+	}
+}
+
+func TestBitSet_ClearTracked(t *testing.T) {
+	bs := New(128)
+	bs.Track()
+	bs.Set(1)
+	bs.Changes(0, func(int, uint64) {})
+	bs.Clear()
+
+	if bs.Cleared() != 1 {
+		t.Errorf("Expected Cleared 1, got %d", bs.Cleared())
+	}
+	bs.Set(65)
+	var changed []int
+	bs.Changes(0, func(i int, _ uint64) { changed = append(changed, i) })
+	if len(changed) != 1 || changed[0] != 1 {
+		t.Errorf("Expected only word 1 changed since the clear, got %v", changed)
+	}
+}
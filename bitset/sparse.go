@@ -0,0 +1,329 @@
+package bitset
+
+import (
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// chunkBits is the number of bits covered by one container.
+	chunkBits  = 1 << 16
+	chunkWords = chunkBits / 64
+	// arrayMax is the largest number of positions a container keeps in an
+	// array: beyond it the array takes more memory than a bitmap.
+	arrayMax = chunkWords * 4
+)
+
+// Sparse is a compressed bitset in the style of Roaring bitmaps. Its bits
+// are split into chunks of 2^16, each held by a container that is absent
+// while the chunk is empty, a sorted array of 16-bit offsets while it has
+// at most 4096 bits set, and a bitmap of 1024 words beyond that. A sparse
+// bitset therefore takes about two bytes per bit set, rather than one bit
+// per bit of its size.
+type Sparse struct {
+	chunks []*container
+	size   uint64
+	count  uint64
+}
+
+// container holds the bits of one chunk, in array while bitmap is nil.
+type container struct {
+	array  []uint16
+	bitmap []uint64
+	n      int
+}
+
+// NewSparse returns a sparse bitset of size bits, all unset.
+func NewSparse(size uint64) *Sparse {
+	return &Sparse{
+		chunks: make([]*container, (size+chunkBits-1)/chunkBits),
+		size:   size,
+	}
+}
+
+func (s *Sparse) Set(pos uint64) {
+	if pos >= s.size {
+		return
+	}
+	c := s.chunks[pos/chunkBits]
+	if c == nil {
+		c = &container{}
+		s.chunks[pos/chunkBits] = c
+	}
+	if c.set(uint16(pos)) {
+		s.count++
+	}
+}
+
+func (s *Sparse) Get(pos uint64) bool {
+	if pos >= s.size {
+		return false
+	}
+	c := s.chunks[pos/chunkBits]
+	return c != nil && c.get(uint16(pos))
+}
+
+// Count returns the number of bits set, which is kept as bits are set.
+func (s *Sparse) Count() uint {
+	return uint(s.count)
+}
+
+func (s *Sparse) Size() uint64 {
+	return s.size
+}
+
+// Data returns the bits as dense words, as BitSet.Data does. The words are
+// a copy: writing to them does not change the sparse bitset.
+func (s *Sparse) Data() []uint64 {
+	data := make([]uint64, (s.size+63)/64)
+	for i, c := range s.chunks {
+		if c != nil {
+			c.fill(data[i*chunkWords : min((i+1)*chunkWords, len(data))])
+		}
+	}
+	return data
+}
+
+// Clear unsets every bit.
+func (s *Sparse) Clear() {
+	clear(s.chunks)
+	s.count = 0
+}
+
+// MemoryUsage returns the approximate memory held by the bitset in bytes.
+func (s *Sparse) MemoryUsage() int {
+	n := len(s.chunks) * 8
+	for _, c := range s.chunks {
+		if c != nil {
+			n += cap(c.array)*2 + cap(c.bitmap)*8
+		}
+	}
+	return n
+}
+
+// All returns an iterator over the positions of the bits set, in
+// increasing order. The bitset must not be modified during iteration.
+func (s *Sparse) All() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		for i, c := range s.chunks {
+			if c == nil {
+				continue
+			}
+			base := uint64(i) * chunkBits
+			if c.bitmap == nil {
+				for _, off := range c.array {
+					if !yield(base + uint64(off)) {
+						return
+					}
+				}
+				continue
+			}
+			for j, w := range c.bitmap {
+				for w != 0 {
+					if !yield(base + uint64(j)*64 + uint64(bits.TrailingZeros64(w))) {
+						return
+					}
+					w &= w - 1
+				}
+			}
+		}
+	}
+}
+
+// Or sets every bit that is set in other, the union of both bitsets.
+// Bits of other beyond the size of s are ignored.
+func (s *Sparse) Or(other *Sparse) {
+	for i, oc := range other.chunks[:min(len(s.chunks), len(other.chunks))] {
+		if oc == nil {
+			continue
+		}
+		c := s.chunks[i]
+		if c == nil {
+			c = &container{}
+			s.chunks[i] = c
+		}
+		before := c.n
+		c.or(oc)
+		s.count += uint64(c.n - before)
+	}
+	s.trim()
+}
+
+// And unsets every bit that is not set in other, leaving the intersection
+// of both bitsets.
+func (s *Sparse) And(other *Sparse) {
+	for i, c := range s.chunks {
+		if c == nil {
+			continue
+		}
+		before := c.n
+		if i >= len(other.chunks) || other.chunks[i] == nil {
+			s.chunks[i] = nil
+			s.count -= uint64(before)
+			continue
+		}
+		c.and(other.chunks[i])
+		s.count -= uint64(before - c.n)
+		if c.n == 0 {
+			s.chunks[i] = nil
+		}
+	}
+}
+
+// trim unsets the bits beyond the size in the last chunk, which Or may
+// have copied from a larger bitset.
+func (s *Sparse) trim() {
+	last := len(s.chunks) - 1
+	if last < 0 || s.size%chunkBits == 0 || s.chunks[last] == nil {
+		return
+	}
+	c := s.chunks[last]
+	limit := int(s.size % chunkBits)
+	for off := limit; off < chunkBits; off++ {
+		if c.get(uint16(off)) {
+			c.unset(uint16(off))
+			s.count--
+		}
+	}
+}
+
+// set sets bit off and reports whether it was unset.
+func (c *container) set(off uint16) bool {
+	if c.bitmap != nil {
+		w, b := off/64, off%64
+		if c.bitmap[w]&(1<<b) != 0 {
+			return false
+		}
+		c.bitmap[w] |= 1 << b
+		c.n++
+		return true
+	}
+
+	i, found := slices.BinarySearch(c.array, off)
+	if found {
+		return false
+	}
+	c.array = slices.Insert(c.array, i, off)
+	c.n++
+	if c.n > arrayMax {
+		c.toBitmap()
+	}
+	return true
+}
+
+func (c *container) unset(off uint16) {
+	if c.bitmap != nil {
+		c.bitmap[off/64] &^= 1 << (off % 64)
+	} else if i, found := slices.BinarySearch(c.array, off); found {
+		c.array = slices.Delete(c.array, i, i+1)
+	}
+	c.n--
+}
+
+func (c *container) get(off uint16) bool {
+	if c.bitmap != nil {
+		return c.bitmap[off/64]&(1<<(off%64)) != 0
+	}
+	_, found := slices.BinarySearch(c.array, off)
+	return found
+}
+
+// fill ORs the container's bits into dense words.
+func (c *container) fill(words []uint64) {
+	if c.bitmap != nil {
+		for i := range words {
+			words[i] |= c.bitmap[i]
+		}
+		return
+	}
+	for _, off := range c.array {
+		words[off/64] |= 1 << (off % 64)
+	}
+}
+
+func (c *container) toBitmap() {
+	bitmap := make([]uint64, chunkWords)
+	c.fill(bitmap)
+	c.bitmap, c.array = bitmap, nil
+}
+
+func (c *container) toArray() {
+	c.array = make([]uint16, 0, c.n)
+	for i, w := range c.bitmap {
+		for w != 0 {
+			c.array = append(c.array, uint16(i*64+bits.TrailingZeros64(w)))
+			w &= w - 1
+		}
+	}
+	c.bitmap = nil
+}
+
+func (c *container) or(other *container) {
+	if c.bitmap == nil && other.bitmap == nil {
+		merged := make([]uint16, 0, len(c.array)+len(other.array))
+		i, j := 0, 0
+		for i < len(c.array) && j < len(other.array) {
+			switch a, b := c.array[i], other.array[j]; {
+			case a < b:
+				merged = append(merged, a)
+				i++
+			case a > b:
+				merged = append(merged, b)
+				j++
+			default:
+				merged = append(merged, a)
+				i++
+				j++
+			}
+		}
+		merged = append(merged, c.array[i:]...)
+		c.array = append(merged, other.array[j:]...)
+		c.n = len(c.array)
+		if c.n > arrayMax {
+			c.toBitmap()
+		}
+		return
+	}
+
+	if c.bitmap == nil {
+		c.toBitmap()
+	}
+	other.fill(c.bitmap)
+	c.n = 0
+	for _, w := range c.bitmap {
+		c.n += bits.OnesCount64(w)
+	}
+}
+
+func (c *container) and(other *container) {
+	switch {
+	case c.bitmap == nil:
+		kept := c.array[:0]
+		for _, off := range c.array {
+			if other.get(off) {
+				kept = append(kept, off)
+			}
+		}
+		c.array = kept
+		c.n = len(kept)
+	case other.bitmap == nil:
+		c.array = make([]uint16, 0, other.n)
+		for _, off := range other.array {
+			if c.get(off) {
+				c.array = append(c.array, off)
+			}
+		}
+		c.bitmap = nil
+		c.n = len(c.array)
+	default:
+		c.n = 0
+		for i := range c.bitmap {
+			c.bitmap[i] &= other.bitmap[i]
+			c.n += bits.OnesCount64(c.bitmap[i])
+		}
+		if c.n <= arrayMax {
+			c.toArray()
+		}
+	}
+}
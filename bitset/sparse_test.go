@@ -0,0 +1,139 @@
+package bitset
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// randomSparse returns a sparse bitset of size bits with n random bits
+// set, and the same bits in a dense bitset.
+func randomSparse(r *rand.Rand, size uint64, n int) (*Sparse, *BitSet) {
+	s, d := NewSparse(size), New(size)
+	for range n {
+		pos := r.Uint64N(size)
+		s.Set(pos)
+		d.Set(pos)
+	}
+	return s, d
+}
+
+// checkSparse checks that s holds the same bits as d.
+func checkSparse(t *testing.T, s *Sparse, d *BitSet) {
+	t.Helper()
+	if s.Count() != d.Count() {
+		t.Errorf("Expected %d bits set, got %d", d.Count(), s.Count())
+	}
+	if !slices.Equal(s.Data(), d.Data()) {
+		t.Error("Expected the sparse bitset's words to match the dense ones")
+	}
+}
+
+func TestSparse_SetAndGet(t *testing.T) {
+	s := NewSparse(200000)
+	for _, pos := range []uint64{0, 63, 65535, 65536, 199999} {
+		s.Set(pos)
+		s.Set(pos)
+	}
+	s.Set(200000) // out of range, ignored
+
+	for _, pos := range []uint64{0, 63, 65535, 65536, 199999} {
+		if !s.Get(pos) {
+			t.Errorf("Bit %d should be set", pos)
+		}
+	}
+	for _, pos := range []uint64{1, 65537, 200000, 1 << 40} {
+		if s.Get(pos) {
+			t.Errorf("Bit %d should not be set", pos)
+		}
+	}
+	if s.Count() != 5 || s.Size() != 200000 {
+		t.Errorf("Expected 5 of 200000 bits set, got %d of %d", s.Count(), s.Size())
+	}
+}
+
+func TestSparse_MatchesDense(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	// Few bits stay in arrays; many convert chunks to bitmaps.
+	for _, n := range []int{10, 1000, 20000} {
+		s, d := randomSparse(r, 300000, n)
+		checkSparse(t, s, d)
+
+		var got []uint64
+		for pos := range s.All() {
+			got = append(got, pos)
+		}
+		if uint(len(got)) != d.Count() || !slices.IsSorted(got) {
+			t.Fatalf("Expected %d positions in increasing order, got %d", d.Count(), len(got))
+		}
+		for _, pos := range got {
+			if !d.Get(pos) {
+				t.Fatalf("Iterated bit %d is not set", pos)
+			}
+		}
+	}
+}
+
+func TestSparse_Compression(t *testing.T) {
+	s := NewSparse(1 << 24)
+	for i := range uint64(1000) {
+		s.Set(i * 16000)
+	}
+	if dense := (1 << 24) / 8; s.MemoryUsage() > dense/50 {
+		t.Errorf("Expected well under %d bytes for 1000 bits, got %d", dense/50, s.MemoryUsage())
+	}
+
+	// A full chunk takes a bitmap, not an array.
+	full := NewSparse(1 << 16)
+	for i := range uint64(1 << 16) {
+		full.Set(i)
+	}
+	if full.MemoryUsage() > 9000 || full.Count() != 1<<16 {
+		t.Errorf("Expected a full chunk in about 8 KiB, got %d bytes and %d bits", full.MemoryUsage(), full.Count())
+	}
+}
+
+func TestSparse_OrAnd(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, n := range [][2]int{{100, 200}, {100, 10000}, {10000, 10000}} {
+		a, da := randomSparse(r, 200000, n[0])
+		b, db := randomSparse(r, 200000, n[1])
+
+		union, inter := New(200000), New(200000)
+		for i := range da.Data() {
+			union.Data()[i] = da.Data()[i] | db.Data()[i]
+			inter.Data()[i] = da.Data()[i] & db.Data()[i]
+		}
+
+		u := NewSparse(200000)
+		u.Or(a)
+		u.Or(b)
+		checkSparse(t, u, union)
+
+		a.And(b)
+		checkSparse(t, a, inter)
+		b.And(NewSparse(200000))
+		if b.Count() != 0 || b.MemoryUsage() != 4*8 {
+			t.Errorf("Expected an empty intersection to free its chunks, got %d bits in %d bytes", b.Count(), b.MemoryUsage())
+		}
+	}
+}
+
+func TestSparse_OrLarger(t *testing.T) {
+	small, large := NewSparse(100), NewSparse(1000)
+	large.Set(5)
+	large.Set(500)
+	small.Or(large)
+	if !small.Get(5) || small.Count() != 1 || small.Data()[1] != 0 {
+		t.Errorf("Expected bits beyond the size to be dropped, got %d bits", small.Count())
+	}
+}
+
+func TestSparse_Clear(t *testing.T) {
+	s := NewSparse(1000)
+	s.Set(10)
+	s.Clear()
+	if s.Count() != 0 || s.Get(10) {
+		t.Error("Expected no bits set after Clear")
+	}
+}
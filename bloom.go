@@ -41,10 +41,12 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
 
-	"github.com/umang-sinha/bitbloom/internal/bitset"
 	"github.com/umang-sinha/bitbloom/internal/hasher"
+	"github.com/umang-sinha/bitbloom/storage"
 )
 
 // OptimalM calculates the optimal size of the bit array (m) given the expected number
@@ -68,12 +70,28 @@ func OptimalK(m, n uint64) uint64 {
 // BloomFilter represents a Bloom filter instance.
 // It is safe for concurrent use by multiple goroutines.
 type BloomFilter struct {
-	bitset *bitset.BitSet
-	hasher hasher.Hasher
-	mutex  sync.RWMutex
-	m      uint64
-	k      uint64
-	count  uint64
+	storage storage.Storage
+	hasher  hasher.Hasher
+	scheme  hasher.Scheme
+	probe   probe
+	mutex   sync.RWMutex
+	m       uint64
+	k       uint64
+	count   uint64
+
+	// epoch identifies the change history returned by Delta. It is chosen
+	// when tracking starts and is zero until then, or once the storage it
+	// tracked is replaced.
+	epoch uint64
+	// applied is the version of the last delta applied by ApplyDelta, and
+	// appliedEpoch the epoch of its source.
+	applied      uint64
+	appliedEpoch uint64
+
+	// adds and tests count calls to Add and Test. Test only holds the read
+	// lock, so both are updated atomically.
+	adds  atomic.Uint64
+	tests atomic.Uint64
 }
 
 // New creates and returns a new Bloom filter optimized for storing up to `n` items
@@ -81,6 +99,15 @@ type BloomFilter struct {
 //
 // It returns an error if the probability is not in the range (0,1).
 //
+// Locations are derived from murmur3 with enhanced double hashing and
+// reduced to the bit array by multiply-shift rather than a division. The
+// probing is recorded by MarshalBinary, and filters written before it
+// existed decode with their original modulo probing.
+//
+// A filter of 2^20 bits or more starts in a compressed form that takes
+// memory in proportion to the bits set, and moves to a plain bit array once
+// more than 1/64 of its bits are set; see Stats.Sparse. Densify opts out.
+//
 // Example:
 //
 //	bf, err := bitbloom.New(10000, 0.01)
@@ -93,24 +120,79 @@ func New(n uint64, p float64) (*BloomFilter, error) {
 
 	m := OptimalM(n, p)
 	k := OptimalK(m, n)
-	return newBloomFilter(m, k), nil
+	return NewWithParams(m, k), nil
 }
 
 // NewWithParams creates and returns a Bloom filter with explicit control over
 // the size of the bit array (`m`) and number of hash functions (`k`).
 //
 // This should be used only if you need precise control over internals.
-// For most users, the New() constructor is recommended.
+// For most users, the New() constructor is recommended. The filter probes
+// its bits as New's do.
 func NewWithParams(m, k uint64) *BloomFilter {
-	return newBloomFilter(m, k)
+	bf := newBloomFilter(m, k)
+	bf.setProbe(defaultProbe)
+	bf.useSparse()
+	return bf
+}
+
+// NewWithStorage creates a Bloom filter with `k` hash functions over an
+// existing bit storage, such as a memory-mapped or paged file, whose length
+// is the size of the bit array. Add and Test work as for any other filter.
+//
+// As a storage does not record the number of items added, the count of a
+// filter over a storage that already has bits set is estimated from them.
+// The caller remains responsible for syncing and closing the storage.
+//
+// Since the storage holds no header to record the probing, the filter
+// uses the original modulo probing, so files written by earlier versions
+// keep their meaning.
+func NewWithStorage(s storage.Storage, k uint64) *BloomFilter {
+	bf := &BloomFilter{
+		storage: s,
+		hasher:  hasher.New(),
+		scheme:  hasher.SchemeMurmur,
+		m:       s.Len(),
+		k:       k,
+	}
+	if setBits := s.Count(); setBits > 0 {
+		bf.count = uint64(estimateCardinality(bf.m, k, setBits) + 0.5)
+	}
+	return bf
 }
 
 func newBloomFilter(m, k uint64) *BloomFilter {
 	return &BloomFilter{
-		bitset: bitset.New(m),
-		hasher: hasher.New(),
-		m:      m,
-		k:      k,
+		storage: storage.NewHeap(m),
+		hasher:  hasher.New(),
+		scheme:  hasher.SchemeMurmur,
+		m:       m,
+		k:       k,
+	}
+}
+
+// newBloomFilterWithScheme creates a filter that hashes items with the
+// given scheme. The scheme must be known to hasher.ForScheme.
+func newBloomFilterWithScheme(m, k uint64, scheme hasher.Scheme) (*BloomFilter, error) {
+	h, err := hasher.ForScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	bf := newBloomFilter(m, k)
+	bf.hasher = h
+	bf.scheme = scheme
+	return bf, nil
+}
+
+// setProbe sets the filter's probing, replacing its hasher to match. It is
+// called before the filter is shared.
+func (bf *BloomFilter) setProbe(p probe) {
+	bf.probe = p
+	if p&^probePartitioned != 0 {
+		bf.hasher = p.probing()
+	}
+	if p&probePartitioned != 0 {
+		bf.hasher = hasher.Partitioned{Hasher: bf.hasher}
 	}
 }
 
@@ -121,10 +203,42 @@ func (bf *BloomFilter) Add(item []byte) {
 
 	hashes := bf.hasher.Hashes(item, bf.k, bf.m)
 	for _, h := range hashes {
-		bf.bitset.Set(h)
+		bf.storage.SetBit(h)
+	}
+	bf.densify()
+
+	bf.count++
+	bf.adds.Add(1)
+}
+
+// addDigest inserts an item given its digest under the filter's scheme,
+// as computed by hasher.Scheme.Sum.
+func (bf *BloomFilter) addDigest(d hasher.Digest) {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	for i := range bf.k {
+		bf.storage.SetBit(bf.probe.location(bf.scheme, d, i, bf.m, bf.k))
 	}
+	bf.densify()
 
 	bf.count++
+	bf.adds.Add(1)
+}
+
+// testDigest checks whether an item is possibly in the filter given its
+// digest under the filter's scheme.
+func (bf *BloomFilter) testDigest(d hasher.Digest) bool {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	bf.tests.Add(1)
+	for i := range bf.k {
+		if !bf.storage.GetBit(bf.probe.location(bf.scheme, d, i, bf.m, bf.k)) {
+			return false
+		}
+	}
+	return true
 }
 
 // Test checks whether an item is possibly in the Bloom filter.
@@ -134,15 +248,142 @@ func (bf *BloomFilter) Test(item []byte) bool {
 	bf.mutex.RLock()
 	defer bf.mutex.RUnlock()
 
+	bf.tests.Add(1)
 	hashes := bf.hasher.Hashes(item, bf.k, bf.m)
 	for _, h := range hashes {
-		if !bf.bitset.Get(h) {
+		if !bf.storage.GetBit(h) {
 			return false
 		}
 	}
 	return true
 }
 
+// TestAndAdd adds an item to the Bloom filter and reports whether it was
+// possibly present before the call. It is equivalent to calling Test and
+// then Add, but performed atomically under a single lock.
+func (bf *BloomFilter) TestAndAdd(item []byte) bool {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	present := true
+	hashes := bf.hasher.Hashes(item, bf.k, bf.m)
+	for _, h := range hashes {
+		if !bf.storage.GetBit(h) {
+			present = false
+			bf.storage.SetBit(h)
+		}
+	}
+	bf.densify()
+
+	bf.count++
+	bf.adds.Add(1)
+	bf.tests.Add(1)
+	return present
+}
+
+// Merge adds every item of other into bf by OR-ing their bit arrays.
+// Both filters must have been created with the same m, k and hashing scheme.
+//
+// After merging, bf reports a positive Test for every item that either
+// filter did, and its count is the sum of both counts.
+func (bf *BloomFilter) Merge(other *BloomFilter) error {
+	other.mutex.RLock()
+	m, k, count, scheme, probe := other.m, other.k, other.count, other.scheme, other.probe
+	words := append([]uint64(nil), other.storage.Words()...)
+	other.mutex.RUnlock()
+
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	if bf.probe != probe {
+		return fmt.Errorf("cannot merge filters with different probing: %#x vs %#x", uint8(bf.probe), uint8(probe))
+	}
+	if bf.m != m || bf.k != k {
+		return fmt.Errorf("cannot merge filters with different parameters: m=%d k=%d vs m=%d k=%d",
+			bf.m, bf.k, m, k)
+	}
+	if bf.scheme != scheme {
+		return fmt.Errorf("cannot merge filters with different hashing schemes: %s vs %s",
+			bf.scheme, scheme)
+	}
+
+	if heap, ok := bf.storage.(*storage.Heap); ok {
+		for i, w := range words {
+			heap.Or(i, w)
+		}
+	} else {
+		setWords(bf.storage, words)
+		bf.densify()
+	}
+	bf.count += count
+	return nil
+}
+
+// Clone returns a deep copy of the filter with the same parameters,
+// hashing scheme, bits and count, held in memory whatever the filter's
+// storage. The copy is not a replica at any version, and its Adds and
+// Tests counters start from zero.
+func (bf *BloomFilter) Clone() *BloomFilter {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	words := append([]uint64(nil), bf.storage.Words()...)
+	heap, _ := storage.NewHeapFromWords(words, bf.m)
+	return &BloomFilter{
+		storage: heap,
+		hasher:  bf.hasher,
+		scheme:  bf.scheme,
+		probe:   bf.probe,
+		m:       bf.m,
+		k:       bf.k,
+		count:   bf.count,
+	}
+}
+
+// Reset removes every item from the filter, unsetting all bits and
+// zeroing the count, while keeping its parameters.
+//
+// Deltas cannot express removed bits, so replicas of a filter that is
+// reset must be rebuilt: Delta rejects their versions with ErrDeltaGap, and
+// each replica should be reset, which returns it to version 0, and then
+// apply Delta(0).
+func (bf *BloomFilter) Reset() {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	bf.storage.Clear()
+	bf.count = 0
+	bf.applied = 0
+}
+
+// Equal reports whether bf and other have the same m, k, hashing scheme,
+// layout and bits, and so answer every Test alike. Counts are not compared, since
+// filters holding the same items may have counted duplicates differently.
+func (bf *BloomFilter) Equal(other *BloomFilter) bool {
+	if bf == other {
+		return true
+	}
+
+	other.mutex.RLock()
+	m, k, scheme, probe := other.m, other.k, other.scheme, other.probe
+	words := append([]uint64(nil), other.storage.Words()...)
+	other.mutex.RUnlock()
+
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	return bf.m == m && bf.k == k && bf.scheme == scheme && bf.probe == probe &&
+		slices.Equal(bf.storage.Words(), words)
+}
+
+// setWords sets every bit that is set in words, for storages whose Words
+// may return a copy.
+func setWords(s storage.Storage, words []uint64) {
+	for i, w := range words {
+		orWord(s, uint64(i), w)
+	}
+}
+
 // EstimatedFillRatio returns the theoretical fill ratio of the bit array
 // based on the number of inserted elements and the number of hash functions.
 func (bf *BloomFilter) EstimatedFillRatio() float64 {
@@ -158,7 +399,7 @@ func (bf *BloomFilter) ActualFillRatio() float64 {
 	bf.mutex.RLock()
 	defer bf.mutex.RUnlock()
 
-	setBits := bf.bitset.Count()
+	setBits := bf.storage.Count()
 	return float64(setBits) / float64(bf.m)
 }
 
@@ -168,18 +409,30 @@ func (bf *BloomFilter) FalsePositiveRate() float64 {
 	bf.mutex.RLock()
 	defer bf.mutex.RUnlock()
 
+	if bf.probe&probePartitioned != 0 {
+		return partitionedFPR(bf.storage.Words(), bf.m, bf.k)
+	}
+
 	// (1 - e^(-k*n/m))^k ≈ (fillRatio)^k
-	fillRatio := float64(bf.bitset.Count()) / float64(bf.m)
+	fillRatio := float64(bf.storage.Count()) / float64(bf.m)
 	return math.Pow(fillRatio, float64(bf.k))
 }
 
 // MemoryUsage returns the total memory used by the bit array in bytes.
+// For a large filter that has few bits set, and so is still held in
+// compressed form, this is less than the size of the bit array.
 func (bf *BloomFilter) MemoryUsage() int {
 	bf.mutex.RLock()
 	defer bf.mutex.RUnlock()
 
-	words := (bf.m + 63) / 64
-	return int(words) * 8
+	return bf.memoryUsage()
+}
+
+func (bf *BloomFilter) memoryUsage() int {
+	if s, ok := bf.storage.(*storage.Sparse); ok {
+		return s.MemoryUsage()
+	}
+	return int((bf.m+63)/64) * 8
 }
 
 // MarshalBinary serializes the Bloom filter into a binary representation.
@@ -189,10 +442,16 @@ func (bf *BloomFilter) MemoryUsage() int {
 //	Offset  Size (bytes)  Description
 //	------  ------------- ----------------------------------------------
 //	0       8             m: total number of bits in the filter
-//	8       8             k: number of hash functions used
+//	8       8             k: number of hash functions used (low 48 bits),
+//	                      probing (next 8 bits) and hashing scheme
+//	                      (high 8 bits)
 //	16      8             count: number of items added
 //	24      8 * w         bitset data (w = ceil(m / 64)) 64-bit words
 //
+// Filters written before the hashing scheme and probing were recorded have
+// zero high bytes, which are the original murmur3 scheme and the classic
+// layout with (h1 + i*h2) mod m probing.
+//
 // This binary encoding allows you to store or transmit the filter and
 // restore it later using UnmarshalBinary. It is safe for cross-platform
 // use as long as both sides use little-endian encoding.
@@ -208,23 +467,27 @@ func (bf *BloomFilter) MemoryUsage() int {
 //
 // Returns a byte slice and any error encountered.
 func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
+	return bf.AppendBinary(nil)
+}
+
+// AppendBinary appends the encoding of MarshalBinary to b, implementing
+// encoding.BinaryAppender.
+func (bf *BloomFilter) AppendBinary(b []byte) ([]byte, error) {
 	bf.mutex.RLock()
 	defer bf.mutex.RUnlock()
 
-	words := (bf.m + 63) / 64
-	buf := make([]byte, 24+words*8)
-
-	binary.LittleEndian.PutUint64(buf[0:8], bf.m)
-	binary.LittleEndian.PutUint64(buf[8:16], bf.k)
-	binary.LittleEndian.PutUint64(buf[16:24], bf.count)
-
-	bitsetData := bf.bitset.Data()
-	for i, word := range bitsetData {
-		offset := 24 + i*8
-		binary.LittleEndian.PutUint64(buf[offset:offset+8], word)
+	words := bf.storage.Words()
+	if uint64(len(words)) != (bf.m+63)/64 {
+		return nil, fmt.Errorf("reading bitset: got %d of %d words", len(words), (bf.m+63)/64)
 	}
+	start := len(b)
+	b = slices.Grow(b, headerSize+len(words)*8)[:start+headerSize]
+	putHeader(b[start:], header{m: bf.m, k: bf.k, count: bf.count, scheme: bf.scheme, probe: bf.probe})
 
-	return buf, nil
+	for _, word := range words {
+		b = binary.LittleEndian.AppendUint64(b, word)
+	}
+	return b, nil
 }
 
 // UnmarshalBinary reconstructs a Bloom filter from its binary representation.
@@ -237,17 +500,25 @@ func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
 //	Offset  Size (bytes)  Description
 //	------  ------------- ----------------------------------------------
 //	0       8             m: total number of bits in the filter
-//	8       8             k: number of hash functions used
+//	8       8             k: number of hash functions used (low 48 bits),
+//	                      probing (next 8 bits) and hashing scheme
+//	                      (high 8 bits)
 //	16      8             count: number of items added
 //
 // The remaining bytes must be the bitset data:
 //
 //	24      8 * w         bitset data (w = ceil(m / 64)) 64-bit words
 //
-// Validations performed:
+// Validations performed, before anything is allocated:
 //   - Ensures `m` and `k` are non-zero
+//   - Ensures `k` is at most DefaultMaxK
+//   - Ensures the hashing scheme and probing are known, and that the
+//     probing suits the scheme and m
 //   - Ensures bitset data length matches expected word count
-//   - Ensures bitset words are parsed correctly
+//
+// Errors wrap the sentinel errors of UnmarshalOptions. Use
+// UnmarshalOptions to bound the size of the filter or to reject
+// non-canonical encodings of untrusted input.
 //
 // Example:
 //
@@ -258,36 +529,148 @@ func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
 //
 // Returns a new BloomFilter or an error if the data is invalid.
 func UnmarshalBinary(data []byte) (*BloomFilter, error) {
-	const headerSize = 24
-	if len(data) < headerSize {
-		return nil, fmt.Errorf("data too short for header")
-	}
-
-	m := binary.LittleEndian.Uint64(data[0:8])
-	k := binary.LittleEndian.Uint64(data[8:16])
-	count := binary.LittleEndian.Uint64(data[16:24])
+	return UnmarshalOptions{}.Unmarshal(data)
+}
 
-	if m == 0 || k == 0 {
-		return nil, fmt.Errorf("invalid parameters in serialized data")
+// Unmarshal decodes a filter in the format produced by MarshalBinary,
+// validating it against the options before allocating.
+func (o UnmarshalOptions) Unmarshal(data []byte) (*BloomFilter, error) {
+	h, bitset, err := o.decodeHeader(data)
+	if err != nil {
+		return nil, err
 	}
 
-	bf := newBloomFilter(m, k)
-	bf.count = count
-
-	expectedWords := (m + 63) / 64
-	actualWords := uint64(len(data[headerSize:])) / 8
-	if actualWords != expectedWords {
-		return nil, fmt.Errorf("bitset data length mismatch")
+	bf, err := newBloomFilterWithScheme(h.m, h.k, h.scheme)
+	if err != nil {
+		return nil, err
 	}
+	bf.setProbe(h.probe)
+	bf.count = h.count
 
-	words := make([]uint64, expectedWords)
+	words := make([]uint64, len(bitset)/8)
 	for i := range words {
-		words[i] = binary.LittleEndian.Uint64(data[headerSize+i*8:])
+		words[i] = binary.LittleEndian.Uint64(bitset[i*8:])
 	}
 
-	if err := bf.bitset.SetData(words); err != nil {
+	heap, err := storage.NewHeapFromWords(words, h.m)
+	if err != nil {
 		return nil, fmt.Errorf("invalid bitset data: %w", err)
 	}
+	bf.storage = heap
 
 	return bf, nil
 }
+
+const headerSize = 24
+
+// header is the fixed-size prefix of the MarshalBinary format.
+type header struct {
+	m, k, count uint64
+	scheme      hasher.Scheme
+	probe       probe
+}
+
+func putHeader(buf []byte, h header) {
+	binary.LittleEndian.PutUint64(buf[0:8], h.m)
+	binary.LittleEndian.PutUint64(buf[8:16], packK(h.k, h.scheme, h.probe))
+	binary.LittleEndian.PutUint64(buf[16:24], h.count)
+}
+
+// probe records how an item's k bit locations are laid out in the bit
+// array. The zero value is the classic layout, in which all k locations
+// range over the whole array.
+type probe uint8
+
+const (
+	// probePartitioned splits the array into k slices of m/k bits and
+	// places the i-th location of an item in slice i.
+	probePartitioned probe = 1 << 0
+	// probeEnhanced selects enhanced double hashing.
+	probeEnhanced probe = 1 << 1
+	// probeReduction holds the hasher.Reduction that maps hashes onto the
+	// array, or onto a slice of a partitioned array. Zero is the modulo
+	// reduction of filters written before it was recorded.
+	probeReduction probe = 3 << probeReductionShift
+
+	probeReductionShift = 2
+
+	probeMultiplyShift = probe(hasher.ReduceMultiplyShift) << probeReductionShift
+	probeMask          = probe(hasher.ReduceMask) << probeReductionShift
+
+	knownProbes = probePartitioned | probeEnhanced | probeReduction
+
+	// defaultProbe is the probing of filters made by New and
+	// NewWithParams.
+	defaultProbe = probeEnhanced | probeMultiplyShift
+)
+
+// probing returns the hasher.Probing selected by p. It only applies to the
+// murmur3 scheme.
+func (p probe) probing() hasher.Probing {
+	return hasher.Probing{
+		Enhanced:  p&probeEnhanced != 0,
+		Reduction: hasher.Reduction(p & probeReduction >> probeReductionShift),
+	}
+}
+
+// probeFor returns the probe flags selecting hp.
+func probeFor(hp hasher.Probing) probe {
+	p := probe(hp.Reduction) << probeReductionShift
+	if hp.Enhanced {
+		p |= probeEnhanced
+	}
+	return p
+}
+
+// location returns the i-th bit location of an item with digest d in a
+// filter of m bits and k hash functions.
+func (p probe) location(scheme hasher.Scheme, d hasher.Digest, i, m, k uint64) uint64 {
+	var base uint64
+	if p&probePartitioned != 0 {
+		m /= k
+		base = i * m
+	}
+	if p&^probePartitioned != 0 {
+		return base + p.probing().Location(d, i, m)
+	}
+	return base + scheme.Location(d, i, m)
+}
+
+// checkProbe validates the probing of a header: its flags must be known, only
+// the murmur3 scheme can change its probing, and the reduction must suit
+// the size of the array or of its slices.
+func (h header) checkProbe() error {
+	if h.probe&^knownProbes != 0 || h.probe&probeReduction > probeMask {
+		return fmt.Errorf("%w: probing %#x", ErrUnknownScheme, uint8(h.probe))
+	}
+	if h.probe&^probePartitioned != 0 && h.scheme != hasher.SchemeMurmur {
+		return fmt.Errorf("%w: probing %s with the %s scheme", ErrUnknownScheme, h.probe.probing(), h.scheme)
+	}
+	size := h.m
+	if h.probe&probePartitioned != 0 {
+		if h.m%h.k != 0 {
+			return fmt.Errorf("%w: partitioned m = %d is not a multiple of k = %d", ErrInvalidParams, h.m, h.k)
+		}
+		size = h.m / h.k
+	}
+	if h.probe&probeReduction == probeMask && size&(size-1) != 0 {
+		return fmt.Errorf("%w: mask probing needs a power of two bits, got %d", ErrInvalidParams, size)
+	}
+	return nil
+}
+
+const (
+	schemeShift = 56
+	probeShift  = 48
+)
+
+// packK stores the hashing scheme and probing in the otherwise unused high
+// bytes of the serialized k, so filters written before either existed
+// still decode.
+func packK(k uint64, scheme hasher.Scheme, p probe) uint64 {
+	return k | uint64(p)<<probeShift | uint64(scheme)<<schemeShift
+}
+
+func unpackK(v uint64) (uint64, hasher.Scheme, probe) {
+	return v & (1<<probeShift - 1), hasher.Scheme(v >> schemeShift), probe(v >> probeShift)
+}
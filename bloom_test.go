@@ -2,10 +2,12 @@ package bitbloom
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/umang-sinha/bitbloom/storage"
 )
 
 func TestBloomFilter_AddAndTest(t *testing.T) {
@@ -400,3 +402,142 @@ func TestBloomFilter_ConcurrentUnmarshalAndTest(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestBloomFilter_Merge(t *testing.T) {
+	a := NewWithParams(1000, 3)
+	b := NewWithParams(1000, 3)
+	a.Add([]byte("foo"))
+	b.Add([]byte("bar"))
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !a.Test([]byte("foo")) || !a.Test([]byte("bar")) {
+		t.Error("Merged filter should contain items from both filters")
+	}
+	if a.count != 2 {
+		t.Errorf("Expected merged count 2, got %d", a.count)
+	}
+
+	if err := a.Merge(NewWithParams(2000, 3)); err == nil {
+		t.Error("Expected error when merging filters with different parameters")
+	}
+}
+
+func TestBloomFilter_TestAndAdd(t *testing.T) {
+	bf := NewWithParams(1000, 3)
+	if bf.TestAndAdd([]byte("foo")) {
+		t.Error("Expected foo to be absent before the first add")
+	}
+	if !bf.TestAndAdd([]byte("foo")) {
+		t.Error("Expected foo to be present after adding it")
+	}
+	if !bf.Test([]byte("foo")) {
+		t.Error("Expected foo to be present")
+	}
+}
+
+func TestBloomFilter_UnmarshalUnknownScheme(t *testing.T) {
+	bf := NewWithParams(64, 3)
+	data, _ := bf.MarshalBinary()
+	data[15] = 0xff // high byte of k holds the hashing scheme
+
+	if _, err := UnmarshalBinary(data); err == nil {
+		t.Error("Expected error for unknown hashing scheme")
+	}
+}
+
+func TestNewWithStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bits")
+	s, err := storage.OpenPaged(path, 10000, storage.PagedOptions{PageSize: 64, CachePages: 4})
+	if err != nil {
+		t.Fatalf("OpenPaged failed: %v", err)
+	}
+
+	bf := NewWithStorage(s, 7)
+	other := NewWithStorage(storage.NewHeap(10000), 7)
+	for i := range 100 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+		other.Add(fmt.Appendf(nil, "other-%d", i))
+	}
+	if err := bf.Merge(other); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, _ = storage.OpenPaged(path, 10000, storage.PagedOptions{PageSize: 64, CachePages: 4})
+	defer s.Close()
+	bf = NewWithStorage(s, 7)
+	for i := range 100 {
+		if !bf.Test(fmt.Appendf(nil, "item-%d", i)) || !bf.Test(fmt.Appendf(nil, "other-%d", i)) {
+			t.Fatalf("False negative for item %d after reopening", i)
+		}
+	}
+	if c := bf.Stats().Count; c < 180 || c > 220 {
+		t.Errorf("Expected an estimated count near 200, got %d", c)
+	}
+
+	// Serialization works from any storage.
+	data, _ := bf.MarshalBinary()
+	decoded, err := UnmarshalBinary(data)
+	if err != nil || !decoded.Test([]byte("item-0")) {
+		t.Errorf("Round trip from paged storage failed: %v", err)
+	}
+}
+
+func TestBloomFilter_Clone(t *testing.T) {
+	bf := NewBitsAndBlooms(1000, 5)
+	bf.Add([]byte("alice"))
+
+	clone := bf.Clone()
+	if !clone.Equal(bf) || clone.count != 1 || clone.scheme != bf.scheme {
+		t.Fatal("Expected the clone to equal the original")
+	}
+
+	clone.Add([]byte("bob"))
+	if bf.Test([]byte("bob")) {
+		t.Error("Expected changes to the clone not to affect the original")
+	}
+	bf.Add([]byte("carol"))
+	if clone.Test([]byte("carol")) {
+		t.Error("Expected changes to the original not to affect the clone")
+	}
+}
+
+func TestBloomFilter_Reset(t *testing.T) {
+	bf, _ := New(1000, 0.01)
+	bf.Add([]byte("alice"))
+	bf.Reset()
+
+	if bf.Test([]byte("alice")) || bf.storage.Count() != 0 || bf.count != 0 {
+		t.Error("Expected Reset to remove every item")
+	}
+	bf.Add([]byte("bob"))
+	if !bf.Test([]byte("bob")) {
+		t.Error("Expected the filter to be usable after Reset")
+	}
+}
+
+func TestBloomFilter_Equal(t *testing.T) {
+	a, _ := New(1000, 0.01)
+	b, _ := New(1000, 0.01)
+	a.Add([]byte("x"))
+	b.Add([]byte("x"))
+	b.Add([]byte("x"))
+
+	if !a.Equal(b) || !a.Equal(a) {
+		t.Error("Expected filters with the same bits to be equal regardless of count")
+	}
+	b.Add([]byte("y"))
+	if a.Equal(b) {
+		t.Error("Expected filters with different bits to differ")
+	}
+	if a.Equal(NewWithParams(a.m, a.k+1)) {
+		t.Error("Expected filters with different k to differ")
+	}
+	if NewWithParams(1000, 5).Equal(NewBitsAndBlooms(1000, 5)) {
+		t.Error("Expected filters with different schemes to differ")
+	}
+}
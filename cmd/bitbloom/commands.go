@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/umang-sinha/bitbloom"
+)
+
+func (e *env) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: bitbloom %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the command line flags and reports whether the command
+// should continue. code is the exit code to use otherwise.
+func parse(fs *flag.FlagSet, args []string) (ok bool, code int) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return false, exitOK
+		}
+		return false, exitError
+	}
+	return true, exitOK
+}
+
+// readKeys returns the keys given as arguments, or else the keys read one
+// per line from path, or from stdin if path is empty or "-". Blank lines are
+// ignored and a trailing carriage return is stripped from each line.
+func (e *env) readKeys(args []string, path string) ([][]byte, error) {
+	if len(args) > 0 {
+		keys := make([][]byte, len(args))
+		for i, arg := range args {
+			keys[i] = []byte(arg)
+		}
+		return keys, nil
+	}
+
+	var r io.Reader = e.stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var keys [][]byte
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		keys = append(keys, []byte(line))
+	}
+	return keys, sc.Err()
+}
+
+func (e *env) writeJSON(v any) int {
+	enc := json.NewEncoder(e.stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return e.errorf("%v", err)
+	}
+	return exitOK
+}
+
+func runCreate(e *env, args []string) int {
+	fs := e.flagSet("create", "")
+	n := fs.Uint64("n", 0, "expected number of items")
+	p := fs.Float64("p", 0.01, "target false positive rate (with -n)")
+	m := fs.Uint64("m", 0, "number of bits (with -k)")
+	k := fs.Uint64("k", 0, "number of hash functions (with -m)")
+	out := fs.String("o", "", "output file (required, - for stdout)")
+	formatName := fs.String("format", "binary", "output format: "+formatNames())
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	if *out == "" {
+		return e.errorf("create: -o is required")
+	}
+
+	var bf *bitbloom.BloomFilter
+	switch {
+	case *n > 0 && *m == 0 && *k == 0:
+		var err error
+		if bf, err = bitbloom.New(*n, *p); err != nil {
+			return e.errorf("create: %v", err)
+		}
+	case *n == 0 && *m > 0 && *k > 0:
+		bf = bitbloom.NewWithParams(*m, *k)
+	default:
+		return e.errorf("create: specify either -n (and -p) or both -m and -k")
+	}
+
+	if err := e.writeFilter(*out, *formatName, bf); err != nil {
+		return e.errorf("create: %v", err)
+	}
+	return exitOK
+}
+
+func runAdd(e *env, args []string) int {
+	fs := e.flagSet("add", "[key ...]")
+	path := fs.String("f", "", "filter file (required)")
+	input := fs.String("i", "", "file with one key per line (default stdin)")
+	formatName := fs.String("format", "binary", "filter format: "+formatNames())
+	jsonOut := fs.Bool("json", false, "print the result as JSON")
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	if *path == "" {
+		return e.errorf("add: -f is required")
+	}
+	bf, err := e.readFilter(*path, *formatName)
+	if err != nil {
+		return e.errorf("add: %v", err)
+	}
+	keys, err := e.readKeys(fs.Args(), *input)
+	if err != nil {
+		return e.errorf("add: reading keys: %v", err)
+	}
+
+	for _, key := range keys {
+		bf.Add(key)
+	}
+	if err := e.writeFilter(*path, *formatName, bf); err != nil {
+		return e.errorf("add: %v", err)
+	}
+
+	if *jsonOut {
+		return e.writeJSON(struct {
+			Added int `json:"added"`
+		}{len(keys)})
+	}
+	return exitOK
+}
+
+type testResult struct {
+	Key     string `json:"key"`
+	Present bool   `json:"present"`
+}
+
+func runTest(e *env, args []string) int {
+	fs := e.flagSet("test", "[key ...]")
+	path := fs.String("f", "", "filter file (required)")
+	input := fs.String("i", "", "file with one key per line (default stdin)")
+	formatName := fs.String("format", "binary", "filter format: "+formatNames())
+	jsonOut := fs.Bool("json", false, "print the results as JSON")
+	quiet := fs.Bool("q", false, "print nothing; report the result only through the exit code")
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	if *path == "" {
+		return e.errorf("test: -f is required")
+	}
+	bf, err := e.readFilter(*path, *formatName)
+	if err != nil {
+		return e.errorf("test: %v", err)
+	}
+	keys, err := e.readKeys(fs.Args(), *input)
+	if err != nil {
+		return e.errorf("test: reading keys: %v", err)
+	}
+
+	allPresent := true
+	results := make([]testResult, len(keys))
+	for i, key := range keys {
+		results[i] = testResult{Key: string(key), Present: bf.Test(key)}
+		allPresent = allPresent && results[i].Present
+	}
+
+	code := exitOK
+	if !allPresent {
+		code = exitAbsent
+	}
+
+	switch {
+	case *quiet:
+	case *jsonOut:
+		if e.writeJSON(struct {
+			AllPresent bool         `json:"all_present"`
+			Results    []testResult `json:"results"`
+		}{allPresent, results}) != exitOK {
+			return exitError
+		}
+	default:
+		w := bufio.NewWriter(e.stdout)
+		for _, r := range results {
+			fmt.Fprintf(w, "%t\t%s\n", r.Present, r.Key)
+		}
+		if err := w.Flush(); err != nil {
+			return e.errorf("test: %v", err)
+		}
+	}
+	return code
+}
+
+func runInfo(e *env, args []string) int {
+	fs := e.flagSet("info", "")
+	path := fs.String("f", "", "filter file (required, - for stdin)")
+	formatName := fs.String("format", "binary", "filter format: "+formatNames())
+	jsonOut := fs.Bool("json", false, "print the statistics as JSON")
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	if *path == "" {
+		return e.errorf("info: -f is required")
+	}
+	bf, err := e.readFilter(*path, *formatName)
+	if err != nil {
+		return e.errorf("info: %v", err)
+	}
+
+	s := bf.Stats()
+	if *jsonOut {
+		return e.writeJSON(s)
+	}
+
+	w := bufio.NewWriter(e.stdout)
+	fmt.Fprintf(w, "m:                    %d\n", s.M)
+	fmt.Fprintf(w, "k:                    %d\n", s.K)
+	fmt.Fprintf(w, "count:                %d\n", s.Count)
+	fmt.Fprintf(w, "set bits:             %d\n", s.SetBits)
+	fmt.Fprintf(w, "estimated count:      %.0f\n", s.EstimatedCount)
+	fmt.Fprintf(w, "estimated fill ratio: %.6f\n", s.EstimatedFillRatio)
+	fmt.Fprintf(w, "actual fill ratio:    %.6f\n", s.ActualFillRatio)
+	fmt.Fprintf(w, "false positive rate:  %.6g\n", s.FalsePositiveRate)
+	fmt.Fprintf(w, "memory usage:         %d bytes\n", s.MemoryUsage)
+	if err := w.Flush(); err != nil {
+		return e.errorf("info: %v", err)
+	}
+	return exitOK
+}
+
+func runMerge(e *env, args []string) int {
+	fs := e.flagSet("merge", "filter1 filter2 [filter ...]")
+	out := fs.String("o", "", "output file (required, - for stdout)")
+	formatName := fs.String("format", "binary", "format of the input and output filters: "+formatNames())
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	if *out == "" {
+		return e.errorf("merge: -o is required")
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	merged, err := e.readFilter(fs.Arg(0), *formatName)
+	if err != nil {
+		return e.errorf("merge: %v", err)
+	}
+	for _, path := range fs.Args()[1:] {
+		bf, err := e.readFilter(path, *formatName)
+		if err != nil {
+			return e.errorf("merge: %v", err)
+		}
+		if err := merged.Merge(bf); err != nil {
+			return e.errorf("merge: %s: %v", path, err)
+		}
+	}
+
+	if err := e.writeFilter(*out, *formatName, merged); err != nil {
+		return e.errorf("merge: %v", err)
+	}
+	return exitOK
+}
+
+func runConvert(e *env, args []string) int {
+	fs := e.flagSet("convert", "")
+	input := fs.String("i", "-", "input file (- for stdin)")
+	out := fs.String("o", "-", "output file (- for stdout)")
+	from := fs.String("from", "binary", "input format: "+formatNames())
+	to := fs.String("to", "base64", "output format: "+formatNames())
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	bf, err := e.readFilter(*input, *from)
+	if err != nil {
+		return e.errorf("convert: %v", err)
+	}
+	if err := e.writeFilter(*out, *to, bf); err != nil {
+		return e.errorf("convert: %v", err)
+	}
+	return exitOK
+}
+
+type verifyResult struct {
+	OK      bool     `json:"ok"`
+	Checked int      `json:"checked"`
+	Missing []string `json:"missing"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func runVerify(e *env, args []string) int {
+	fs := e.flagSet("verify", "")
+	path := fs.String("f", "", "filter file (required)")
+	keysPath := fs.String("keys", "", "file with keys that must all be present (- for stdin)")
+	formatName := fs.String("format", "binary", "filter format: "+formatNames())
+	jsonOut := fs.Bool("json", false, "print the result as JSON")
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	if *path == "" {
+		return e.errorf("verify: -f is required")
+	}
+
+	result := verifyResult{Missing: []string{}}
+	bf, err := e.readFilter(*path, *formatName)
+	if err != nil {
+		result.Error = err.Error()
+	} else if *keysPath != "" {
+		keys, err := e.readKeys(nil, *keysPath)
+		if err != nil {
+			return e.errorf("verify: reading keys: %v", err)
+		}
+		for _, key := range keys {
+			if !bf.Test(key) {
+				result.Missing = append(result.Missing, string(key))
+			}
+		}
+		result.Checked = len(keys)
+	}
+	result.OK = result.Error == "" && len(result.Missing) == 0
+
+	code := exitOK
+	switch {
+	case result.Error != "":
+		code = exitError
+	case !result.OK:
+		code = exitAbsent
+	}
+
+	if *jsonOut {
+		if e.writeJSON(result) != exitOK {
+			return exitError
+		}
+		return code
+	}
+
+	switch {
+	case result.Error != "":
+		fmt.Fprintf(e.stdout, "FAIL: %s\n", result.Error)
+	case len(result.Missing) > 0:
+		for _, key := range result.Missing {
+			fmt.Fprintf(e.stdout, "missing\t%s\n", key)
+		}
+		fmt.Fprintf(e.stdout, "FAIL: %d of %d keys missing\n", len(result.Missing), result.Checked)
+	default:
+		fmt.Fprintf(e.stdout, "OK: %d keys checked\n", result.Checked)
+	}
+	return code
+}
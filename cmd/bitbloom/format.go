@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/umang-sinha/bitbloom"
+	"github.com/umang-sinha/bitbloom/internal/atomicfile"
+)
+
+// format is an on-disk representation of a filter.
+type format struct {
+	decode func(data []byte) (*bitbloom.BloomFilter, error)
+	encode func(bf *bitbloom.BloomFilter) ([]byte, error)
+}
+
+var formats = map[string]format{
+	"binary": {
+		decode: bitbloom.UnmarshalBinary,
+		encode: (*bitbloom.BloomFilter).MarshalBinary,
+	},
+	"bits-and-blooms": {
+		decode: func(data []byte) (*bitbloom.BloomFilter, error) {
+			return bitbloom.ReadBitsAndBlooms(bytes.NewReader(data))
+		},
+		encode: func(bf *bitbloom.BloomFilter) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := bf.WriteBitsAndBlooms(&buf)
+			return buf.Bytes(), err
+		},
+	},
+	"guava": {
+		decode: func(data []byte) (*bitbloom.BloomFilter, error) {
+			return bitbloom.ReadGuava(bytes.NewReader(data))
+		},
+		encode: func(bf *bitbloom.BloomFilter) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := bf.WriteGuava(&buf)
+			return buf.Bytes(), err
+		},
+	},
+	"base64": textFormat(base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString),
+	"hex":    textFormat(hex.EncodeToString, hex.DecodeString),
+}
+
+// textFormat wraps the binary format in a text encoding. Encoded output ends
+// with a newline, and surrounding whitespace is ignored when decoding.
+func textFormat(enc func([]byte) string, dec func(string) ([]byte, error)) format {
+	return format{
+		decode: func(data []byte) (*bitbloom.BloomFilter, error) {
+			raw, err := dec(strings.TrimSpace(string(data)))
+			if err != nil {
+				return nil, err
+			}
+			return bitbloom.UnmarshalBinary(raw)
+		},
+		encode: func(bf *bitbloom.BloomFilter) ([]byte, error) {
+			raw, err := bf.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			return []byte(enc(raw) + "\n"), nil
+		},
+	}
+}
+
+func formatNames() string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+func lookupFormat(name string) (format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return format{}, fmt.Errorf("unknown format %q (supported: %s)", name, formatNames())
+	}
+	return f, nil
+}
+
+// readFilter loads a filter from path, or from stdin if path is "-".
+func (e *env) readFilter(path, formatName string) (*bitbloom.BloomFilter, error) {
+	f, err := lookupFormat(formatName)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if path == "-" {
+		data, err = io.ReadAll(e.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bf, err := f.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return bf, nil
+}
+
+// writeFilter stores a filter at path, or on stdout if path is "-".
+// Files are replaced atomically so a failed write never leaves a
+// truncated filter behind.
+func (e *env) writeFilter(path, formatName string, bf *bitbloom.BloomFilter) error {
+	f, err := lookupFormat(formatName)
+	if err != nil {
+		return err
+	}
+
+	data, err := f.encode(bf)
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		_, err = e.stdout.Write(data)
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,119 @@
+/*
+Command bitbloom creates, inspects and queries Bloom filters stored in files.
+
+Usage:
+
+	bitbloom <command> [flags] [args]
+
+Commands:
+
+	create   create an empty filter from -n/-p or -m/-k
+	add      add keys to a filter
+	test     test keys for membership
+	info     print filter statistics
+	merge    merge filters with identical parameters
+	convert  convert a filter between formats
+	verify   check a filter decodes and contains a set of keys
+	serve    host named filters behind an HTTP API
+	resp     serve RedisBloom commands over the Redis protocol
+
+Keys are read one per line from the file given with -i, or from standard
+input, unless they are passed as arguments. Filters are read and written in
+the MarshalBinary format unless -format says otherwise.
+
+Exit codes:
+
+	0  success; for test, every key is possibly present
+	1  for test, at least one key is definitely absent; for verify, a
+	   key is missing
+	2  usage, I/O or decoding error
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK     = 0
+	exitAbsent = 1
+	exitError  = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(env *env, args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"create", "create an empty filter from -n/-p or -m/-k", runCreate},
+		{"add", "add keys to a filter", runAdd},
+		{"test", "test keys for membership", runTest},
+		{"info", "print filter statistics", runInfo},
+		{"merge", "merge filters with identical parameters", runMerge},
+		{"convert", "convert a filter between formats", runConvert},
+		{"verify", "check a filter decodes and contains a set of keys", runVerify},
+		{"serve", "host named filters behind an HTTP API", runServe},
+		{"resp", "serve RedisBloom commands over the Redis protocol", runRESP},
+	}
+}
+
+// env holds the standard streams so commands can be run from tests.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (e *env) logf(format string, args ...any) {
+	fmt.Fprintf(e.stderr, "bitbloom: "+format+"\n", args...)
+}
+
+func (e *env) errorf(format string, args ...any) int {
+	e.logf(format, args...)
+	return exitError
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage(stdout)
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(e, args[1:])
+		}
+	}
+
+	fmt.Fprintf(stderr, "bitbloom: unknown command %q\n", name)
+	usage(stderr)
+	return exitError
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: bitbloom <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "bitbloom <command> -h" for command flags`)
+}
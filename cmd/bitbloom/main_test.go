@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom"
+)
+
+// runCmd runs the CLI with the given stdin and returns its exit code and output.
+func runCmd(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI_CreateAddTest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.bloom")
+
+	if code, _, stderr := runCmd(t, "", "create", "-n", "1000", "-p", "0.01", "-o", path); code != exitOK {
+		t.Fatalf("create failed with code %d: %s", code, stderr)
+	}
+	if code, _, stderr := runCmd(t, "foo\nbar\r\n\nbaz\n", "add", "-f", path); code != exitOK {
+		t.Fatalf("add failed with code %d: %s", code, stderr)
+	}
+
+	code, stdout, _ := runCmd(t, "", "test", "-f", path, "foo", "bar", "baz")
+	if code != exitOK {
+		t.Errorf("Expected exit code %d when all keys are present, got %d", exitOK, code)
+	}
+	if stdout != "true\tfoo\ntrue\tbar\ntrue\tbaz\n" {
+		t.Errorf("Unexpected test output: %q", stdout)
+	}
+
+	code, _, _ = runCmd(t, "", "test", "-q", "-f", path, "foo", "missing-key")
+	if code != exitAbsent {
+		t.Errorf("Expected exit code %d when a key is absent, got %d", exitAbsent, code)
+	}
+}
+
+func TestCLI_TestJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.bloom")
+	runCmd(t, "", "create", "-m", "1024", "-k", "3", "-o", path)
+	runCmd(t, "", "add", "-f", path, "foo")
+
+	code, stdout, _ := runCmd(t, "foo\nmissing\n", "test", "-json", "-f", path)
+	if code != exitAbsent {
+		t.Errorf("Expected exit code %d, got %d", exitAbsent, code)
+	}
+
+	var out struct {
+		AllPresent bool         `json:"all_present"`
+		Results    []testResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, stdout)
+	}
+	if out.AllPresent || len(out.Results) != 2 || !out.Results[0].Present || out.Results[1].Present {
+		t.Errorf("Unexpected JSON results: %+v", out)
+	}
+}
+
+func TestCLI_Info(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.bloom")
+	runCmd(t, "", "create", "-m", "1024", "-k", "3", "-o", path)
+	runCmd(t, "", "add", "-f", path, "a", "b")
+
+	code, stdout, _ := runCmd(t, "", "info", "-json", "-f", path)
+	if code != exitOK {
+		t.Fatalf("info failed with code %d", code)
+	}
+
+	var s bitbloom.Stats
+	if err := json.Unmarshal([]byte(stdout), &s); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if s.M != 1024 || s.K != 3 || s.Count != 2 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+
+	_, stdout, _ = runCmd(t, "", "info", "-f", path)
+	if !strings.Contains(stdout, "count:                2\n") {
+		t.Errorf("Unexpected info output:\n%s", stdout)
+	}
+}
+
+func TestCLI_Merge(t *testing.T) {
+	dir := t.TempDir()
+	a, b, out := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "out")
+	runCmd(t, "", "create", "-m", "1024", "-k", "3", "-o", a)
+	runCmd(t, "", "create", "-m", "1024", "-k", "3", "-o", b)
+	runCmd(t, "", "add", "-f", a, "foo")
+	runCmd(t, "", "add", "-f", b, "bar")
+
+	if code, _, stderr := runCmd(t, "", "merge", "-o", out, a, b); code != exitOK {
+		t.Fatalf("merge failed with code %d: %s", code, stderr)
+	}
+	if code, _, _ := runCmd(t, "", "test", "-q", "-f", out, "foo", "bar"); code != exitOK {
+		t.Errorf("Merged filter should contain keys from both inputs")
+	}
+
+	c := filepath.Join(dir, "c")
+	runCmd(t, "", "create", "-m", "2048", "-k", "3", "-o", c)
+	if code, _, _ := runCmd(t, "", "merge", "-o", out, a, c); code != exitError {
+		t.Errorf("Expected merge of incompatible filters to fail, got code %d", code)
+	}
+}
+
+func TestCLI_Convert(t *testing.T) {
+	dir := t.TempDir()
+	path, text := filepath.Join(dir, "f.bloom"), filepath.Join(dir, "f.b64")
+	runCmd(t, "", "create", "-m", "1024", "-k", "3", "-o", path)
+	runCmd(t, "", "add", "-f", path, "foo")
+
+	if code, _, stderr := runCmd(t, "", "convert", "-i", path, "-o", text, "-to", "base64"); code != exitOK {
+		t.Fatalf("convert failed with code %d: %s", code, stderr)
+	}
+	if code, _, _ := runCmd(t, "", "test", "-q", "-format", "base64", "-f", text, "foo"); code != exitOK {
+		t.Errorf("Converted filter should contain the original key")
+	}
+
+	code, stdout, _ := runCmd(t, "", "convert", "-i", text, "-from", "base64", "-to", "binary")
+	if code != exitOK {
+		t.Fatalf("convert to stdout failed with code %d", code)
+	}
+	original, _ := os.ReadFile(path)
+	if stdout != string(original) {
+		t.Errorf("Round-tripped filter does not match the original")
+	}
+
+	if code, _, _ := runCmd(t, "", "convert", "-i", path, "-to", "nope"); code != exitError {
+		t.Errorf("Expected unknown format to fail, got code %d", code)
+	}
+}
+
+func TestCLI_Verify(t *testing.T) {
+	dir := t.TempDir()
+	path, keys := filepath.Join(dir, "f.bloom"), filepath.Join(dir, "keys")
+	runCmd(t, "", "create", "-n", "100", "-o", path)
+	runCmd(t, "", "add", "-f", path, "foo", "bar")
+
+	os.WriteFile(keys, []byte("foo\nbar\n"), 0o644)
+	if code, stdout, _ := runCmd(t, "", "verify", "-f", path, "-keys", keys); code != exitOK {
+		t.Errorf("Expected verify to pass, got code %d: %s", code, stdout)
+	}
+
+	os.WriteFile(keys, []byte("foo\nnot-added\n"), 0o644)
+	code, stdout, _ := runCmd(t, "", "verify", "-json", "-f", path, "-keys", keys)
+	if code != exitAbsent {
+		t.Errorf("Expected verify to fail, got code %d", code)
+	}
+	var res verifyResult
+	if err := json.Unmarshal([]byte(stdout), &res); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if res.OK || len(res.Missing) != 1 || res.Missing[0] != "not-added" {
+		t.Errorf("Unexpected verify result: %+v", res)
+	}
+
+	os.WriteFile(path, []byte("garbage"), 0o644)
+	if code, _, _ := runCmd(t, "", "verify", "-f", path); code != exitError {
+		t.Errorf("Expected verify of corrupt file to fail with code %d, got %d", exitError, code)
+	}
+	code, stdout, _ = runCmd(t, "", "verify", "-json", "-f", filepath.Join(dir, "missing.bloom"))
+	if code != exitError {
+		t.Errorf("Expected verify of an unreadable file to fail with code %d, got %d", exitError, code)
+	}
+	if err := json.Unmarshal([]byte(stdout), &res); err != nil || res.OK || res.Error == "" {
+		t.Errorf("Expected the JSON result to report the error, got %+v, %v", res, err)
+	}
+}
+
+func TestCLI_Usage(t *testing.T) {
+	if code, _, _ := runCmd(t, ""); code != exitError {
+		t.Errorf("Expected usage error without a command, got %d", code)
+	}
+	if code, _, _ := runCmd(t, "", "frobnicate"); code != exitError {
+		t.Errorf("Expected error for unknown command, got %d", code)
+	}
+	if code, _, _ := runCmd(t, "", "create", "-o", "x"); code != exitError {
+		t.Errorf("Expected error for create without size parameters, got %d", code)
+	}
+	if code, _, _ := runCmd(t, "", "test", "-h"); code != exitOK {
+		t.Errorf("Expected -h to succeed, got %d", code)
+	}
+}
+
+func TestCLI_ConvertBitsAndBlooms(t *testing.T) {
+	dir := t.TempDir()
+	golden := filepath.Join("..", "..", "testdata", "bitsandblooms", "small.bin")
+	path, back := filepath.Join(dir, "f.bloom"), filepath.Join(dir, "back.bin")
+
+	if code, _, stderr := runCmd(t, "", "convert", "-i", golden, "-from", "bits-and-blooms", "-to", "binary", "-o", path); code != exitOK {
+		t.Fatalf("convert failed with code %d: %s", code, stderr)
+	}
+	if code, _, _ := runCmd(t, "", "test", "-q", "-f", path, "key-0", "key-29"); code != exitOK {
+		t.Error("Converted filter should contain the keys added by bits-and-blooms")
+	}
+	if code, _, stderr := runCmd(t, "", "convert", "-i", path, "-to", "bits-and-blooms", "-o", back); code != exitOK {
+		t.Fatalf("convert back failed with code %d: %s", code, stderr)
+	}
+
+	want, _ := os.ReadFile(golden)
+	got, _ := os.ReadFile(back)
+	if !bytes.Equal(got, want) {
+		t.Error("Round trip through bitbloom changed the bits-and-blooms filter")
+	}
+}
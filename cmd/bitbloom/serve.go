@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/umang-sinha/bitbloom/resp"
+	"github.com/umang-sinha/bitbloom/server"
+)
+
+func runServe(e *env, args []string) int {
+	fs := e.flagSet("serve", "")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	dataDir := fs.String("data", "", "directory to persist filters in (default: memory only)")
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	srv, err := server.New(server.Options{DataDir: *dataDir})
+	if err != nil {
+		return e.errorf("serve: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return e.errorf("serve: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	e.logf("serving on %s", ln.Addr())
+	if err := srv.Serve(ctx, ln); err != nil {
+		return e.errorf("serve: %v", err)
+	}
+	return exitOK
+}
+
+func runRESP(e *env, args []string) int {
+	fs := e.flagSet("resp", "")
+	addr := fs.String("addr", "localhost:6379", "address to listen on")
+	if ok, code := parse(fs, args); !ok {
+		return code
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return e.errorf("resp: %v", err)
+	}
+
+	srv := resp.NewServer()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	e.logf("serving RESP on %s", ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, resp.ErrServerClosed) {
+		return e.errorf("resp: %v", err)
+	}
+	return exitOK
+}
@@ -0,0 +1,270 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+// ErrDeltaGap is returned by ApplyDelta for a delta that starts after the
+// replica's version, so the changes in between are missing, and by Delta
+// for a version the filter has not reached. Either way the replica must be
+// resynchronized from a full snapshot, such as Delta(0). ApplyDelta also
+// returns it for a delta from another epoch of the source's history.
+var ErrDeltaGap = errors.New("delta does not follow the replica's version")
+
+// DeltaWord is a changed word of a filter's bit array. Applying it ORs
+// Mask into word Index.
+type DeltaWord struct {
+	Index uint64
+	Mask  uint64
+}
+
+// Delta holds the words of a filter's bit array that changed between two
+// versions, as returned by (*BloomFilter).Delta. Applying a delta ORs its
+// words into a replica, so applying one twice, or applying deltas that
+// overlap, is harmless.
+type Delta struct {
+	// From and To are the versions spanned: the delta holds the words
+	// that changed after version From, up to and including version To.
+	From, To uint64
+	// Epoch identifies the source's history of versions. It changes when
+	// the source's bit array is replaced, as by Fold or UnmarshalBinary,
+	// and versions restart.
+	Epoch uint64
+	// M and K are the parameters of the filter and Count its count at
+	// version To.
+	M, K, Count uint64
+	// Words are the changed words in increasing order of index.
+	Words []DeltaWord
+
+	scheme hasher.Scheme
+	probe  probe
+}
+
+// Delta returns the words of the bit array that changed after version
+// since, to bring a replica at that version up to date. Each call closes a
+// version, returned as the delta's To, after which further changes belong
+// to the next version.
+//
+// Changes are tracked from the first call, which costs one more word of
+// memory per word of bits. Bits set before then count as changed in version
+// 1, so Delta(0) always returns every word with a bit set and can seed a new
+// replica.
+//
+// A large filter still held in compressed form moves to dense storage on
+// the first call.
+//
+// It returns an error matching ErrDeltaGap if since is beyond the last
+// version closed, as for a replica of a filter that has since been
+// recreated, or precedes a Reset, and an error if the filter is not stored
+// in memory.
+func (bf *BloomFilter) Delta(since uint64) (*Delta, error) {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	heap, ok := bf.heap()
+	if !ok {
+		return nil, fmt.Errorf("cannot track changes of a filter not stored in memory")
+	}
+	if v := heap.Version(); since > v {
+		return nil, fmt.Errorf("%w: version %d is beyond the filter's version %d", ErrDeltaGap, since, v)
+	}
+	if c := heap.Cleared(); since != 0 && since <= c {
+		return nil, fmt.Errorf("%w: the filter was reset after version %d", ErrDeltaGap, c)
+	}
+
+	if bf.epoch == 0 {
+		bf.epoch = max(rand.Uint64(), 1)
+	}
+	heap.Track()
+	d := &Delta{
+		From:   since,
+		Epoch:  bf.epoch,
+		M:      bf.m,
+		K:      bf.k,
+		Count:  bf.count,
+		scheme: bf.scheme,
+		probe:  bf.probe,
+	}
+	d.To = heap.Changes(since, func(i int, w uint64) {
+		d.Words = append(d.Words, DeltaWord{Index: uint64(i), Mask: w})
+	})
+	return d, nil
+}
+
+// ApplyDelta ORs the words of a delta into bf, which must have the same m,
+// k and hashing scheme as the delta's source, making it a replica at
+// version d.To whose count is the source's. A new filter, or one decoded
+// from MarshalBinary, is a replica at version 0.
+//
+// A delta that ends at or before the replica's version has already been
+// applied and is ignored. A delta that starts after it, or that comes from
+// another epoch than the deltas already applied, returns an error matching
+// ErrDeltaGap and leaves bf unchanged.
+func (bf *BloomFilter) ApplyDelta(d *Delta) error {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	if bf.m != d.M || bf.k != d.K {
+		return fmt.Errorf("cannot apply a delta with different parameters: m=%d k=%d vs m=%d k=%d",
+			bf.m, bf.k, d.M, d.K)
+	}
+	if bf.scheme != d.scheme {
+		return fmt.Errorf("cannot apply a delta with a different hashing scheme: %s vs %s",
+			bf.scheme, d.scheme)
+	}
+	if bf.probe != d.probe {
+		return fmt.Errorf("cannot apply a delta with different probing: %#x vs %#x", uint8(bf.probe), uint8(d.probe))
+	}
+	if bf.applied != 0 && d.Epoch != bf.appliedEpoch {
+		return fmt.Errorf("%w: replica follows epoch %#x, delta is from epoch %#x", ErrDeltaGap, bf.appliedEpoch, d.Epoch)
+	}
+	if d.From > bf.applied {
+		return fmt.Errorf("%w: replica at version %d, delta from %d", ErrDeltaGap, bf.applied, d.From)
+	}
+	if d.To <= bf.applied {
+		return nil
+	}
+	if err := checkDeltaWords(d.Words, bf.m); err != nil {
+		return err
+	}
+
+	heap, isHeap := bf.storage.(*storage.Heap)
+	for _, w := range d.Words {
+		if isHeap {
+			heap.Or(int(w.Index), w.Mask)
+		} else {
+			orWord(bf.storage, w.Index, w.Mask)
+		}
+	}
+	bf.densify()
+	bf.count = d.Count
+	bf.applied = d.To
+	bf.appliedEpoch = d.Epoch
+	return nil
+}
+
+// checkDeltaWords checks that every word lies within a bit array of m bits.
+func checkDeltaWords(words []DeltaWord, m uint64) error {
+	numWords := (m + 63) / 64
+	for _, w := range words {
+		if w.Index >= numWords {
+			return fmt.Errorf("delta word %d out of range for %d words", w.Index, numWords)
+		}
+		if w.Index == numWords-1 && m%64 != 0 && w.Mask>>(m%64) != 0 {
+			return fmt.Errorf("%w: delta word %d", ErrTrailingBits, w.Index)
+		}
+	}
+	return nil
+}
+
+// orWord sets the bits of mask in word i of s.
+func orWord(s storage.Storage, i, mask uint64) {
+	for mask != 0 {
+		s.SetBit(i*64 + uint64(bits.TrailingZeros64(mask)))
+		mask &= mask - 1
+	}
+}
+
+// ReplicaVersion returns the version of the last delta applied by
+// ApplyDelta, which the replica passes to Delta to request the next one.
+func (bf *BloomFilter) ReplicaVersion() uint64 {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	return bf.applied
+}
+
+const deltaHeaderSize = 48
+
+// MarshalBinary encodes the delta for shipping to a replica. The header
+// holds m, k and the hashing scheme as in the filter format, the count,
+// both versions and the epoch; it is followed by the number of words and, for each, the
+// gap from the previous index as a uvarint and the mask as a little-endian
+// word.
+func (d *Delta) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, deltaHeaderSize, deltaHeaderSize+binary.MaxVarintLen64+len(d.Words)*10)
+	putHeader(buf, header{m: d.M, k: d.K, count: d.Count, scheme: d.scheme, probe: d.probe})
+	binary.LittleEndian.PutUint64(buf[24:32], d.From)
+	binary.LittleEndian.PutUint64(buf[32:40], d.To)
+	binary.LittleEndian.PutUint64(buf[40:48], d.Epoch)
+
+	buf = binary.AppendUvarint(buf, uint64(len(d.Words)))
+	next := uint64(0)
+	for _, w := range d.Words {
+		if w.Index < next {
+			return nil, fmt.Errorf("delta words out of order at index %d", w.Index)
+		}
+		buf = binary.AppendUvarint(buf, w.Index-next)
+		buf = binary.LittleEndian.AppendUint64(buf, w.Mask)
+		next = w.Index + 1
+	}
+	return buf, nil
+}
+
+// UnmarshalDelta decodes a delta encoded by MarshalBinary.
+func UnmarshalDelta(data []byte) (*Delta, error) {
+	if len(data) < deltaHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTruncated, len(data))
+	}
+	k, scheme, probe := unpackK(binary.LittleEndian.Uint64(data[8:16]))
+	d := &Delta{
+		M:      binary.LittleEndian.Uint64(data[0:8]),
+		K:      k,
+		Count:  binary.LittleEndian.Uint64(data[16:24]),
+		From:   binary.LittleEndian.Uint64(data[24:32]),
+		To:     binary.LittleEndian.Uint64(data[32:40]),
+		Epoch:  binary.LittleEndian.Uint64(data[40:48]),
+		scheme: scheme,
+		probe:  probe,
+	}
+	if d.M == 0 || d.K == 0 || d.From > d.To {
+		return nil, fmt.Errorf("%w: m=%d k=%d from=%d to=%d", ErrInvalidParams, d.M, d.K, d.From, d.To)
+	}
+	if _, err := hasher.ForScheme(scheme); err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownScheme, scheme)
+	}
+	if err := (header{m: d.M, k: d.K, scheme: scheme, probe: probe}).checkProbe(); err != nil {
+		return nil, err
+	}
+
+	data = data[deltaHeaderSize:]
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return nil, fmt.Errorf("%w: word count", ErrTruncated)
+	}
+	data = data[size:]
+	// Each word takes at least 9 bytes, which bounds the allocation.
+	if n > uint64(len(data)/9) {
+		return nil, fmt.Errorf("%w: %d words in %d bytes", ErrLengthMismatch, n, len(data))
+	}
+
+	d.Words = make([]DeltaWord, n)
+	next := uint64(0)
+	for i := range d.Words {
+		gap, size := binary.Uvarint(data)
+		if size <= 0 || len(data) < size+8 {
+			return nil, fmt.Errorf("%w: word %d", ErrTruncated, i)
+		}
+		index := next + gap
+		if index < next {
+			return nil, fmt.Errorf("%w: word index overflows", ErrInvalidParams)
+		}
+		d.Words[i] = DeltaWord{Index: index, Mask: binary.LittleEndian.Uint64(data[size:])}
+		data = data[size+8:]
+		next = index + 1
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrLengthMismatch, len(data))
+	}
+	if err := checkDeltaWords(d.Words, d.M); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,294 @@
+package bitbloom
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+func TestDelta_Replicate(t *testing.T) {
+	primary, _ := New(1000, 0.01)
+	primary.Add([]byte("before"))
+
+	// A new replica seeds itself with everything set so far.
+	replica := NewWithParams(primary.m, primary.k)
+	d, err := primary.Delta(replica.ReplicaVersion())
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if d.From != 0 || d.To != 1 {
+		t.Errorf("Expected delta from 0 to 1, got %d to %d", d.From, d.To)
+	}
+	if err := replica.ApplyDelta(d); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+
+	for i := range 50 {
+		primary.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	d, err = primary.Delta(replica.ReplicaVersion())
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if len(d.Words) == 0 || len(d.Words) > 50*int(primary.k) {
+		t.Errorf("Unexpected number of changed words: %d", len(d.Words))
+	}
+	if err := replica.ApplyDelta(d); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+
+	if replica.ReplicaVersion() != 2 {
+		t.Errorf("Expected replica at version 2, got %d", replica.ReplicaVersion())
+	}
+	if replica.count != 51 {
+		t.Errorf("Expected replica count 51, got %d", replica.count)
+	}
+	pw, rw := primary.storage.Words(), replica.storage.Words()
+	for i := range pw {
+		if pw[i] != rw[i] {
+			t.Fatalf("Word %d differs after replication: %x vs %x", i, pw[i], rw[i])
+		}
+	}
+
+	d, _ = primary.Delta(replica.ReplicaVersion())
+	if len(d.Words) != 0 {
+		t.Errorf("Expected an empty delta without changes, got %d words", len(d.Words))
+	}
+}
+
+func TestDelta_Idempotent(t *testing.T) {
+	primary, _ := New(1000, 0.01)
+	replica := NewWithParams(primary.m, primary.k)
+
+	primary.Add([]byte("a"))
+	d1, _ := primary.Delta(0)
+	primary.Add([]byte("b"))
+	d2, _ := primary.Delta(d1.To)
+
+	for _, d := range []*Delta{d1, d2, d1, d2} {
+		if err := replica.ApplyDelta(d); err != nil {
+			t.Fatalf("ApplyDelta failed: %v", err)
+		}
+	}
+	if replica.ReplicaVersion() != d2.To {
+		t.Errorf("Expected replica at version %d, got %d", d2.To, replica.ReplicaVersion())
+	}
+	if replica.count != 2 {
+		t.Errorf("Expected reapplying an old delta not to change the count, got %d", replica.count)
+	}
+
+	// A delta overlapping the replica's version is applied.
+	primary.Add([]byte("c"))
+	d3, _ := primary.Delta(d1.To)
+	if err := replica.ApplyDelta(d3); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if !replica.Test([]byte("c")) || replica.ReplicaVersion() != d3.To {
+		t.Error("Expected the overlapping delta to be applied")
+	}
+}
+
+func TestDelta_Gap(t *testing.T) {
+	primary, _ := New(1000, 0.01)
+	replica := NewWithParams(primary.m, primary.k)
+
+	primary.Add([]byte("a"))
+	d1, _ := primary.Delta(0)
+	primary.Add([]byte("b"))
+	d2, _ := primary.Delta(d1.To)
+	primary.Add([]byte("c"))
+	d3, _ := primary.Delta(d2.To)
+
+	replica.ApplyDelta(d1)
+	if err := replica.ApplyDelta(d3); !errors.Is(err, ErrDeltaGap) {
+		t.Errorf("Expected ErrDeltaGap, got %v", err)
+	}
+	if replica.Test([]byte("c")) || replica.ReplicaVersion() != d1.To {
+		t.Error("Expected a rejected delta to leave the replica unchanged")
+	}
+
+	if _, err := primary.Delta(d3.To + 1); !errors.Is(err, ErrDeltaGap) {
+		t.Errorf("Expected ErrDeltaGap for a version beyond the filter's, got %v", err)
+	}
+	if _, err := NewWithParams(100, 3).Delta(1); !errors.Is(err, ErrDeltaGap) {
+		t.Errorf("Expected ErrDeltaGap from an untracked filter, got %v", err)
+	}
+}
+
+func TestDelta_Mismatch(t *testing.T) {
+	primary := NewWithParams(1000, 7)
+	d, _ := primary.Delta(0)
+
+	if err := NewWithParams(1000, 6).ApplyDelta(d); err == nil {
+		t.Error("Expected error applying a delta with a different k")
+	}
+	other := NewBitsAndBlooms(1000, 7)
+	if err := other.ApplyDelta(d); err == nil {
+		t.Error("Expected error applying a delta with a different scheme")
+	}
+
+	d = &Delta{From: 0, To: 1, M: 100, K: 3, Words: []DeltaWord{{Index: 1, Mask: 1 << 36}}, probe: defaultProbe}
+	if err := NewWithParams(100, 3).ApplyDelta(d); !errors.Is(err, ErrTrailingBits) {
+		t.Errorf("Expected ErrTrailingBits, got %v", err)
+	}
+	d.Words[0].Index = 2
+	if err := NewWithParams(100, 3).ApplyDelta(d); err == nil {
+		t.Error("Expected error for a word out of range")
+	}
+}
+
+func TestDelta_NotInMemory(t *testing.T) {
+	view, err := storage.NewView(make([]byte, 16), 128)
+	if err != nil {
+		t.Fatalf("NewView failed: %v", err)
+	}
+	bf := NewWithStorage(view, 3)
+	if _, err := bf.Delta(0); err == nil {
+		t.Error("Expected error tracking changes of a view")
+	}
+}
+
+func TestDelta_MergeTracked(t *testing.T) {
+	primary := NewWithParams(1000, 5)
+	d, _ := primary.Delta(0)
+
+	other := NewWithParams(1000, 5)
+	other.Add([]byte("merged"))
+	if err := primary.Merge(other); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	replica := NewWithParams(1000, 5)
+	replica.ApplyDelta(d)
+	d, _ = primary.Delta(d.To)
+	replica.ApplyDelta(d)
+	if !replica.Test([]byte("merged")) {
+		t.Error("Expected bits set by Merge to be in the delta")
+	}
+}
+
+func TestDelta_MarshalRoundTrip(t *testing.T) {
+	primary := NewBitsAndBlooms(5000, 4)
+	for i := range 20 {
+		primary.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	d, _ := primary.Delta(0)
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	decoded, err := UnmarshalDelta(data)
+	if err != nil {
+		t.Fatalf("UnmarshalDelta failed: %v", err)
+	}
+	if decoded.From != d.From || decoded.To != d.To || decoded.Epoch != d.Epoch || decoded.Count != d.Count ||
+		decoded.scheme != d.scheme || len(decoded.Words) != len(d.Words) {
+		t.Fatalf("Decoded delta differs: %+v vs %+v", decoded, d)
+	}
+	for i := range d.Words {
+		if decoded.Words[i] != d.Words[i] {
+			t.Errorf("Word %d differs: %+v vs %+v", i, decoded.Words[i], d.Words[i])
+		}
+	}
+
+	replica := NewBitsAndBlooms(5000, 4)
+	if err := replica.ApplyDelta(decoded); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if !replica.Test([]byte("item-7")) {
+		t.Error("Expected the decoded delta to replicate the filter")
+	}
+
+	for _, n := range []int{0, deltaHeaderSize, len(data) - 1} {
+		if _, err := UnmarshalDelta(data[:n]); err == nil {
+			t.Errorf("Expected error decoding %d of %d bytes", n, len(data))
+		}
+	}
+	if _, err := UnmarshalDelta(append(data, 0)); err == nil {
+		t.Error("Expected error for trailing bytes")
+	}
+}
+
+func TestDelta_Reset(t *testing.T) {
+	primary, _ := New(1000, 0.01)
+	replica := NewWithParams(primary.m, primary.k)
+
+	primary.Add([]byte("a"))
+	d, _ := primary.Delta(0)
+	replica.ApplyDelta(d)
+
+	primary.Reset()
+	primary.Add([]byte("b"))
+	if _, err := primary.Delta(replica.ReplicaVersion()); !errors.Is(err, ErrDeltaGap) {
+		t.Fatalf("Expected ErrDeltaGap after Reset, got %v", err)
+	}
+
+	replica.Reset()
+	d, err := primary.Delta(replica.ReplicaVersion())
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if err := replica.ApplyDelta(d); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if !replica.Equal(primary) || replica.Test([]byte("a")) {
+		t.Error("Expected the rebuilt replica to equal the reset filter")
+	}
+}
+
+func TestDelta_Epoch(t *testing.T) {
+	primary, _ := New(1000, 0.01)
+	replica := NewWithParams(primary.m, primary.k)
+
+	primary.Add([]byte("a"))
+	d, _ := primary.Delta(0)
+	if d.Epoch == 0 {
+		t.Fatal("Expected a delta to carry an epoch")
+	}
+	replica.ApplyDelta(d)
+	if d2, _ := primary.Delta(0); d2.Epoch != d.Epoch {
+		t.Errorf("Expected the epoch to stay %#x while the storage is kept, got %#x", d.Epoch, d2.Epoch)
+	}
+
+	// Replacing the bit array restarts versions at 0, so the replica's
+	// version alone would accept the new history.
+	other, _ := New(1000, 0.01)
+	other.Add([]byte("b"))
+	data, _ := other.MarshalBinary()
+	if err := primary.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	primary.Delta(0)
+	primary.Add([]byte("c"))
+	d, err := primary.Delta(replica.ReplicaVersion())
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if err := replica.ApplyDelta(d); !errors.Is(err, ErrDeltaGap) {
+		t.Fatalf("Expected ErrDeltaGap for a delta from another epoch, got %v", err)
+	}
+	if replica.Test([]byte("c")) {
+		t.Error("Expected a rejected delta to leave the replica unchanged")
+	}
+
+	replica.Reset()
+	d, _ = primary.Delta(0)
+	if err := replica.ApplyDelta(d); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if !replica.Equal(primary) {
+		t.Error("Expected the rebuilt replica to equal the filter")
+	}
+
+	pow, _ := NewPowerOfTwo(1000, 0.01)
+	before, _ := pow.Delta(0)
+	if _, err := pow.Fold(2); err != nil {
+		t.Fatalf("Fold failed: %v", err)
+	}
+	if after, _ := pow.Delta(0); after.Epoch == before.Epoch {
+		t.Error("Expected Fold to start a new epoch")
+	}
+}
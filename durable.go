@@ -0,0 +1,473 @@
+package bitbloom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/umang-sinha/bitbloom/internal/atomicfile"
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// Names of the files in a durable filter's directory.
+const (
+	DurableSnapshotFile = "filter.bloom"
+	DurableLogFile      = "filter.wal"
+)
+
+const (
+	walMagic      = "BBWAL"
+	walVersion    = 1
+	walHeaderSize = 8
+)
+
+var walTable = crc32.MakeTable(crc32.Castagnoli)
+
+// DurableOptions configures a DurableFilter.
+type DurableOptions struct {
+	// SyncEvery is the number of log records written between fsyncs. The
+	// default of 1 syncs on every Add; larger values batch fsyncs, so up to
+	// SyncEvery-1 of the most recent adds may be lost in a crash.
+	SyncEvery int
+
+	// SyncInterval, if positive, also syncs the log in the background at
+	// this interval, bounding how long an unsynced add can stay at risk.
+	SyncInterval time.Duration
+
+	// SnapshotInterval, if positive, snapshots the filter in the background
+	// at this interval. Otherwise snapshots are only taken by Snapshot and
+	// Close, and the log grows until then.
+	SnapshotInterval time.Duration
+}
+
+// DurableFilter is a Bloom filter that survives crashes. Each Add appends
+// the item's hash, not the item itself, to a write-ahead log before the
+// bits are set. The filter is periodically snapshotted to a file, after
+// which the log is cut down to the records the snapshot does not cover;
+// opening the filter replays the log on top of the latest snapshot.
+//
+// Replaying a record is idempotent for the bit array, so a crash in the
+// middle of a snapshot loses nothing, but the items whose records are
+// replayed on top of a snapshot that already covers them are counted twice.
+//
+// It is safe for concurrent use by multiple goroutines.
+type DurableFilter struct {
+	bf  *BloomFilter
+	dir string
+
+	// mutex guards the log. Add sets bits while holding it, so that the
+	// filter as seen under the mutex contains exactly the logged records.
+	mutex     sync.Mutex
+	log       *os.File
+	w         *bufio.Writer
+	logSize   int64
+	syncEvery int
+	unsynced  int
+	err       error // first log error; no further adds are accepted
+
+	// snapMutex serializes snapshots.
+	snapMutex sync.Mutex
+	bgErr     error // first error of a background snapshot or sync
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenDurable opens the durable filter stored in dir, creating the
+// directory and a new filter sized for `n` items with a false positive
+// probability of `p` if it holds none. The parameters of an existing filter
+// are read from its snapshot, and n and p are ignored.
+//
+// A log whose tail was torn by a crash is replayed up to the last complete
+// record and truncated there.
+func OpenDurable(dir string, n uint64, p float64, opts DurableOptions) (*DurableFilter, error) {
+	if opts.SyncEvery < 0 || opts.SyncInterval < 0 || opts.SnapshotInterval < 0 {
+		return nil, fmt.Errorf("durable: options must not be negative")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("durable: %w", err)
+	}
+
+	bf, err := loadSnapshot(filepath.Join(dir, DurableSnapshotFile), n, p)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, DurableLogFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("durable: %w", err)
+	}
+	size, err := replayLog(f, bf)
+	if err == nil {
+		size, err = resetLog(f, size, bf.scheme)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	df := &DurableFilter{
+		bf:        bf,
+		dir:       dir,
+		log:       f,
+		w:         bufio.NewWriter(f),
+		logSize:   size,
+		syncEvery: max(opts.SyncEvery, 1),
+	}
+	if opts.SyncInterval > 0 || opts.SnapshotInterval > 0 {
+		df.stop = make(chan struct{})
+		df.done = make(chan struct{})
+		go df.run(opts.SyncInterval, opts.SnapshotInterval)
+	}
+	return df, nil
+}
+
+// loadSnapshot reads the snapshot at path, or creates a filter and writes
+// its first snapshot if there is none, so the parameters are always on disk.
+func loadSnapshot(path string, n uint64, p float64) (*BloomFilter, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		bf, err := UnmarshalBinary(data)
+		if err != nil {
+			return nil, fmt.Errorf("durable: reading snapshot: %w", err)
+		}
+		return bf, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("durable: %w", err)
+	}
+
+	bf, err := New(n, p)
+	if err != nil {
+		return nil, err
+	}
+	data, err = bf.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("durable: writing snapshot: %w", err)
+	}
+	return bf, nil
+}
+
+// walHeader returns the header of a log whose records hold digests of the
+// given scheme.
+func walHeader(scheme hasher.Scheme) []byte {
+	h := make([]byte, walHeaderSize)
+	copy(h, walMagic)
+	h[5] = walVersion
+	h[6] = byte(scheme)
+	return h
+}
+
+// walRecordSize returns the size of a log record: the digest words in
+// little-endian order followed by their CRC-32C.
+func walRecordSize(scheme hasher.Scheme) int {
+	return scheme.DigestLen()*8 + 4
+}
+
+func appendRecord(dst []byte, d hasher.Digest, scheme hasher.Scheme) []byte {
+	start := len(dst)
+	for _, w := range d[:scheme.DigestLen()] {
+		dst = binary.LittleEndian.AppendUint64(dst, w)
+	}
+	return binary.LittleEndian.AppendUint32(dst, crc32.Checksum(dst[start:], walTable))
+}
+
+// replayLog adds the records of the log read from f to bf and returns the
+// size of its valid prefix, which is 0 if not even the header is complete.
+// Reading stops at the first incomplete or corrupt record.
+func replayLog(f *os.File, bf *BloomFilter) (int64, error) {
+	r := bufio.NewReader(f)
+
+	hdr := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("durable: reading log: %w", err)
+	}
+	if string(hdr[:len(walMagic)]) != walMagic || hdr[5] != walVersion {
+		return 0, fmt.Errorf("durable: %s is not a filter log", f.Name())
+	}
+	if scheme := hasher.Scheme(hdr[6]); scheme != bf.scheme {
+		return 0, fmt.Errorf("durable: log hashes with %s but the snapshot with %s", scheme, bf.scheme)
+	}
+
+	size := int64(walHeaderSize)
+	rec := make([]byte, walRecordSize(bf.scheme))
+	sum := len(rec) - 4
+	for {
+		if _, err := io.ReadFull(r, rec); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return size, nil
+			}
+			return 0, fmt.Errorf("durable: reading log: %w", err)
+		}
+		if crc32.Checksum(rec[:sum], walTable) != binary.LittleEndian.Uint32(rec[sum:]) {
+			return size, nil
+		}
+
+		var d hasher.Digest
+		for i := range sum / 8 {
+			d[i] = binary.LittleEndian.Uint64(rec[i*8:])
+		}
+		bf.addDigest(d)
+		size += int64(len(rec))
+	}
+}
+
+// resetLog truncates the log to its valid prefix of size bytes, writing a
+// header if it has none, and positions f at its end. It returns the new
+// size.
+func resetLog(f *os.File, size int64, scheme hasher.Scheme) (int64, error) {
+	if err := f.Truncate(size); err != nil {
+		return 0, fmt.Errorf("durable: %w", err)
+	}
+	if size == 0 {
+		if _, err := f.WriteAt(walHeader(scheme), 0); err != nil {
+			return 0, fmt.Errorf("durable: %w", err)
+		}
+		size = walHeaderSize
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("durable: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("durable: %w", err)
+	}
+	return size, nil
+}
+
+// Add logs the item's hash and inserts it into the filter. The item is not
+// added if the log cannot be written. After a log error every Add fails
+// with it; the filter must then be closed and reopened.
+func (df *DurableFilter) Add(item []byte) error {
+	d := df.bf.scheme.Sum(item)
+	var buf [4*8 + 4]byte
+	rec := appendRecord(buf[:0], d, df.bf.scheme)
+
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	if df.err != nil {
+		return df.err
+	}
+	if _, err := df.w.Write(rec); err != nil {
+		return df.setErr(err)
+	}
+	df.logSize += int64(len(rec))
+	df.unsynced++
+	if df.unsynced >= df.syncEvery {
+		if err := df.syncLocked(); err != nil {
+			return err
+		}
+	}
+
+	df.bf.addDigest(d)
+	return nil
+}
+
+// Test checks whether an item is possibly in the filter.
+func (df *DurableFilter) Test(item []byte) bool {
+	return df.bf.Test(item)
+}
+
+// Count returns the number of items added, see the note on replay in the
+// type's documentation.
+func (df *DurableFilter) Count() uint64 {
+	df.bf.mutex.RLock()
+	defer df.bf.mutex.RUnlock()
+
+	return df.bf.count
+}
+
+// Stats returns the filter's statistics.
+func (df *DurableFilter) Stats() Stats {
+	return df.bf.Stats()
+}
+
+func (df *DurableFilter) setErr(err error) error {
+	if df.err == nil {
+		df.err = fmt.Errorf("durable: writing log: %w", err)
+	}
+	return df.err
+}
+
+// syncLocked flushes buffered records and fsyncs the log. The caller holds
+// the mutex.
+func (df *DurableFilter) syncLocked() error {
+	if df.err != nil {
+		return df.err
+	}
+	if err := df.w.Flush(); err != nil {
+		return df.setErr(err)
+	}
+	if err := df.log.Sync(); err != nil {
+		return df.setErr(err)
+	}
+	df.unsynced = 0
+	return nil
+}
+
+// Sync writes every logged record to stable storage, regardless of
+// SyncEvery.
+func (df *DurableFilter) Sync() error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	return df.syncLocked()
+}
+
+// Snapshot atomically replaces the filter's snapshot with its current
+// contents and then drops the log records the snapshot covers. Adds may
+// continue while the snapshot is written.
+func (df *DurableFilter) Snapshot() error {
+	df.snapMutex.Lock()
+	defer df.snapMutex.Unlock()
+
+	df.mutex.Lock()
+	if err := df.syncLocked(); err != nil {
+		df.mutex.Unlock()
+		return err
+	}
+	covered := df.logSize
+	frozen := df.bf.Freeze()
+	df.mutex.Unlock()
+
+	data, err := frozen.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(df.dir, DurableSnapshotFile), data, 0o600); err != nil {
+		return fmt.Errorf("durable: writing snapshot: %w", err)
+	}
+
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	return df.truncateLog(covered)
+}
+
+// truncateLog replaces the log with one holding only the records after
+// offset covered, which have been added since the last snapshot was taken.
+// The caller holds the mutex.
+func (df *DurableFilter) truncateLog(covered int64) error {
+	if err := df.syncLocked(); err != nil {
+		return err
+	}
+
+	tail := make([]byte, df.logSize-covered)
+	if _, err := df.log.ReadAt(tail, covered); err != nil {
+		return fmt.Errorf("durable: reading log: %w", err)
+	}
+	path := filepath.Join(df.dir, DurableLogFile)
+	if err := atomicfile.WriteFile(path, append(walHeader(df.bf.scheme), tail...), 0o600); err != nil {
+		return fmt.Errorf("durable: rewriting log: %w", err)
+	}
+
+	// The old file has been replaced; switch appends over to the new one.
+	df.log.Close()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekEnd)
+		if err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		df.log = nil
+		return df.setErr(err)
+	}
+	df.log = f
+	df.w.Reset(f)
+	df.logSize = walHeaderSize + int64(len(tail))
+	return nil
+}
+
+// run syncs and snapshots the filter in the background until Close.
+func (df *DurableFilter) run(syncInterval, snapshotInterval time.Duration) {
+	defer close(df.done)
+
+	var syncC, snapC <-chan time.Time
+	if syncInterval > 0 {
+		t := time.NewTicker(syncInterval)
+		defer t.Stop()
+		syncC = t.C
+	}
+	if snapshotInterval > 0 {
+		t := time.NewTicker(snapshotInterval)
+		defer t.Stop()
+		snapC = t.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-df.stop:
+			return
+		case <-syncC:
+			err = df.Sync()
+		case <-snapC:
+			err = df.Snapshot()
+		}
+		if err != nil {
+			df.mutex.Lock()
+			if df.bgErr == nil {
+				df.bgErr = err
+			}
+			df.mutex.Unlock()
+		}
+	}
+}
+
+// Err returns the first error of the log or of a background sync or
+// snapshot, if any.
+func (df *DurableFilter) Err() error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	if df.err != nil {
+		return df.err
+	}
+	return df.bgErr
+}
+
+// Close stops background work, takes a final snapshot and closes the log.
+// The filter must not be used afterwards.
+func (df *DurableFilter) Close() error {
+	if df.stop != nil {
+		close(df.stop)
+		<-df.done
+		df.stop = nil
+	}
+
+	err := df.Snapshot()
+
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	if df.log != nil {
+		if serr := df.syncLocked(); err == nil {
+			err = serr
+		}
+		if cerr := df.log.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("durable: %w", cerr)
+		}
+		df.log = nil
+	}
+	if df.err == nil {
+		df.err = errDurableClosed
+	}
+	return err
+}
+
+var errDurableClosed = errors.New("durable: filter is closed")
@@ -0,0 +1,278 @@
+package bitbloom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func logSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	fi, err := os.Stat(filepath.Join(dir, DurableLogFile))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	return fi.Size()
+}
+
+// crash abandons the filter without a final snapshot, leaving whatever has
+// reached the log file.
+func crash(df *DurableFilter) {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	df.log.Close()
+	df.log = nil
+	df.err = errDurableClosed
+}
+
+func TestDurableFilter_ReopenAfterClose(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	for i := range 100 {
+		if err := df.Add(fmt.Appendf(nil, "item-%d", i)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := df.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := df.Add([]byte("late")); err == nil {
+		t.Error("Expected Add after Close to fail")
+	}
+	if size := logSize(t, dir); size != walHeaderSize {
+		t.Errorf("Expected the final snapshot to empty the log, got %d bytes", size)
+	}
+
+	df, err = OpenDurable(dir, 1, 0.5, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+
+	for i := range 100 {
+		if !df.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Errorf("Expected item-%d to survive reopening", i)
+		}
+	}
+	if df.Count() != 100 {
+		t.Errorf("Expected count 100, got %d", df.Count())
+	}
+	if df.Stats().M != OptimalM(1000, 0.01) {
+		t.Errorf("Expected the snapshot's parameters to override n and p")
+	}
+}
+
+func TestDurableFilter_ReplayAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	df.Add([]byte("before"))
+	if err := df.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	df.Add([]byte("after"))
+	crash(df)
+
+	df, err = OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+
+	for _, item := range []string{"before", "after"} {
+		if !df.Test([]byte(item)) {
+			t.Errorf("Expected %q to be recovered", item)
+		}
+	}
+	if df.Count() != 2 {
+		t.Errorf("Expected count 2, got %d", df.Count())
+	}
+}
+
+func TestDurableFilter_TornLog(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	df.Add([]byte("whole"))
+	crash(df)
+
+	path := filepath.Join(dir, DurableLogFile)
+	valid := logSize(t, dir)
+
+	// A record cut short, as by a crash in the middle of a write.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write(make([]byte, 7))
+	f.Close()
+
+	df, err = OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	if !df.Test([]byte("whole")) {
+		t.Error("Expected the complete record to be replayed")
+	}
+	if size := logSize(t, dir); size != valid {
+		t.Errorf("Expected the log to be truncated to %d bytes, got %d", valid, size)
+	}
+	df.Add([]byte("next"))
+	crash(df)
+
+	df, err = OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+	if !df.Test([]byte("next")) {
+		t.Error("Expected a record written after truncation to be replayed")
+	}
+}
+
+func TestDurableFilter_CorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	df.Add([]byte("first"))
+	df.Add([]byte("second"))
+	crash(df)
+
+	path := filepath.Join(dir, DurableLogFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	df, err = OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+
+	if !df.Test([]byte("first")) {
+		t.Error("Expected the record before the corrupt one to be replayed")
+	}
+	if df.Count() != 1 {
+		t.Errorf("Expected replay to stop at the corrupt record, got count %d", df.Count())
+	}
+}
+
+func TestDurableFilter_SyncEvery(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{SyncEvery: 4})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+
+	rec := int64(walRecordSize(df.bf.scheme))
+	for i := range 3 {
+		df.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	if size := logSize(t, dir); size != walHeaderSize {
+		t.Errorf("Expected records to be batched, log has %d bytes", size)
+	}
+	df.Add([]byte("item-3"))
+	if size := logSize(t, dir); size != walHeaderSize+4*rec {
+		t.Errorf("Expected 4 records after the batch, log has %d bytes", size)
+	}
+
+	df.Add([]byte("item-4"))
+	if err := df.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if size := logSize(t, dir); size != walHeaderSize+5*rec {
+		t.Errorf("Expected Sync to write the partial batch, log has %d bytes", size)
+	}
+}
+
+func TestDurableFilter_SnapshotKeepsNewerRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+
+	df.Add([]byte("a"))
+	df.Add([]byte("b"))
+
+	// Records logged after the snapshot was frozen must stay in the log.
+	if err := df.truncateLog(walHeaderSize + int64(walRecordSize(df.bf.scheme))); err != nil {
+		t.Fatalf("truncateLog failed: %v", err)
+	}
+	if size := logSize(t, dir); size != walHeaderSize+int64(walRecordSize(df.bf.scheme)) {
+		t.Errorf("Expected one record left in the log, got %d bytes", size)
+	}
+
+	df.Add([]byte("c"))
+	if size := logSize(t, dir); size != walHeaderSize+2*int64(walRecordSize(df.bf.scheme)) {
+		t.Errorf("Expected appends to continue on the new log, got %d bytes", size)
+	}
+}
+
+func TestDurableFilter_BackgroundSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	df, err := OpenDurable(dir, 1000, 0.01, DurableOptions{SnapshotInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenDurable failed: %v", err)
+	}
+	defer df.Close()
+
+	df.Add([]byte("item"))
+	deadline := time.Now().Add(5 * time.Second)
+	for logSize(t, dir) != walHeaderSize {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a background snapshot to empty the log")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, DurableSnapshotFile))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	bf, err := UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !bf.Test([]byte("item")) {
+		t.Error("Expected the snapshot to contain the item")
+	}
+	if err := df.Err(); err != nil {
+		t.Errorf("Unexpected background error: %v", err)
+	}
+}
+
+func TestDurableFilter_InvalidOptions(t *testing.T) {
+	if _, err := OpenDurable(t.TempDir(), 1000, 0.01, DurableOptions{SyncEvery: -1}); err == nil {
+		t.Error("Expected error for negative SyncEvery")
+	}
+	if _, err := OpenDurable(t.TempDir(), 1000, 0, DurableOptions{}); err == nil {
+		t.Error("Expected error for p = 0")
+	}
+}
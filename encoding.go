@@ -0,0 +1,150 @@
+package bitbloom
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// UnmarshalBinary replaces the filter's contents with data in the format
+// produced by MarshalBinary, implementing encoding.BinaryUnmarshaler so
+// filters can be decoded by encoding/gob. It is the method form of the
+// package-level UnmarshalBinary.
+func (bf *BloomFilter) UnmarshalBinary(data []byte) error {
+	decoded, err := UnmarshalBinary(data)
+	if err != nil {
+		return err
+	}
+	bf.replace(decoded)
+	return nil
+}
+
+// replace moves the contents of other, which must not be shared, into bf.
+// Change tracking starts over, and bf is no longer a replica at any
+// version.
+func (bf *BloomFilter) replace(other *BloomFilter) {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	bf.storage = other.storage
+	bf.hasher = other.hasher
+	bf.scheme = other.scheme
+	bf.probe = other.probe
+	bf.m = other.m
+	bf.k = other.k
+	bf.count = other.count
+	bf.epoch = 0
+	bf.applied = 0
+}
+
+// MarshalText encodes the filter as the standard base64 encoding of
+// MarshalBinary, implementing encoding.TextMarshaler.
+func (bf *BloomFilter) MarshalText() ([]byte, error) {
+	data, err := bf.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.AppendEncode(nil, data), nil
+}
+
+// UnmarshalText decodes a filter encoded by MarshalText, implementing
+// encoding.TextUnmarshaler. The URL-safe base64 alphabet and unpadded
+// input are also accepted.
+func (bf *BloomFilter) UnmarshalText(text []byte) error {
+	data, err := decodeBase64(text)
+	if err != nil {
+		return fmt.Errorf("decoding filter text: %w", err)
+	}
+	return bf.UnmarshalBinary(data)
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not.
+func decodeBase64(text []byte) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		if len(text)%4 != 0 {
+			enc = enc.WithPadding(base64.NoPadding)
+		}
+		if data, err := enc.AppendDecode(nil, text); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64")
+}
+
+// filterJSON is the JSON representation of a filter. Bits holds the
+// bitset words as in MarshalBinary, base64 encoded by encoding/json.
+type filterJSON struct {
+	M           uint64 `json:"m"`
+	K           uint64 `json:"k"`
+	Count       uint64 `json:"count"`
+	Hasher      string `json:"hasher"`
+	Partitioned bool   `json:"partitioned,omitempty"`
+	Probing     string `json:"probing,omitempty"`
+	Bits        []byte `json:"bits"`
+}
+
+// MarshalJSON encodes the filter as a JSON object, implementing
+// json.Marshaler:
+//
+//	{"m":1024,"k":7,"count":3,"hasher":"murmur3","probing":"enhanced-multiply-shift","bits":"AAAA..."}
+//
+// bits is the standard base64 encoding of the little-endian bitset words.
+// Partitioned filters also have "partitioned":true. probing is omitted for
+// the scheme's own probing, as used by filters written before it was
+// recorded.
+func (bf *BloomFilter) MarshalJSON() ([]byte, error) {
+	data, err := bf.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	// Take the parameters from the same snapshot as the bits.
+	k, scheme, probe := unpackK(binary.LittleEndian.Uint64(data[8:16]))
+	v := filterJSON{
+		M:           binary.LittleEndian.Uint64(data[0:8]),
+		K:           k,
+		Count:       binary.LittleEndian.Uint64(data[16:24]),
+		Hasher:      scheme.String(),
+		Partitioned: probe&probePartitioned != 0,
+		Bits:        data[headerSize:],
+	}
+	if probe&^probePartitioned != 0 {
+		v.Probing = probe.probing().String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a filter encoded by MarshalJSON, implementing
+// json.Unmarshaler. It validates the object as UnmarshalBinary does.
+func (bf *BloomFilter) UnmarshalJSON(data []byte) error {
+	var v filterJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	scheme, err := hasher.ParseScheme(v.Hasher)
+	if err != nil {
+		return fmt.Errorf("%w %q", ErrUnknownScheme, v.Hasher)
+	}
+	if v.K > DefaultMaxK {
+		return &LimitError{Field: "k", Value: v.K, Limit: DefaultMaxK}
+	}
+
+	var probe probe
+	if v.Probing != "" {
+		hp, err := hasher.ParseProbing(v.Probing)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnknownScheme, err)
+		}
+		probe = probeFor(hp)
+	}
+	if v.Partitioned {
+		probe |= probePartitioned
+	}
+	buf := make([]byte, headerSize, headerSize+len(v.Bits))
+	putHeader(buf, header{m: v.M, k: v.K, count: v.Count, scheme: scheme, probe: probe})
+	return bf.UnmarshalBinary(append(buf, v.Bits...))
+}
@@ -0,0 +1,149 @@
+package bitbloom
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*BloomFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*BloomFilter)(nil)
+	_ encoding.BinaryAppender    = (*BloomFilter)(nil)
+	_ encoding.TextMarshaler     = (*BloomFilter)(nil)
+	_ encoding.TextUnmarshaler   = (*BloomFilter)(nil)
+	_ json.Marshaler             = (*BloomFilter)(nil)
+	_ json.Unmarshaler           = (*BloomFilter)(nil)
+)
+
+func newTestFilter() *BloomFilter {
+	bf := NewBitsAndBlooms(200, 3)
+	bf.Add([]byte("foo"))
+	bf.Add([]byte("bar"))
+	return bf
+}
+
+func checkDecoded(t *testing.T, got, want *BloomFilter) {
+	t.Helper()
+	if got.m != want.m || got.k != want.k || got.count != want.count || got.scheme != want.scheme {
+		t.Errorf("Parameters not preserved: m=%d k=%d count=%d scheme=%s", got.m, got.k, got.count, got.scheme)
+	}
+	if !got.Test([]byte("foo")) || !got.Test([]byte("bar")) {
+		t.Error("Items not preserved")
+	}
+}
+
+func TestBloomFilter_AppendBinary(t *testing.T) {
+	bf := newTestFilter()
+	want, _ := bf.MarshalBinary()
+
+	got, err := bf.AppendBinary([]byte("prefix"))
+	if err != nil {
+		t.Fatalf("AppendBinary failed: %v", err)
+	}
+	if !bytes.Equal(got, append([]byte("prefix"), want...)) {
+		t.Error("AppendBinary did not append the MarshalBinary encoding")
+	}
+}
+
+func TestBloomFilter_UnmarshalBinaryMethod(t *testing.T) {
+	bf := newTestFilter()
+	data, _ := bf.MarshalBinary()
+
+	decoded := NewWithParams(10, 1)
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	checkDecoded(t, decoded, bf)
+
+	if err := decoded.UnmarshalBinary(data[:10]); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+}
+
+func TestBloomFilter_Text(t *testing.T) {
+	bf := newTestFilter()
+	text, err := bf.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText failed: %v", err)
+	}
+
+	data, _ := bf.MarshalBinary()
+	urlText := base64.RawURLEncoding.EncodeToString(data)
+	for _, s := range []string{string(text), urlText} {
+		var decoded BloomFilter
+		if err := decoded.UnmarshalText([]byte(s)); err != nil {
+			t.Fatalf("UnmarshalText(%.20s...) failed: %v", s, err)
+		}
+		checkDecoded(t, &decoded, bf)
+	}
+
+	var decoded BloomFilter
+	if err := decoded.UnmarshalText([]byte("not base64!")); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+}
+
+func TestBloomFilter_JSON(t *testing.T) {
+	bf := newTestFilter()
+
+	type config struct {
+		Name   string       `json:"name"`
+		Filter *BloomFilter `json:"filter"`
+	}
+	data, err := json.Marshal(config{Name: "users", Filter: bf})
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	for _, field := range []string{`"m":200`, `"k":3`, `"count":2`, `"hasher":"bits-and-blooms"`, `"bits":"`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("Expected %s in %s", field, data)
+		}
+	}
+
+	var decoded config
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	checkDecoded(t, decoded.Filter, bf)
+
+	for name, s := range map[string]string{
+		"unknown hasher": `{"m":64,"k":3,"count":0,"hasher":"sha1","bits":"AAAAAAAAAAA="}`,
+		"short bits":     `{"m":128,"k":3,"count":0,"hasher":"murmur3","bits":"AAAAAAAAAAA="}`,
+		"huge k":         `{"m":64,"k":72057594037927937,"count":0,"hasher":"murmur3","bits":"AAAAAAAAAAA="}`,
+	} {
+		var f BloomFilter
+		if err := json.Unmarshal([]byte(s), &f); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	var f BloomFilter
+	err = json.Unmarshal([]byte(`{"m":64,"k":3,"count":0,"hasher":"sha1","bits":""}`), &f)
+	if !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme, got %v", err)
+	}
+}
+
+func TestBloomFilter_Gob(t *testing.T) {
+	bf := newTestFilter()
+
+	type snapshot struct {
+		Version int
+		Filter  *BloomFilter
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot{Version: 1, Filter: bf}); err != nil {
+		t.Fatalf("gob encode failed: %v", err)
+	}
+
+	var decoded snapshot
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("gob decode failed: %v", err)
+	}
+	checkDecoded(t, decoded.Filter, bf)
+}
@@ -0,0 +1,143 @@
+package bitbloom
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+// Fold shrinks the filter's bit array by factor, a power of two, by OR-ing
+// its equal parts together, and returns the filter's false positive rate
+// afterwards. Every item added before remains present, k and the count are
+// kept, and only the false positive rate grows.
+//
+// Folding relies on mask probing, which keeps the low bits of each hash, so
+// only filters made by NewPowerOfTwo can be folded: halving the array drops
+// the highest bit of every location, moving bit j + m/2 onto bit j. Each
+// slice of a partitioned filter is folded on its own. The folded size must
+// be at least one bit, or one bit per slice.
+//
+// It returns an error if the filter does not use mask probing or is not
+// stored in memory. The folded filter is no longer a replica at any
+// version, and its replicas must be rebuilt.
+func (bf *BloomFilter) Fold(factor uint64) (float64, error) {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	if factor == 0 || factor&(factor-1) != 0 {
+		return 0, fmt.Errorf("fold factor must be a power of two, got %d", factor)
+	}
+	if err := bf.checkFoldable(); err != nil {
+		return 0, err
+	}
+	if bf.sliceSize() < factor {
+		return 0, fmt.Errorf("cannot fold %d bits by %d", bf.sliceSize(), factor)
+	}
+
+	words := bf.storage.Words()
+	if factor > 1 {
+		words = foldWords(words, bf.m, bf.slices(), factor)
+		bf.setFolded(words, bf.m/factor)
+	}
+	return wordsFPR(words, bf.m, bf.k, bf.probe), nil
+}
+
+// Shrink folds the filter as far as it can while its false positive rate
+// stays at or below targetFPR, and returns the fold factor used, 1 if even
+// halving would exceed the target, with the resulting false positive rate.
+//
+// It returns an error under the same conditions as Fold, or if the filter
+// already exceeds targetFPR.
+func (bf *BloomFilter) Shrink(targetFPR float64) (factor uint64, fpr float64, err error) {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	if err := bf.checkFoldable(); err != nil {
+		return 0, 0, err
+	}
+	words, m := bf.storage.Words(), bf.m
+	fpr = wordsFPR(words, m, bf.k, bf.probe)
+	if fpr > targetFPR {
+		return 0, 0, fmt.Errorf("false positive rate %g already exceeds the target %g", fpr, targetFPR)
+	}
+
+	// Folding never clears a bit, so the rate only grows with each halving
+	// and the first one over the target ends the search.
+	factor = 1
+	for size := bf.sliceSize(); size > 1; size /= 2 {
+		folded := foldWords(words, m, bf.slices(), 2)
+		foldedFPR := wordsFPR(folded, m/2, bf.k, bf.probe)
+		if foldedFPR > targetFPR {
+			break
+		}
+		words, m, fpr = folded, m/2, foldedFPR
+		factor *= 2
+	}
+	if factor > 1 {
+		bf.setFolded(words, m)
+	}
+	return factor, fpr, nil
+}
+
+func (bf *BloomFilter) checkFoldable() error {
+	if bf.probe&probeReduction != probeMask {
+		return fmt.Errorf("cannot fold a filter without mask probing")
+	}
+	if _, ok := bf.heap(); !ok {
+		return fmt.Errorf("cannot fold a filter not stored in memory")
+	}
+	return nil
+}
+
+// slices returns the number of slices probed independently: k for a
+// partitioned filter and 1 otherwise.
+func (bf *BloomFilter) slices() uint64 {
+	if bf.probe&probePartitioned != 0 {
+		return bf.k
+	}
+	return 1
+}
+
+func (bf *BloomFilter) sliceSize() uint64 {
+	return bf.m / bf.slices()
+}
+
+// setFolded replaces the bit array with the folded words of m bits.
+func (bf *BloomFilter) setFolded(words []uint64, m uint64) {
+	heap, _ := storage.NewHeapFromWords(words, m)
+	bf.storage = heap
+	bf.m = m
+	bf.epoch = 0
+	bf.applied = 0
+}
+
+// foldWords folds the words of a bit array of m bits, split into the given
+// number of slices of a power of two bits, by factor, OR-ing bit j of each
+// slice into bit j mod (size/factor) of the same slice. The input is not
+// modified.
+func foldWords(words []uint64, m, slices, factor uint64) []uint64 {
+	size := m / slices
+	folded := size / factor
+	out := make([]uint64, (folded*slices+63)/64)
+
+	if slices == 1 && folded%64 == 0 {
+		n := folded / 64
+		for part := range factor {
+			for i, w := range words[part*n : (part+1)*n] {
+				out[i] |= w
+			}
+		}
+		return out
+	}
+
+	for i, w := range words {
+		for w != 0 {
+			pos := uint64(i)*64 + uint64(bits.TrailingZeros64(w))
+			w &= w - 1
+			to := (pos/size)*folded + (pos%size)&(folded-1)
+			out[to/64] |= 1 << (to % 64)
+		}
+	}
+	return out
+}
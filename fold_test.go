@@ -0,0 +1,150 @@
+package bitbloom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+func TestBloomFilter_Fold(t *testing.T) {
+	bf, _ := NewPowerOfTwo(1000, 0.001)
+	for i := range 1000 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	m, before := bf.m, bf.FalsePositiveRate()
+
+	fpr, err := bf.Fold(4)
+	if err != nil {
+		t.Fatalf("Fold failed: %v", err)
+	}
+	if bf.m != m/4 || bf.count != 1000 {
+		t.Errorf("Expected m=%d and count 1000 after folding, got m=%d count=%d", m/4, bf.m, bf.count)
+	}
+	if fpr != bf.FalsePositiveRate() || fpr <= before {
+		t.Errorf("Expected the reported rate %g to match the filter's %g and exceed %g",
+			fpr, bf.FalsePositiveRate(), before)
+	}
+	for i := range 1000 {
+		if !bf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d after folding", i)
+		}
+	}
+
+	// A filter built at the folded size holds the same bits.
+	small := NewPowerOfTwoWithParams(m/4, bf.k)
+	for i := range 1000 {
+		small.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	if !small.Equal(bf) {
+		t.Error("Expected the folded filter to equal one built at the folded size")
+	}
+
+	if _, err := bf.Fold(3); err == nil {
+		t.Error("Expected error for a factor that is not a power of two")
+	}
+	if _, err := bf.Fold(bf.m * 2); err == nil {
+		t.Error("Expected error folding below one bit")
+	}
+}
+
+func TestBloomFilter_FoldSmall(t *testing.T) {
+	// Folds below a word, and of partitioned slices, go bit by bit.
+	bf := NewPowerOfTwoWithParams(64, 3)
+	bf.Add([]byte("alice"))
+	if _, err := bf.Fold(8); err != nil {
+		t.Fatalf("Fold failed: %v", err)
+	}
+	if bf.m != 8 || !bf.Test([]byte("alice")) {
+		t.Errorf("Expected alice in a filter of 8 bits, got m=%d", bf.m)
+	}
+
+	data := make([]byte, headerSize+4*64)
+	putHeader(data, header{m: 4 * 512, k: 4, probe: probePartitioned | probeEnhanced | probeMask})
+	pf, err := UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	for i := range 100 {
+		pf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	fpr, err := pf.Fold(16)
+	if err != nil {
+		t.Fatalf("Fold failed: %v", err)
+	}
+	if pf.m != 4*32 || fpr != pf.Stats().FalsePositiveRate {
+		t.Errorf("Expected 4 slices of 32 bits and a rate of %g, got m=%d and %g",
+			pf.Stats().FalsePositiveRate, pf.m, fpr)
+	}
+	for i := range 100 {
+		if !pf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d after folding slices", i)
+		}
+	}
+}
+
+func TestBloomFilter_FoldUnsupported(t *testing.T) {
+	bf, _ := New(1000, 0.01)
+	if _, err := bf.Fold(2); err == nil {
+		t.Error("Expected error folding a filter without mask probing")
+	}
+	if _, _, err := bf.Shrink(0.1); err == nil {
+		t.Error("Expected error shrinking a filter without mask probing")
+	}
+	view, _ := storage.NewView(make([]byte, 16), 128)
+	mapped := NewWithStorage(view, 3)
+	mapped.setProbe(probeEnhanced | probeMask)
+	if _, err := mapped.Fold(2); err == nil {
+		t.Error("Expected error folding a filter not stored in memory")
+	}
+}
+
+func TestBloomFilter_Shrink(t *testing.T) {
+	bf, _ := NewPowerOfTwo(1000, 0.01)
+	// Over-provisioned by a factor of 20.
+	for i := range 50 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	m := bf.m
+
+	factor, fpr, err := bf.Shrink(0.01)
+	if err != nil {
+		t.Fatalf("Shrink failed: %v", err)
+	}
+	if factor < 2 || bf.m != m/factor {
+		t.Errorf("Expected a fold, got factor %d and m=%d", factor, bf.m)
+	}
+	if fpr > 0.01 || fpr != bf.FalsePositiveRate() {
+		t.Errorf("Expected a rate within the target matching the filter's, got %g and %g", fpr, bf.FalsePositiveRate())
+	}
+
+	// One more halving would have exceeded the target.
+	next := bf.Clone()
+	if fpr, err := next.Fold(2); err == nil && fpr <= 0.01 {
+		t.Errorf("Expected folding once more to exceed the target, got %g", fpr)
+	}
+	for i := range 50 {
+		if !bf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d after shrinking", i)
+		}
+	}
+
+	if factor, _, err := bf.Shrink(fpr); err != nil || factor != 1 {
+		t.Errorf("Expected no further fold at the same target, got %d, %v", factor, err)
+	}
+	if _, _, err := bf.Shrink(fpr / 2); err == nil {
+		t.Error("Expected error for a target the filter already exceeds")
+	}
+}
+
+func TestBloomFilter_ShrinkEmpty(t *testing.T) {
+	bf := NewPowerOfTwoWithParams(1024, 3)
+	factor, fpr, err := bf.Shrink(0.01)
+	if err != nil || factor != 1024 || bf.m != 1 || fpr != 0 {
+		t.Errorf("Expected an empty filter to fold to one bit, got factor %d m=%d rate %g, %v",
+			factor, bf.m, fpr, err)
+	}
+	if bf.Test([]byte("alice")) {
+		t.Error("Expected the folded empty filter to stay empty")
+	}
+}
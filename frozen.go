@@ -0,0 +1,250 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"unsafe"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+// littleEndian reports whether the host stores integers little-endian, in
+// which case serialized words can be read in place.
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// FrozenFilter is an immutable Bloom filter, created with Freeze or View.
+// It takes no locks, so any number of goroutines can share it without
+// contending on a cache line, and Test does not allocate.
+type FrozenFilter struct {
+	// words holds the bitset when it can be read as words in place or was
+	// copied; otherwise data holds the little-endian serialized words.
+	words  []uint64
+	data   []byte
+	scheme hasher.Scheme
+	probe  probe
+	m      uint64
+	k      uint64
+	count  uint64
+}
+
+// View returns a read-only filter over data, which must be in the format
+// produced by MarshalBinary, without copying the bitset. The header is
+// validated as by UnmarshalBinary. data must not be modified while the
+// filter is in use.
+//
+// When data is 8-byte aligned on a little-endian host, the bitset is read
+// as words in place; otherwise Test reads the individual bytes.
+func View(data []byte) (*FrozenFilter, error) {
+	return UnmarshalOptions{}.View(data)
+}
+
+// View is like the package-level View, validating data against the
+// options.
+func (o UnmarshalOptions) View(data []byte) (*FrozenFilter, error) {
+	h, bitset, err := o.decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FrozenFilter{scheme: h.scheme, probe: h.probe, m: h.m, k: h.k, count: h.count}
+	if littleEndian && uintptr(unsafe.Pointer(&bitset[0]))%8 == 0 {
+		f.words = unsafe.Slice((*uint64)(unsafe.Pointer(&bitset[0])), len(bitset)/8)
+	} else {
+		f.data = bitset
+	}
+	return f, nil
+}
+
+// Test checks whether an item is possibly in the filter.
+func (f *FrozenFilter) Test(item []byte) bool {
+	d := f.scheme.Sum(item)
+
+	if f.words != nil {
+		for i := range f.k {
+			pos := f.probe.location(f.scheme, d, i, f.m, f.k)
+			if f.words[pos/64]&(1<<(pos%64)) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i := range f.k {
+		pos := f.probe.location(f.scheme, d, i, f.m, f.k)
+		if f.data[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// M returns the number of bits in the filter.
+func (f *FrozenFilter) M() uint64 {
+	return f.m
+}
+
+// K returns the number of hash functions.
+func (f *FrozenFilter) K() uint64 {
+	return f.k
+}
+
+// Count returns the number of items added before the filter was frozen.
+func (f *FrozenFilter) Count() uint64 {
+	return f.count
+}
+
+// Freeze returns an immutable copy of the filter for read-only use. The
+// copy answers Test exactly as bf did at the time of the call, and later
+// changes to bf do not affect it.
+func (bf *BloomFilter) Freeze() *FrozenFilter {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	return &FrozenFilter{
+		words:  append([]uint64(nil), bf.storage.Words()...),
+		scheme: bf.scheme,
+		probe:  bf.probe,
+		m:      bf.m,
+		k:      bf.k,
+		count:  bf.count,
+	}
+}
+
+// Snapshot returns a point-in-time, read-only copy of the filter, for
+// consistent backups or comparisons while writers keep adding. Unlike
+// Freeze, it does not copy the bit array of a filter held in memory but
+// shares it: the filter copies the array before its next change instead,
+// so taking a snapshot is cheap and the first writer after it pays for the
+// copy. Filters over other storages are copied as by Freeze.
+func (bf *BloomFilter) Snapshot() *FrozenFilter {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	var words []uint64
+	if heap, ok := bf.storage.(*storage.Heap); ok {
+		words = heap.Share()
+	} else {
+		words = append([]uint64(nil), bf.storage.Words()...)
+	}
+	return &FrozenFilter{
+		words:  words,
+		scheme: bf.scheme,
+		probe:  bf.probe,
+		m:      bf.m,
+		k:      bf.k,
+		count:  bf.count,
+	}
+}
+
+// Thaw returns a new mutable filter with the same contents. The frozen
+// filter is unchanged and remains usable.
+func (f *FrozenFilter) Thaw() *BloomFilter {
+	bf, _ := newBloomFilterWithScheme(f.m, f.k, f.scheme)
+	bf.setProbe(f.probe)
+	words := bf.storage.Words()
+	for i := range words {
+		words[i] = f.word(i)
+	}
+	bf.count = f.count
+	return bf
+}
+
+func (f *FrozenFilter) word(i int) uint64 {
+	if f.words != nil {
+		return f.words[i]
+	}
+	return binary.LittleEndian.Uint64(f.data[i*8:])
+}
+
+// allWords returns the bitset as words, decoding them if they are not held
+// as words.
+func (f *FrozenFilter) allWords() []uint64 {
+	if f.words != nil {
+		return f.words
+	}
+	words := make([]uint64, f.numWords())
+	for i := range words {
+		words[i] = f.word(i)
+	}
+	return words
+}
+
+func (f *FrozenFilter) numWords() int {
+	return int((f.m + 63) / 64)
+}
+
+func (f *FrozenFilter) setBits() uint64 {
+	var count uint64
+	for i := range f.numWords() {
+		count += uint64(bits.OnesCount64(f.word(i)))
+	}
+	return count
+}
+
+// EstimatedFillRatio returns the theoretical fill ratio of the bit array,
+// as BloomFilter.EstimatedFillRatio.
+func (f *FrozenFilter) EstimatedFillRatio() float64 {
+	return 1 - math.Exp(-float64(f.k*f.count)/float64(f.m))
+}
+
+// ActualFillRatio returns the fraction of bits set.
+func (f *FrozenFilter) ActualFillRatio() float64 {
+	return float64(f.setBits()) / float64(f.m)
+}
+
+// FalsePositiveRate estimates the false positive rate from the actual fill
+// ratio, as BloomFilter.FalsePositiveRate.
+func (f *FrozenFilter) FalsePositiveRate() float64 {
+	if f.probe&probePartitioned != 0 {
+		return partitionedFPR(f.allWords(), f.m, f.k)
+	}
+	return math.Pow(f.ActualFillRatio(), float64(f.k))
+}
+
+// MemoryUsage returns the size of the bit array in bytes. For a filter
+// created with View this memory belongs to the viewed slice.
+func (f *FrozenFilter) MemoryUsage() int {
+	return f.numWords() * 8
+}
+
+// Stats returns the filter's statistics. A frozen filter does not count
+// calls to Test, as that would reintroduce shared writes, so Adds and Tests
+// are zero.
+func (f *FrozenFilter) Stats() Stats {
+	setBits := f.setBits()
+	fillRatio := float64(setBits) / float64(f.m)
+
+	s := Stats{
+		M:                  f.m,
+		K:                  f.k,
+		Count:              f.count,
+		SetBits:            setBits,
+		EstimatedCount:     estimateCardinality(f.m, f.k, setBits),
+		EstimatedFillRatio: f.EstimatedFillRatio(),
+		ActualFillRatio:    fillRatio,
+		FalsePositiveRate:  math.Pow(fillRatio, float64(f.k)),
+		MemoryUsage:        f.MemoryUsage(),
+	}
+	if f.probe&probePartitioned != 0 {
+		s.partition(f.allWords())
+	}
+	return s
+}
+
+// MarshalBinary serializes the filter in the format of
+// BloomFilter.MarshalBinary.
+func (f *FrozenFilter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, headerSize+f.numWords()*8)
+	putHeader(buf, header{m: f.m, k: f.k, count: f.count, scheme: f.scheme, probe: f.probe})
+
+	if f.words == nil {
+		copy(buf[headerSize:], f.data)
+		return buf, nil
+	}
+	for i, word := range f.words {
+		binary.LittleEndian.PutUint64(buf[headerSize+i*8:], word)
+	}
+	return buf, nil
+}
@@ -0,0 +1,183 @@
+package bitbloom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	bf, _ := New(1000, 0.01)
+	for i := range 1000 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	data, _ := bf.MarshalBinary()
+
+	// Views over an aligned and an unaligned copy take both paths.
+	unaligned := make([]byte, len(data)+1)[1:]
+	copy(unaligned, data)
+	for name, buf := range map[string][]byte{"aligned": data, "unaligned": unaligned} {
+		f, err := View(buf)
+		if err != nil {
+			t.Fatalf("%s: View failed: %v", name, err)
+		}
+		if f.M() != bf.m || f.K() != bf.k || f.Count() != 1000 {
+			t.Errorf("%s: parameters not preserved", name)
+		}
+		for i := range 2000 {
+			item := fmt.Appendf(nil, "item-%d", i)
+			if f.Test(item) != bf.Test(item) {
+				t.Fatalf("%s: Test(%s) differs from the original filter", name, item)
+			}
+		}
+	}
+}
+
+func TestView_Schemes(t *testing.T) {
+	for _, bf := range []*BloomFilter{NewBitsAndBlooms(1000, 5), mustGuava(t)} {
+		bf.Add([]byte("foo"))
+		data, _ := bf.MarshalBinary()
+		f, err := View(data)
+		if err != nil {
+			t.Fatalf("View failed: %v", err)
+		}
+		if !f.Test([]byte("foo")) || f.Test([]byte("bar")) != bf.Test([]byte("bar")) {
+			t.Errorf("%s: view answers differently", bf.scheme)
+		}
+	}
+}
+
+func mustGuava(t *testing.T) *BloomFilter {
+	bf, err := NewGuava(100, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bf
+}
+
+func TestView_Invalid(t *testing.T) {
+	data, _ := NewWithParams(100, 3).MarshalBinary()
+	for name, buf := range map[string][]byte{
+		"short header": data[:10],
+		"short bitset": data[:len(data)-8],
+		"zero m":       append(make([]byte, 8), data[8:]...),
+	} {
+		if _, err := View(buf); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestFrozenFilter_TestDoesNotAllocate(t *testing.T) {
+	bf, _ := New(1000, 0.01)
+	bf.Add([]byte("foo"))
+	data, _ := bf.MarshalBinary()
+	f, _ := View(data)
+
+	item := []byte("foo")
+	if allocs := testing.AllocsPerRun(100, func() { f.Test(item) }); allocs != 0 {
+		t.Errorf("Test allocated %v times", allocs)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	bf, _ := New(1000, 0.01)
+	for i := range 500 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+
+	f := bf.Freeze()
+	bf.Add([]byte("after"))
+	if f.Test([]byte("after")) {
+		t.Error("Frozen filter saw an item added after Freeze")
+	}
+	for i := range 500 {
+		if !f.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d", i)
+		}
+	}
+
+	stats := f.Stats()
+	if stats.Count != 500 || stats.M != bf.m || stats.K != bf.k || stats.Tests != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.ActualFillRatio != f.ActualFillRatio() || stats.FalsePositiveRate != f.FalsePositiveRate() {
+		t.Error("Stats disagree with the individual methods")
+	}
+
+	thawed := f.Thaw()
+	thawed.Add([]byte("thawed"))
+	if !thawed.Test([]byte("item-1")) || !thawed.Test([]byte("thawed")) || f.Test([]byte("thawed")) {
+		t.Error("Thaw did not return an independent mutable copy")
+	}
+}
+
+func TestFrozenFilter_MarshalBinary(t *testing.T) {
+	bf := NewBitsAndBlooms(1000, 4)
+	bf.Add([]byte("foo"))
+	want, _ := bf.MarshalBinary()
+
+	got, _ := bf.Freeze().MarshalBinary()
+	if string(got) != string(want) {
+		t.Error("Frozen filter serialized differently")
+	}
+
+	unaligned := make([]byte, len(want)+1)[1:]
+	copy(unaligned, want)
+	view, _ := View(unaligned)
+	got, _ = view.MarshalBinary()
+	if string(got) != string(want) {
+		t.Error("Unaligned view serialized differently")
+	}
+	if thawed := view.Thaw(); !thawed.Test([]byte("foo")) || thawed.scheme != bf.scheme {
+		t.Error("Thawed view lost its contents or scheme")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	bf, _ := New(1000, 0.01)
+	bf.Add([]byte("before"))
+
+	snap := bf.Snapshot()
+	again := bf.Snapshot()
+	bf.Add([]byte("after"))
+
+	for _, s := range []*FrozenFilter{snap, again} {
+		if !s.Test([]byte("before")) || s.Test([]byte("after")) || s.Count() != 1 {
+			t.Error("Expected the snapshot to keep the filter's contents when it was taken")
+		}
+	}
+	if !bf.Test([]byte("after")) {
+		t.Error("Expected the filter to keep accepting adds")
+	}
+
+	bf.Reset()
+	if !snap.Test([]byte("before")) {
+		t.Error("Expected Reset not to affect a snapshot")
+	}
+}
+
+func TestSnapshot_ConcurrentWriters(t *testing.T) {
+	bf, _ := New(10000, 0.01)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 2000 {
+			bf.Add(fmt.Appendf(nil, "item-%d", i))
+		}
+	}()
+
+	for range 50 {
+		snap := bf.Snapshot()
+		data, _ := snap.MarshalBinary()
+		decoded, err := UnmarshalBinary(data)
+		if err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		if decoded.count != snap.Count() || decoded.storage.Count() != snap.Stats().SetBits {
+			t.Fatal("Expected a consistent snapshot")
+		}
+	}
+	wg.Wait()
+}
@@ -3,6 +3,7 @@ module github.com/umang-sinha/bitbloom
 go 1.24.3
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/spaolacci/murmur3 v1.1.0
 	github.com/stretchr/testify v1.10.0
 )
@@ -0,0 +1,153 @@
+package bitbloom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// Guava strategy ordinals, as written by BloomFilter.writeTo.
+const (
+	guavaMitz32Ordinal = 0
+	guavaMitz64Ordinal = 1
+)
+
+// NewGuava creates a Bloom filter sized and hashed like Guava's
+// BloomFilter.create(funnel, n, p), using the default MURMUR128_MITZ_64
+// strategy, so it can be exchanged with JVM services using WriteGuava and
+// ReadGuava.
+//
+// Items are hashed as the raw bytes a Guava funnel would feed to the hasher:
+// Funnels.byteArrayFunnel() and Funnels.stringFunnel(UTF_8) match Add and
+// Test with the byte array or UTF-8 string respectively.
+func NewGuava(n uint64, p float64) (*BloomFilter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+
+	// BloomFilter.optimalNumOfBits and optimalNumOfHashFunctions.
+	n = max(n, 1)
+	numBits := uint64(-float64(n) * math.Log(p) / (math.Log(2) * math.Log(2)))
+	k := max(1, uint64(math.Round(float64(numBits)/float64(n)*math.Log(2))))
+
+	// Guava's bit array is rounded up to whole longs and probes modulo its
+	// full size.
+	m := (max(numBits, 1) + 63) / 64 * 64
+	return newBloomFilterWithScheme(m, k, hasher.SchemeGuavaMitz64)
+}
+
+// WriteGuava writes the filter in the format of Guava's BloomFilter.writeTo,
+// readable with BloomFilter.readFrom:
+//
+//	Offset  Size (bytes)  Description
+//	------  ------------- ----------------------------------------------
+//	0       1             strategy ordinal (0 = MITZ_32, 1 = MITZ_64)
+//	1       1             number of hash functions (unsigned)
+//	2       4             number of 64-bit words w
+//	6       8 * w         bit array words
+//
+// All fields are big-endian. The filter must have been created with
+// NewGuava or read with ReadGuava. The item count is not part of the format
+// and is lost.
+func (bf *BloomFilter) WriteGuava(w io.Writer) (int64, error) {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	var ordinal byte
+	switch bf.scheme {
+	case hasher.SchemeGuavaMitz32:
+		ordinal = guavaMitz32Ordinal
+	case hasher.SchemeGuavaMitz64:
+		ordinal = guavaMitz64Ordinal
+	default:
+		return 0, fmt.Errorf("cannot export a filter using the %s hashing scheme in Guava format", bf.scheme)
+	}
+	if bf.probe != 0 {
+		return 0, fmt.Errorf("cannot export a partitioned filter in Guava format")
+	}
+	if bf.k > math.MaxUint8 {
+		return 0, fmt.Errorf("guava supports at most 255 hash functions, filter has %d", bf.k)
+	}
+	if bf.m%64 != 0 {
+		return 0, fmt.Errorf("guava filters use whole 64-bit words, m = %d is not a multiple of 64", bf.m)
+	}
+
+	data := bf.storage.Words()
+	if len(data) > math.MaxInt32 {
+		return 0, fmt.Errorf("filter too large for Guava format: %d words", len(data))
+	}
+
+	bw := bufio.NewWriter(w)
+	var buf [8]byte
+	bw.WriteByte(ordinal)
+	bw.WriteByte(byte(bf.k))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(data)))
+	bw.Write(buf[:4])
+	for _, word := range data {
+		binary.BigEndian.PutUint64(buf[:], word)
+		bw.Write(buf[:])
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(6 + 8*len(data)), nil
+}
+
+// ReadGuava reads a filter written by Guava's BloomFilter.writeTo or by
+// WriteGuava. Both the MURMUR128_MITZ_32 and MURMUR128_MITZ_64 strategies
+// are supported, and the returned filter answers Test exactly as the Guava
+// filter answers mightContain for the same funnelled bytes.
+//
+// As the format does not record the number of items added, the count of the
+// returned filter is estimated from the number of bits set.
+func ReadGuava(r io.Reader) (*BloomFilter, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading Guava header: %w", err)
+	}
+
+	var scheme hasher.Scheme
+	switch header[0] {
+	case guavaMitz32Ordinal:
+		scheme = hasher.SchemeGuavaMitz32
+	case guavaMitz64Ordinal:
+		scheme = hasher.SchemeGuavaMitz64
+	default:
+		return nil, fmt.Errorf("unknown Guava strategy ordinal %d", int8(header[0]))
+	}
+
+	k := uint64(header[1])
+	words := int32(binary.BigEndian.Uint32(header[2:6]))
+	if k == 0 || words <= 0 {
+		return nil, fmt.Errorf("invalid parameters in Guava data: k = %d, words = %d", k, words)
+	}
+
+	var data bytes.Buffer
+	n, err := io.CopyN(&data, r, int64(words)*8)
+	if err != nil {
+		return nil, fmt.Errorf("reading Guava bit array: read %d of %d bytes: %w", n, int64(words)*8, err)
+	}
+
+	m := uint64(words) * 64
+	bf, err := newBloomFilterWithScheme(m, k, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := data.Bytes()
+	bitsetData := bf.storage.Words()
+	var setBits uint64
+	for i := range bitsetData {
+		bitsetData[i] = binary.BigEndian.Uint64(raw[i*8:])
+		setBits += uint64(bits.OnesCount64(bitsetData[i]))
+	}
+	bf.count = uint64(estimateCardinality(m, k, setBits) + 0.5)
+
+	return bf, nil
+}
@@ -0,0 +1,146 @@
+package bitbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/spaolacci/murmur3"
+)
+
+func TestNewGuava_Sizing(t *testing.T) {
+	// Guava: BloomFilter.create(funnel, 1000, 0.03) has 7298 bits in 115
+	// longs and 5 hash functions.
+	bf, err := NewGuava(1000, 0.03)
+	if err != nil {
+		t.Fatalf("NewGuava failed: %v", err)
+	}
+	if bf.m != 115*64 || bf.k != 5 {
+		t.Errorf("Expected m=%d k=5, got m=%d k=%d", 115*64, bf.m, bf.k)
+	}
+
+	if _, err := NewGuava(1000, 0); err == nil {
+		t.Error("Expected error for invalid false positive rate")
+	}
+}
+
+// guavaIndexes computes the bit indexes of MURMUR128_MITZ_64 using Java's
+// signed long arithmetic.
+func guavaIndexes(item []byte, k int, bitSize int64) []int64 {
+	h1, h2 := murmur3.Sum128(item)
+	hash1, hash2 := int64(h1), int64(h2)
+
+	indexes := make([]int64, k)
+	combined := hash1
+	for i := range k {
+		indexes[i] = (combined & math.MaxInt64) % bitSize
+		combined += hash2
+	}
+	return indexes
+}
+
+func TestReadGuava_HandBuilt(t *testing.T) {
+	const words, k = 4, 3
+	bitSize := int64(words * 64)
+
+	longs := make([]uint64, words)
+	for _, key := range []string{"foo", "bar"} {
+		for _, idx := range guavaIndexes([]byte(key), k, bitSize) {
+			longs[idx/64] |= 1 << (idx % 64)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write([]byte{guavaMitz64Ordinal, k})
+	binary.Write(&buf, binary.BigEndian, int32(words))
+	binary.Write(&buf, binary.BigEndian, longs)
+
+	bf, err := ReadGuava(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadGuava failed: %v", err)
+	}
+	if !bf.Test([]byte("foo")) || !bf.Test([]byte("bar")) {
+		t.Error("Expected keys set with Guava's indexes to be present")
+	}
+
+	out := bytes.Buffer{}
+	if _, err := bf.WriteGuava(&out); err != nil {
+		t.Fatalf("WriteGuava failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), buf.Bytes()) {
+		t.Error("Re-exported filter differs from the input")
+	}
+}
+
+func TestGuava_RoundTrip(t *testing.T) {
+	bf, _ := NewGuava(1000, 0.01)
+	for i := range 1000 {
+		bf.Add([]byte(fmt.Sprintf("key-%d", i)))
+	}
+
+	var buf bytes.Buffer
+	n, err := bf.WriteGuava(&buf)
+	if err != nil {
+		t.Fatalf("WriteGuava failed: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Reported %d bytes written, wrote %d", n, buf.Len())
+	}
+	if b := buf.Bytes(); b[0] != guavaMitz64Ordinal || b[1] != byte(bf.k) {
+		t.Errorf("Unexpected header bytes % x", b[:6])
+	}
+
+	decoded, err := ReadGuava(&buf)
+	if err != nil {
+		t.Fatalf("ReadGuava failed: %v", err)
+	}
+	for i := range 1000 {
+		if !decoded.Test([]byte(fmt.Sprintf("key-%d", i))) {
+			t.Fatalf("Expected key-%d to be present", i)
+		}
+	}
+	for i := range 1000 {
+		item := []byte(fmt.Sprintf("probe-%d", i))
+		if bf.Test(item) != decoded.Test(item) {
+			t.Fatalf("Decoded filter answers differently for %s", item)
+		}
+	}
+}
+
+func TestGuava_Mitz32(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{guavaMitz32Ordinal, 4})
+	binary.Write(&buf, binary.BigEndian, int32(2))
+	buf.Write(make([]byte, 16))
+
+	bf, err := ReadGuava(&buf)
+	if err != nil {
+		t.Fatalf("ReadGuava failed: %v", err)
+	}
+	bf.Add([]byte("foo"))
+	if !bf.Test([]byte("foo")) {
+		t.Error("Expected foo to be present")
+	}
+	for _, h := range bf.hasher.Hashes([]byte("foo"), 4, 128) {
+		if h >= 128 {
+			t.Errorf("Index %d out of range", h)
+		}
+	}
+}
+
+func TestGuava_Errors(t *testing.T) {
+	if _, err := NewWithParams(128, 3).WriteGuava(&bytes.Buffer{}); err == nil {
+		t.Error("Expected error exporting a filter with the murmur3 scheme")
+	}
+	if _, err := ReadGuava(bytes.NewReader([]byte{7, 3, 0, 0, 0, 1})); err == nil {
+		t.Error("Expected error for unknown strategy ordinal")
+	}
+	if _, err := ReadGuava(bytes.NewReader([]byte{1, 3, 0x7f, 0xff, 0xff, 0xff})); err == nil {
+		t.Error("Expected error for missing bit array")
+	}
+	if _, err := ReadGuava(bytes.NewReader([]byte{1, 3, 0xff, 0xff, 0xff, 0xff})); err == nil {
+		t.Error("Expected error for negative word count")
+	}
+}
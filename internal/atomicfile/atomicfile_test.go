@@ -0,0 +1,36 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "filter.bloom")
+
+	for _, data := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(data), 0o640); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != data {
+			t.Errorf("Expected %q, got %q, %v", data, got, err)
+		}
+	}
+
+	info, _ := os.Stat(path)
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+		t.Errorf("Expected permissions 0640, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %d entries", len(entries))
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "f"), nil, 0o644); err == nil {
+		t.Error("Expected error writing into a missing directory")
+	}
+}
@@ -0,0 +1,30 @@
+package hasher
+
+import (
+	"github.com/spaolacci/murmur3"
+)
+
+// BitsAndBloomsHasher reproduces the bit locations of
+// github.com/bits-and-blooms/bloom/v3.
+//
+// Four base hashes are derived from murmur3-128 of the data and of the data
+// followed by a single 0x01 byte, and location i is
+//
+//	h[i%2] + i*h[2+(((i+(i%2))%4)/2)]  mod m
+type BitsAndBloomsHasher struct{}
+
+func (bh *BitsAndBloomsHasher) Hashes(data []byte, k, m uint64) []uint64 {
+	d := murmur3.New128()
+	d.Write(data)
+	h1, h2 := d.Sum128()
+	d.Write([]byte{1})
+	h3, h4 := d.Sum128()
+	h := [4]uint64{h1, h2, h3, h4}
+
+	hashes := make([]uint64, k)
+	for i := uint64(0); i < k; i++ {
+		hashes[i] = (h[i%2] + i*h[2+(((i+(i%2))%4)/2)]) % m
+	}
+
+	return hashes
+}
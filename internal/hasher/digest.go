@@ -0,0 +1,182 @@
+package hasher
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// Digest holds the hashes of an item from which its bit locations are
+// derived. Computing a digest once and then each location with
+// Scheme.Location does not allocate, unlike Hasher.Hashes.
+type Digest [4]uint64
+
+// Sum returns the digest of data under s. Unknown schemes give a zero
+// digest.
+func (s Scheme) Sum(data []byte) Digest {
+	switch s {
+	case SchemeMurmur, SchemeGuavaMitz64, SchemeGuavaMitz32:
+		h1, h2 := sum128(data, false)
+		return Digest{h1, h2}
+	case SchemeBitsAndBlooms:
+		// The second pair hashes the data followed by a single 0x01 byte.
+		h1, h2 := sum128(data, false)
+		h3, h4 := sum128(data, true)
+		return Digest{h1, h2, h3, h4}
+	}
+	return Digest{}
+}
+
+// DigestLen returns the number of words of a digest that s uses. The
+// remaining words of a Digest are zero.
+func (s Scheme) DigestLen() int {
+	if s == SchemeBitsAndBlooms {
+		return 4
+	}
+	return 2
+}
+
+// Location returns the i-th of an item's bit locations in a filter of m
+// bits, for i from 0 to k-1. The digest must come from Sum with the same
+// scheme.
+func (s Scheme) Location(d Digest, i, m uint64) uint64 {
+	switch s {
+	case SchemeMurmur:
+		return (d[0] + i*d[1]) % m
+	case SchemeBitsAndBlooms:
+		return (d[i%2] + i*d[2+(((i+(i%2))%4)/2)]) % m
+	case SchemeGuavaMitz64:
+		return ((d[0] + i*d[1]) & math.MaxInt64) % m
+	case SchemeGuavaMitz32:
+		h1, h2 := int32(d[0]), int32(d[0]>>32)
+		combined := h1 + int32(i+1)*h2
+		if combined < 0 {
+			combined = ^combined
+		}
+		return uint64(combined) % m
+	}
+	return 0
+}
+
+const (
+	murmurC1 = 0x87c37b91114253d5
+	murmurC2 = 0x4cf5ad432745937f
+)
+
+// sum128 is murmur3 x64 128-bit with a zero seed, as murmur3.Sum128, of
+// data optionally followed by a 0x01 byte. It does not allocate.
+func sum128(data []byte, suffix bool) (h1, h2 uint64) {
+	length := uint64(len(data))
+
+	for ; len(data) >= 16; data = data[16:] {
+		h1, h2 = murmurBlock(h1, h2, binary.LittleEndian.Uint64(data), binary.LittleEndian.Uint64(data[8:]))
+	}
+
+	var tail [16]byte
+	n := copy(tail[:], data)
+	if suffix {
+		tail[n] = 1
+		n++
+		length++
+	}
+	if n == 16 {
+		h1, h2 = murmurBlock(h1, h2, binary.LittleEndian.Uint64(tail[:]), binary.LittleEndian.Uint64(tail[8:]))
+		n = 0
+	}
+
+	// The tail is zero padded, so whole words give the same mixing input.
+	if n > 8 {
+		k2 := binary.LittleEndian.Uint64(tail[8:])
+		k2 *= murmurC2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+	}
+	if n > 0 {
+		k1 := binary.LittleEndian.Uint64(tail[:])
+		k1 *= murmurC1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+	}
+
+	h1 ^= length
+	h2 ^= length
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+func murmurBlock(h1, h2, k1, k2 uint64) (uint64, uint64) {
+	k1 *= murmurC1
+	k1 = bits.RotateLeft64(k1, 31)
+	k1 *= murmurC2
+	h1 ^= k1
+	h1 = bits.RotateLeft64(h1, 27)
+	h1 += h2
+	h1 = h1*5 + 0x52dce729
+
+	k2 *= murmurC2
+	k2 = bits.RotateLeft64(k2, 33)
+	k2 *= murmurC1
+	h2 ^= k2
+	h2 = bits.RotateLeft64(h2, 31)
+	h2 += h1
+	h2 = h2*5 + 0x38495ab5
+	return h1, h2
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// Sum32 is murmur3 x86 32-bit with the given seed, as
+// murmur3.Sum32WithSeed, reading data without unsafe pointer arithmetic.
+func Sum32(data []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+
+	h := seed
+	length := uint32(len(data))
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= length
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
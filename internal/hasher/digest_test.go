@@ -0,0 +1,90 @@
+package hasher
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/spaolacci/murmur3"
+)
+
+func TestSum128_MatchesMurmur3(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for n := range 70 {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(r.Uint32())
+		}
+
+		w1, w2 := murmur3.Sum128(data)
+		if h1, h2 := sum128(data, false); h1 != w1 || h2 != w2 {
+			t.Errorf("len %d: sum128 = %x %x, want %x %x", n, h1, h2, w1, w2)
+		}
+
+		w1, w2 = murmur3.Sum128(append(data, 1))
+		if h1, h2 := sum128(data, true); h1 != w1 || h2 != w2 {
+			t.Errorf("len %d with suffix: sum128 = %x %x, want %x %x", n, h1, h2, w1, w2)
+		}
+	}
+}
+
+func TestSum32(t *testing.T) {
+	tests := []struct {
+		data string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"", 0xffffffff, 0x81f16f39},
+		{"\x00\x00\x00\x00", 0, 0x2362f9de},
+		{"a", 0x9747b28c, 0x7fa09ea6},
+		{"abc", 0, 0xb3dd93fa},
+		{"aaaa", 0x9747b28c, 0x5a97808a},
+		{"Hello, world!", 0x9747b28c, 0x24884cba},
+		{"The quick brown fox jumps over the lazy dog", 0x9747b28c, 0x2fa826cd},
+	}
+	for _, tt := range tests {
+		if got := Sum32([]byte(tt.data), tt.seed); got != tt.want {
+			t.Errorf("Sum32(%q, %#x) = %#x, want %#x", tt.data, tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestScheme_LocationMatchesHasher(t *testing.T) {
+	for _, s := range []Scheme{SchemeMurmur, SchemeBitsAndBlooms, SchemeGuavaMitz32, SchemeGuavaMitz64} {
+		h, _ := ForScheme(s)
+		for _, key := range []string{"", "a", "hello world", "a much longer key spanning several blocks"} {
+			want := h.Hashes([]byte(key), 9, 1000003)
+			d := s.Sum([]byte(key))
+			for i, w := range want {
+				if got := s.Location(d, uint64(i), 1000003); got != w {
+					t.Errorf("%s %q: location %d = %d, want %d", s, key, i, got, w)
+				}
+			}
+		}
+	}
+}
+
+func TestScheme_SumDoesNotAllocate(t *testing.T) {
+	data := []byte("hello world")
+	for _, s := range []Scheme{SchemeMurmur, SchemeBitsAndBlooms} {
+		allocs := testing.AllocsPerRun(100, func() {
+			d := s.Sum(data)
+			s.Location(d, 3, 1000)
+		})
+		if allocs != 0 {
+			t.Errorf("%s: Sum allocated %v times", s, allocs)
+		}
+	}
+}
+
+func TestScheme_DigestLen(t *testing.T) {
+	for _, s := range []Scheme{SchemeMurmur, SchemeBitsAndBlooms, SchemeGuavaMitz32, SchemeGuavaMitz64} {
+		d := s.Sum([]byte("hello"))
+		for i := s.DigestLen(); i < len(d); i++ {
+			if d[i] != 0 {
+				t.Errorf("%s: digest word %d beyond DigestLen is set", s, i)
+			}
+		}
+	}
+}
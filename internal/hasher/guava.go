@@ -0,0 +1,48 @@
+package hasher
+
+import (
+	"math"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// GuavaMitz64Hasher reproduces the MURMUR128_MITZ_64 strategy of Guava's
+// BloomFilter. Both halves of the murmur3-128 hash are used, and location i
+// is
+//
+//	((h1 + i*h2) & math.MaxInt64) mod m
+type GuavaMitz64Hasher struct{}
+
+func (gh *GuavaMitz64Hasher) Hashes(data []byte, k, m uint64) []uint64 {
+	h1, h2 := murmur3.Sum128(data)
+	hashes := make([]uint64, k)
+
+	combined := h1
+	for i := uint64(0); i < k; i++ {
+		hashes[i] = (combined & math.MaxInt64) % m
+		combined += h2
+	}
+
+	return hashes
+}
+
+// GuavaMitz32Hasher reproduces the MURMUR128_MITZ_32 strategy of Guava's
+// BloomFilter, which only uses the lower 64 bits of the murmur3-128 hash,
+// split into two 32-bit halves combined with Java int arithmetic.
+type GuavaMitz32Hasher struct{}
+
+func (gh *GuavaMitz32Hasher) Hashes(data []byte, k, m uint64) []uint64 {
+	h64, _ := murmur3.Sum128(data)
+	h1, h2 := int32(h64), int32(h64>>32)
+	hashes := make([]uint64, k)
+
+	for i := uint64(0); i < k; i++ {
+		combined := h1 + int32(i+1)*h2
+		if combined < 0 {
+			combined = ^combined
+		}
+		hashes[i] = uint64(combined) % m
+	}
+
+	return hashes
+}
@@ -141,3 +141,62 @@ func TestMurmurHasher_HashDiversity(t *testing.T) {
 		t.Errorf("Too many hash collisions: got %d collisions out of %d", collisions, k)
 	}
 }
+
+func TestForScheme(t *testing.T) {
+	for _, s := range []Scheme{SchemeMurmur, SchemeBitsAndBlooms, SchemeGuavaMitz32, SchemeGuavaMitz64} {
+		h, err := ForScheme(s)
+		if err != nil || h == nil {
+			t.Errorf("Expected hasher for scheme %s, got %v", s, err)
+		}
+	}
+
+	if _, err := ForScheme(Scheme(200)); err == nil {
+		t.Error("Expected error for unknown scheme")
+	}
+}
+
+func TestBitsAndBloomsHasher_InRange(t *testing.T) {
+	bh := &BitsAndBloomsHasher{}
+	k, m := uint64(7), uint64(1000)
+	hashes := bh.Hashes([]byte("bits-and-blooms"), k, m)
+
+	if len(hashes) != int(k) {
+		t.Fatalf("Expected %d hashes, got %d", k, len(hashes))
+	}
+	for _, h := range hashes {
+		if h >= m {
+			t.Errorf("Hash value %d out of range (>= %d)", h, m)
+		}
+	}
+}
+
+func TestGuavaMitz64Hasher_MatchesGuavaMurmur(t *testing.T) {
+	// Guava's Murmur3Hash128Test expects 0xe34bbc7bbc071b6c and
+	// 0x7a433ca9c49a9347 as the lower and upper eight bytes for this input.
+	// With k = 2 and m = 2^63 the first two indexes expose both halves.
+	gh := &GuavaMitz64Hasher{}
+	hashes := gh.Hashes([]byte("The quick brown fox jumps over the lazy dog"), 2, 1<<63)
+
+	h1, h2 := uint64(0xe34bbc7bbc071b6c), uint64(0x7a433ca9c49a9347)
+	if hashes[0] != h1&(1<<63-1) || hashes[1] != (h1+h2)&(1<<63-1) {
+		t.Errorf("Unexpected indexes %x", hashes)
+	}
+}
+
+func TestPartitioned_OneLocationPerSlice(t *testing.T) {
+	ph := Partitioned{Hasher: New()}
+	const k, m = 7, 7 * 100
+
+	for _, item := range []string{"a", "b", "hello", ""} {
+		hashes := ph.Hashes([]byte(item), k, m)
+		inner := New().Hashes([]byte(item), k, m/k)
+		for i, h := range hashes {
+			if h/(m/k) != uint64(i) {
+				t.Errorf("%q: location %d = %d is outside slice %d", item, i, h, i)
+			}
+			if h%(m/k) != inner[i] {
+				t.Errorf("%q: location %d = %d, expected offset %d", item, i, h, inner[i])
+			}
+		}
+	}
+}
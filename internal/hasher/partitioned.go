@@ -0,0 +1,18 @@
+package hasher
+
+// Partitioned splits a filter of m bits into k slices of m/k bits and
+// places the i-th location of an item in slice i, at the i-th location
+// the wrapped Hasher would choose in a filter of m/k bits. m must be a
+// multiple of k.
+type Partitioned struct {
+	Hasher
+}
+
+func (ph Partitioned) Hashes(data []byte, k, m uint64) []uint64 {
+	size := m / k
+	hashes := ph.Hasher.Hashes(data, k, size)
+	for i := range hashes {
+		hashes[i] += uint64(i) * size
+	}
+	return hashes
+}
@@ -0,0 +1,153 @@
+package hasher
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Reduction maps a 64-bit hash onto a bit location in [0, m).
+type Reduction uint8
+
+const (
+	// ReduceModulo takes the hash modulo m, which costs a 64-bit division.
+	ReduceModulo Reduction = iota
+	// ReduceMultiplyShift takes the high word of the 128-bit product of the
+	// hash and m, Lemire's fast alternative to the modulo reduction.
+	ReduceMultiplyShift
+	// ReduceMask keeps the low bits of the hash. m must be a power of two.
+	ReduceMask
+)
+
+var reductionNames = map[Reduction]string{
+	ReduceModulo:        "modulo",
+	ReduceMultiplyShift: "multiply-shift",
+	ReduceMask:          "mask",
+}
+
+func (r Reduction) String() string {
+	if name, ok := reductionNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("reduction(%d)", uint8(r))
+}
+
+// Reduce maps x onto [0, m).
+func (r Reduction) Reduce(x, m uint64) uint64 {
+	switch r {
+	case ReduceMultiplyShift:
+		hi, _ := bits.Mul64(x, m)
+		return hi
+	case ReduceMask:
+		return x & (m - 1)
+	}
+	return x % m
+}
+
+// Probing derives the bit locations of an item from its murmur3-128
+// digest (h1, h2). Its zero value is the probing of SchemeMurmur,
+// (h1 + i*h2) mod m.
+//
+// Enhanced double hashing adds the tetrahedral number ((i+1)³ - (i+1))/6
+// to the i-th location, so the locations of an item do not repeat when h2
+// is zero and do not cycle early when h2 shares a factor with m, as with
+// even h2 and a power-of-two m.
+//
+// Under ReduceMultiplyShift each location is mixed with the splitmix64
+// finalizer before it is reduced. The reduction keeps only the high bits
+// of the product with m, which the small differences between the
+// locations of an item with a small h2 would otherwise never reach.
+type Probing struct {
+	Enhanced  bool
+	Reduction Reduction
+}
+
+// String returns the reduction's name, prefixed with "enhanced-" for
+// enhanced double hashing.
+func (p Probing) String() string {
+	if p.Enhanced {
+		return "enhanced-" + p.Reduction.String()
+	}
+	return p.Reduction.String()
+}
+
+// ParseProbing returns the probing whose String is name.
+func ParseProbing(name string) (Probing, error) {
+	base, enhanced := strings.CutPrefix(name, "enhanced-")
+	for r, n := range reductionNames {
+		if n == base {
+			return Probing{Enhanced: enhanced, Reduction: r}, nil
+		}
+	}
+	return Probing{}, fmt.Errorf("unknown probing %q", name)
+}
+
+// Location returns the i-th of an item's bit locations in a filter of m
+// bits. The digest must come from SchemeMurmur.Sum.
+func (p Probing) Location(d Digest, i, m uint64) uint64 {
+	x := d[0] + i*d[1]
+	if p.Enhanced {
+		x += tetrahedral(i + 1)
+	}
+	return p.reduce(x, m)
+}
+
+// Hashes implements Hasher with murmur3-128, as MurmurHasher does, and
+// this probing.
+func (p Probing) Hashes(data []byte, k, m uint64) []uint64 {
+	x, y := sum128(data, false)
+	hashes := make([]uint64, k)
+
+	if p.Enhanced {
+		y++
+	}
+	for i := range k {
+		hashes[i] = p.reduce(x, m)
+		// With enhanced double hashing the step after location i is
+		// h2 + (i+1)(i+2)/2, whose triangular terms sum to the tetrahedral
+		// term of Location.
+		x += y
+		if p.Enhanced {
+			y += i + 2
+		}
+	}
+
+	return hashes
+}
+
+// reduce maps location x onto [0, m), mixing it first under
+// ReduceMultiplyShift.
+func (p Probing) reduce(x, m uint64) uint64 {
+	if p.Reduction == ReduceMultiplyShift {
+		x = mix64(x)
+	}
+	return p.Reduction.Reduce(x, m)
+}
+
+// mix64 is the splitmix64 finalizer, a bijection that spreads every bit
+// of x over the whole word.
+func mix64(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// tetrahedral returns (i³ - i)/6 modulo 2^64. The division is taken
+// before the product, so the result is exact for any i.
+func tetrahedral(i uint64) uint64 {
+	a, b, c := i-1, i, i+1
+	switch {
+	case a%3 == 0:
+		a /= 3
+	case b%3 == 0:
+		b /= 3
+	default:
+		c /= 3
+	}
+	if b%2 == 0 {
+		b /= 2
+	} else {
+		a /= 2
+	}
+	return a * b * c
+}
@@ -0,0 +1,123 @@
+package hasher
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReduction_InRange(t *testing.T) {
+	for _, r := range []Reduction{ReduceModulo, ReduceMultiplyShift, ReduceMask} {
+		for _, x := range []uint64{0, 1, 12345, 1 << 63, math.MaxUint64} {
+			if got := r.Reduce(x, 1024); got >= 1024 {
+				t.Errorf("%s: Reduce(%d, 1024) = %d out of range", r, x, got)
+			}
+		}
+	}
+
+	if got := ReduceMultiplyShift.Reduce(math.MaxUint64, 1000); got != 999 {
+		t.Errorf("Expected the largest hash to map to 999, got %d", got)
+	}
+	if got := ReduceMultiplyShift.Reduce(1<<63, 1000); got != 500 {
+		t.Errorf("Expected half the hash range to map to 500, got %d", got)
+	}
+	if got := ReduceMask.Reduce(0x12345, 256); got != 0x45 {
+		t.Errorf("Expected the low byte, got %#x", got)
+	}
+}
+
+func TestProbing_ZeroValueIsMurmur(t *testing.T) {
+	for _, item := range []string{"", "a", "hello world"} {
+		got := Probing{}.Hashes([]byte(item), 7, 1000)
+		want := New().Hashes([]byte(item), 7, 1000)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%q: location %d = %d, expected %d", item, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestProbing_HashesMatchLocation(t *testing.T) {
+	for _, p := range []Probing{
+		{Enhanced: true, Reduction: ReduceModulo},
+		{Enhanced: true, Reduction: ReduceMultiplyShift},
+		{Enhanced: true, Reduction: ReduceMask},
+		{Reduction: ReduceMultiplyShift},
+	} {
+		for _, item := range []string{"", "a", "hello world"} {
+			d := SchemeMurmur.Sum([]byte(item))
+			for i, h := range p.Hashes([]byte(item), 20, 4096) {
+				if loc := p.Location(d, uint64(i), 4096); loc != h {
+					t.Errorf("%s %q: Hashes[%d] = %d, Location = %d", p, item, i, h, loc)
+				}
+			}
+		}
+	}
+}
+
+func TestProbing_EnhancedAvoidsCycles(t *testing.T) {
+	// An item whose h2 is zero, or a multiple of m, gets a single location
+	// under plain double hashing. Enhanced double hashing keeps them
+	// distinct.
+	d := Digest{42, 0}
+	p := Probing{Enhanced: true, Reduction: ReduceMask}
+
+	seen := map[uint64]bool{}
+	for i := range uint64(8) {
+		seen[p.Location(d, i, 1024)] = true
+	}
+	if len(seen) != 8 {
+		t.Errorf("Expected 8 distinct locations with h2 = 0, got %d", len(seen))
+	}
+	if (Probing{Reduction: ReduceMask}).Location(d, 7, 1024) != 42 {
+		t.Error("Expected plain double hashing to repeat the first location")
+	}
+}
+
+func TestProbing_SmallStrideDistinct(t *testing.T) {
+	// Multiply-shift keeps only the high bits of each location, which a
+	// small h2 and the tetrahedral term never reach unless mixed first.
+	const k = 7
+	for _, r := range []Reduction{ReduceModulo, ReduceMultiplyShift, ReduceMask} {
+		m := uint64(9585059)
+		if r == ReduceMask {
+			m = 1 << 23
+		}
+		p := Probing{Enhanced: true, Reduction: r}
+		for _, h2 := range []uint64{0, 1, 1 << 20} {
+			d := Digest{0x9e3779b97f4a7c15, h2}
+			seen := map[uint64]bool{}
+			for i := range uint64(k) {
+				seen[p.Location(d, i, m)] = true
+			}
+			if len(seen) != k {
+				t.Errorf("%s, h2 = %d: expected %d distinct locations, got %d", p, h2, k, len(seen))
+			}
+		}
+	}
+}
+
+func TestTetrahedral(t *testing.T) {
+	for i := range uint64(100) {
+		if got, want := tetrahedral(i), (i*i*i-i)/6; got != want {
+			t.Errorf("tetrahedral(%d) = %d, expected %d", i, got, want)
+		}
+	}
+	// (2^32)³ overflows, but the result modulo 2^64 is exact.
+	i := uint64(1) << 32
+	if got := tetrahedral(i) * 6; got != i*i*i-i {
+		t.Errorf("tetrahedral(2^32) * 6 = %d, expected %d", got, i*i*i-i)
+	}
+}
+
+func TestParseProbing(t *testing.T) {
+	for _, p := range []Probing{{}, {Enhanced: true, Reduction: ReduceMultiplyShift}, {Reduction: ReduceMask}} {
+		parsed, err := ParseProbing(p.String())
+		if err != nil || parsed != p {
+			t.Errorf("ParseProbing(%q) = %v, %v", p.String(), parsed, err)
+		}
+	}
+	if _, err := ParseProbing("enhanced-"); err == nil {
+		t.Error("Expected error for an unknown probing")
+	}
+}
@@ -0,0 +1,66 @@
+package hasher
+
+import "fmt"
+
+// Scheme identifies how an item is hashed and turned into bit positions.
+// It is recorded in serialized filters, so existing values must never be
+// renumbered.
+type Scheme uint8
+
+const (
+	// SchemeMurmur is murmur3-128 with (h1 + i*h2) mod m probing, used by
+	// filters created before schemes were recorded.
+	SchemeMurmur Scheme = 0
+	// SchemeBitsAndBlooms is the probing scheme of
+	// github.com/bits-and-blooms/bloom/v3.
+	SchemeBitsAndBlooms Scheme = 1
+	// SchemeGuavaMitz32 is the MURMUR128_MITZ_32 strategy of Guava.
+	SchemeGuavaMitz32 Scheme = 2
+	// SchemeGuavaMitz64 is the MURMUR128_MITZ_64 strategy of Guava.
+	SchemeGuavaMitz64 Scheme = 3
+	// SchemeAdaptive marks the filters serialized by an AdaptiveFilter,
+	// whose locations derive from a single 64-bit hash rather than the
+	// murmur3 digest. ForScheme does not implement it, so such a filter
+	// cannot be decoded, and misread, as a plain one.
+	SchemeAdaptive Scheme = 4
+)
+
+var schemeNames = map[Scheme]string{
+	SchemeMurmur:        "murmur3",
+	SchemeBitsAndBlooms: "bits-and-blooms",
+	SchemeGuavaMitz32:   "guava-murmur128-mitz-32",
+	SchemeGuavaMitz64:   "guava-murmur128-mitz-64",
+	SchemeAdaptive:      "adaptive",
+}
+
+func (s Scheme) String() string {
+	if name, ok := schemeNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("scheme(%d)", uint8(s))
+}
+
+// ParseScheme returns the scheme whose String is name.
+func ParseScheme(name string) (Scheme, error) {
+	for s, n := range schemeNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hashing scheme %q", name)
+}
+
+// ForScheme returns the Hasher implementing s.
+func ForScheme(s Scheme) (Hasher, error) {
+	switch s {
+	case SchemeMurmur:
+		return &MurmurHasher{}, nil
+	case SchemeBitsAndBlooms:
+		return &BitsAndBloomsHasher{}, nil
+	case SchemeGuavaMitz32:
+		return &GuavaMitz32Hasher{}, nil
+	case SchemeGuavaMitz64:
+		return &GuavaMitz64Hasher{}, nil
+	}
+	return nil, fmt.Errorf("unknown hashing scheme %d", uint8(s))
+}
@@ -0,0 +1,139 @@
+package lsm
+
+import (
+	"encoding/binary"
+)
+
+// filterBaseLg is the log2 of the range of data block offsets covered by
+// each filter. A new filter is generated every 2 KiB of table data.
+const filterBaseLg = 11
+
+// FilterBlockBuilder constructs the filter block of a table. A filter block
+// holds the filters one after another, then the little-endian 32-bit offset
+// of each filter, the offset of that array, and a byte holding the base lg:
+//
+//	[filter 0]
+//	...
+//	[filter N-1]
+//	[offset of filter 0]     : 4 bytes
+//	...
+//	[offset of filter N-1]   : 4 bytes
+//	[offset of offset array] : 4 bytes
+//	lg(base)                 : 1 byte
+//
+// Filter i covers the data blocks starting at file offsets in
+// [i*base, (i+1)*base).
+//
+// The calls must match the sequence (StartBlock AddKey*)* Finish. A
+// FilterBlockBuilder is not safe for concurrent use.
+type FilterBlockBuilder struct {
+	policy  FilterPolicy
+	keys    [][]byte
+	result  []byte
+	offsets []uint32
+}
+
+// NewFilterBlockBuilder returns a builder creating filters with policy.
+func NewFilterBlockBuilder(policy FilterPolicy) *FilterBlockBuilder {
+	return &FilterBlockBuilder{policy: policy}
+}
+
+// StartBlock is called before adding the keys of the data block starting
+// at blockOffset in the table file. Offsets must not decrease.
+func (b *FilterBlockBuilder) StartBlock(blockOffset uint64) {
+	index := blockOffset >> filterBaseLg
+	for index > uint64(len(b.offsets)) {
+		b.generateFilter()
+	}
+}
+
+// AddKey adds a key of the current data block. The key is copied.
+func (b *FilterBlockBuilder) AddKey(key []byte) {
+	b.keys = append(b.keys, append([]byte(nil), key...))
+}
+
+// Finish returns the contents of the filter block. The builder must not be
+// used afterwards.
+func (b *FilterBlockBuilder) Finish() []byte {
+	if len(b.keys) > 0 {
+		b.generateFilter()
+	}
+
+	arrayOffset := uint32(len(b.result))
+	for _, offset := range b.offsets {
+		b.result = binary.LittleEndian.AppendUint32(b.result, offset)
+	}
+	b.result = binary.LittleEndian.AppendUint32(b.result, arrayOffset)
+	return append(b.result, filterBaseLg)
+}
+
+func (b *FilterBlockBuilder) generateFilter() {
+	b.offsets = append(b.offsets, uint32(len(b.result)))
+	if len(b.keys) == 0 {
+		// An empty filter matches nothing; it shares the next offset.
+		return
+	}
+
+	b.result = b.policy.CreateFilter(b.keys, b.result)
+	b.keys = b.keys[:0]
+}
+
+// FilterBlockReader queries a filter block produced by FilterBlockBuilder
+// or by LevelDB. It does not copy the contents and is safe for concurrent
+// use.
+type FilterBlockReader struct {
+	policy FilterPolicy
+	data   []byte // the filters
+	index  []byte // the offset array
+	baseLg uint8
+}
+
+// NewFilterBlockReader returns a reader for the filter block contents,
+// which must remain unchanged while the reader is in use. Malformed
+// contents give a reader that reports every key as a potential match.
+func NewFilterBlockReader(policy FilterPolicy, contents []byte) *FilterBlockReader {
+	r := &FilterBlockReader{policy: policy}
+
+	n := len(contents)
+	if n < 5 {
+		return r
+	}
+	arrayOffset := binary.LittleEndian.Uint32(contents[n-5:])
+	if uint64(arrayOffset) > uint64(n-5) {
+		return r
+	}
+
+	r.baseLg = contents[n-1]
+	r.data = contents[:arrayOffset]
+	r.index = contents[arrayOffset : n-5]
+	r.index = r.index[:len(r.index)/4*4]
+	return r
+}
+
+// KeyMayMatch reports whether key may be in the data block starting at
+// blockOffset. Errors, such as an offset beyond the filters, are treated as
+// potential matches.
+func (r *FilterBlockReader) KeyMayMatch(blockOffset uint64, key []byte) bool {
+	if r.baseLg >= 64 {
+		return true
+	}
+	i := blockOffset >> r.baseLg
+	if i >= uint64(len(r.index)/4) {
+		return true
+	}
+
+	start := binary.LittleEndian.Uint32(r.index[i*4:])
+	limit := uint32(len(r.data))
+	if next := (i + 1) * 4; next < uint64(len(r.index)) {
+		limit = binary.LittleEndian.Uint32(r.index[next:])
+	}
+
+	switch {
+	case start == limit:
+		// Empty filters do not match any keys.
+		return false
+	case start < limit && limit <= uint32(len(r.data)):
+		return r.policy.KeyMayMatch(key, r.data[start:limit])
+	}
+	return true
+}
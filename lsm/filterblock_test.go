@@ -0,0 +1,100 @@
+package lsm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFilterBlock_EmptyBuilder(t *testing.T) {
+	b := NewFilterBlockBuilder(NewBloomPolicy(10))
+	contents := b.Finish()
+	if !bytes.Equal(contents, []byte{0, 0, 0, 0, 11}) {
+		t.Errorf("Unexpected empty filter block %x", contents)
+	}
+
+	r := NewFilterBlockReader(NewBloomPolicy(10), contents)
+	if !r.KeyMayMatch(0, []byte("foo")) || !r.KeyMayMatch(100000, []byte("foo")) {
+		t.Error("Expected an empty filter block to match everything")
+	}
+}
+
+func TestFilterBlock_SingleChunk(t *testing.T) {
+	policy := NewBloomPolicy(10)
+	b := NewFilterBlockBuilder(policy)
+	b.StartBlock(100)
+	b.AddKey([]byte("foo"))
+	b.AddKey([]byte("bar"))
+	b.AddKey([]byte("box"))
+	b.StartBlock(200)
+	b.AddKey([]byte("box"))
+	b.StartBlock(300)
+	b.AddKey([]byte("hello"))
+	contents := b.Finish()
+
+	r := NewFilterBlockReader(policy, contents)
+	for _, key := range []string{"foo", "bar", "box", "hello"} {
+		if !r.KeyMayMatch(100, []byte(key)) {
+			t.Errorf("Expected %s to match", key)
+		}
+	}
+	if r.KeyMayMatch(100, []byte("missing")) || r.KeyMayMatch(100, []byte("other")) {
+		t.Error("Unexpected match for a missing key")
+	}
+}
+
+func TestFilterBlock_MultiChunk(t *testing.T) {
+	policy := NewBloomPolicy(10)
+	b := NewFilterBlockBuilder(policy)
+
+	// First filter.
+	b.StartBlock(0)
+	b.AddKey([]byte("foo"))
+	b.StartBlock(2000)
+	b.AddKey([]byte("bar"))
+
+	// Second filter.
+	b.StartBlock(3100)
+	b.AddKey([]byte("box"))
+
+	// Third filter is empty.
+
+	// Last filter.
+	b.StartBlock(9000)
+	b.AddKey([]byte("box"))
+	b.AddKey([]byte("hello"))
+
+	r := NewFilterBlockReader(policy, b.Finish())
+
+	check := func(offset uint64, present, absent []string) {
+		t.Helper()
+		for _, key := range present {
+			if !r.KeyMayMatch(offset, []byte(key)) {
+				t.Errorf("offset %d: expected %s to match", offset, key)
+			}
+		}
+		for _, key := range absent {
+			if r.KeyMayMatch(offset, []byte(key)) {
+				t.Errorf("offset %d: unexpected match for %s", offset, key)
+			}
+		}
+	}
+	check(0, []string{"foo", "bar"}, []string{"box", "hello"})
+	check(2000, []string{"foo", "bar"}, []string{"box", "hello"})
+	check(3100, []string{"box"}, []string{"foo", "bar", "hello"})
+	check(4100, nil, []string{"foo", "bar", "box", "hello"})
+	check(9000, []string{"box", "hello"}, []string{"foo", "bar"})
+}
+
+func TestFilterBlockReader_Malformed(t *testing.T) {
+	policy := NewBloomPolicy(10)
+	for _, contents := range [][]byte{
+		nil,
+		{1, 2, 3},
+		{0xff, 0xff, 0, 0, 11}, // offset array beyond the block
+	} {
+		r := NewFilterBlockReader(policy, contents)
+		if !r.KeyMayMatch(0, []byte("foo")) {
+			t.Errorf("Expected malformed block %x to match", contents)
+		}
+	}
+}
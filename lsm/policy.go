@@ -0,0 +1,146 @@
+/*
+Package lsm provides Bloom filters for the data blocks of LSM-tree sorted
+tables, in the format used by LevelDB and RocksDB's block-based tables.
+
+A FilterPolicy builds one filter from a set of keys. FilterBlockBuilder
+collects a filter for each data block of a table into a filter block, and
+FilterBlockReader answers whether a key may be present in the block at a
+given file offset:
+
+	policy := lsm.NewBloomPolicy(10)
+
+	b := lsm.NewFilterBlockBuilder(policy)
+	b.StartBlock(0)
+	b.AddKey([]byte("apple"))
+	contents := b.Finish()
+
+	r := lsm.NewFilterBlockReader(policy, contents)
+	r.KeyMayMatch(0, []byte("apple")) // true
+*/
+package lsm
+
+import (
+	"encoding/binary"
+)
+
+// FilterPolicy creates and queries the filters of a filter block.
+type FilterPolicy interface {
+	// Name identifies the filter encoding. Tables store filter blocks
+	// under the key "filter." + Name(), and a policy must only read
+	// filters written with the same name.
+	Name() string
+
+	// CreateFilter appends a filter summarizing keys to dst and returns the
+	// extended slice.
+	CreateFilter(keys [][]byte, dst []byte) []byte
+
+	// KeyMayMatch reports whether key may have been among the keys passed
+	// to CreateFilter for filter. It must return true if it was, and should
+	// return false with high probability if it was not.
+	KeyMayMatch(key, filter []byte) bool
+}
+
+// bloomPolicy is LevelDB's built-in Bloom filter policy.
+type bloomPolicy struct {
+	bitsPerKey int
+	k          int
+}
+
+// NewBloomPolicy returns the Bloom filter policy built into LevelDB,
+// equivalent to leveldb::NewBloomFilterPolicy(bitsPerKey). Filters it
+// creates can be read by LevelDB, and by RocksDB's block-based tables using
+// the legacy filter format.
+//
+// A bitsPerKey of 10 gives a false positive rate of about 1%.
+func NewBloomPolicy(bitsPerKey int) FilterPolicy {
+	bitsPerKey = max(bitsPerKey, 0)
+
+	// Rounding down reduces the probing cost a little.
+	k := int(float64(bitsPerKey) * 0.69)
+	return &bloomPolicy{bitsPerKey: bitsPerKey, k: min(max(k, 1), 30)}
+}
+
+func (p *bloomPolicy) Name() string {
+	return "leveldb.BuiltinBloomFilter2"
+}
+
+// CreateFilter appends a bit array of at least 64 bits followed by a byte
+// holding the number of probes.
+func (p *bloomPolicy) CreateFilter(keys [][]byte, dst []byte) []byte {
+	bits := max(len(keys)*p.bitsPerKey, 64)
+	bytes := (bits + 7) / 8
+	bits = bytes * 8
+
+	start := len(dst)
+	dst = append(dst, make([]byte, bytes)...)
+	dst = append(dst, byte(p.k))
+
+	array := dst[start : start+bytes]
+	for _, key := range keys {
+		// Double hashing with a rotated copy of the hash as the delta.
+		h := bloomHash(key)
+		delta := h>>17 | h<<15
+		for range p.k {
+			pos := h % uint32(bits)
+			array[pos/8] |= 1 << (pos % 8)
+			h += delta
+		}
+	}
+	return dst
+}
+
+func (p *bloomPolicy) KeyMayMatch(key, filter []byte) bool {
+	if len(filter) < 2 {
+		return false
+	}
+
+	array := filter[:len(filter)-1]
+	bits := uint32(len(array) * 8)
+
+	// Larger values are reserved for new encodings; treat them as matches.
+	k := filter[len(filter)-1]
+	if k > 30 {
+		return true
+	}
+
+	h := bloomHash(key)
+	delta := h>>17 | h<<15
+	for range k {
+		pos := h % bits
+		if array[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
+
+// bloomHash is LevelDB's Hash function with the seed used for Bloom filters.
+func bloomHash(key []byte) uint32 {
+	const (
+		seed = 0xbc9f1d34
+		m    = 0xc6a4a793
+		r    = 24
+	)
+
+	h := seed ^ uint32(len(key))*m
+	for ; len(key) >= 4; key = key[4:] {
+		h += binary.LittleEndian.Uint32(key)
+		h *= m
+		h ^= h >> 16
+	}
+
+	switch len(key) {
+	case 3:
+		h += uint32(key[2]) << 16
+		fallthrough
+	case 2:
+		h += uint32(key[1]) << 8
+		fallthrough
+	case 1:
+		h += uint32(key[0])
+		h *= m
+		h ^= h >> r
+	}
+	return h
+}
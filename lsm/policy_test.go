@@ -0,0 +1,107 @@
+package lsm
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Vectors from LevelDB's hash_test.cc.
+func TestBloomHash(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint32
+	}{
+		{nil, 0xbc9f1d34},
+		{[]byte{0x62}, 0xef1345c4},
+		{[]byte{0xc3, 0x97}, 0x5b663814},
+		{[]byte{0xe2, 0x99, 0xa5}, 0x323c078f},
+		{[]byte{0xe1, 0x80, 0xb9, 0x32}, 0xed21633a},
+	}
+	for _, tt := range tests {
+		if got := bloomHash(tt.data); got != tt.want {
+			t.Errorf("bloomHash(%x) = %#x, want %#x", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestBloomPolicy_Empty(t *testing.T) {
+	p := NewBloomPolicy(10)
+	filter := p.CreateFilter(nil, nil)
+	if len(filter) != 9 || filter[8] != 6 {
+		t.Errorf("Expected 8 zero bytes and k=6, got %x", filter)
+	}
+	if p.KeyMayMatch([]byte("hello"), filter) {
+		t.Error("Empty filter matched hello")
+	}
+	if p.KeyMayMatch([]byte("hello"), nil) {
+		t.Error("Missing filter matched hello")
+	}
+}
+
+func TestBloomPolicy_Small(t *testing.T) {
+	p := NewBloomPolicy(10)
+	if p.Name() != "leveldb.BuiltinBloomFilter2" {
+		t.Errorf("Unexpected policy name %q", p.Name())
+	}
+
+	prefix := []byte("existing")
+	filter := p.CreateFilter([][]byte{[]byte("hello"), []byte("world")}, prefix)
+	if string(filter[:len(prefix)]) != "existing" {
+		t.Fatal("CreateFilter overwrote dst")
+	}
+	filter = filter[len(prefix):]
+
+	for _, key := range []string{"hello", "world"} {
+		if !p.KeyMayMatch([]byte(key), filter) {
+			t.Errorf("Expected %s to match", key)
+		}
+	}
+	for _, key := range []string{"x", "foo"} {
+		if p.KeyMayMatch([]byte(key), filter) {
+			t.Errorf("Expected %s not to match", key)
+		}
+	}
+}
+
+func TestBloomPolicy_VaryingLengths(t *testing.T) {
+	// As LevelDB's bloom_test: false positive rates stay below 2% for
+	// filters of 1 to 10000 keys.
+	p := NewBloomPolicy(10)
+	key := func(i int) []byte {
+		return binary.LittleEndian.AppendUint32(nil, uint32(i))
+	}
+
+	for _, n := range []int{1, 10, 100, 1000, 10000} {
+		keys := make([][]byte, n)
+		for i := range keys {
+			keys[i] = key(i)
+		}
+		filter := p.CreateFilter(keys, nil)
+		if len(filter) > n*10/8+40 {
+			t.Errorf("n=%d: filter too large: %d bytes", n, len(filter))
+		}
+
+		for _, k := range keys {
+			if !p.KeyMayMatch(k, filter) {
+				t.Fatalf("n=%d: false negative for %x", n, k)
+			}
+		}
+
+		falsePositives := 0
+		for i := range 10000 {
+			if p.KeyMayMatch(key(i+1000000000), filter) {
+				falsePositives++
+			}
+		}
+		if rate := float64(falsePositives) / 10000; rate > 0.02 {
+			t.Errorf("n=%d: false positive rate %v exceeds 0.02", n, rate)
+		}
+	}
+}
+
+func TestBloomPolicy_ReservedK(t *testing.T) {
+	p := NewBloomPolicy(10)
+	if !p.KeyMayMatch([]byte("x"), []byte{0, 0, 0, 0, 0, 0, 0, 0, 31}) {
+		t.Error("Expected filters with a reserved k to match")
+	}
+}
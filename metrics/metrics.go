@@ -0,0 +1,149 @@
+/*
+Package metrics exports bitbloom filter statistics through expvar and in the
+Prometheus text exposition format.
+
+Any value with a Stats method, such as *bitbloom.BloomFilter, can be exported:
+
+	bf, _ := bitbloom.New(1000000, 0.01)
+
+	metrics.Publish("users", bf) // visible under /debug/vars
+
+	c := metrics.NewCollector()
+	c.Register("users", bf)
+	http.Handle("/metrics", c)
+*/
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/umang-sinha/bitbloom"
+)
+
+// Source is implemented by filters that can report their statistics.
+type Source interface {
+	Stats() bitbloom.Stats
+}
+
+// Publish exposes the statistics of src as an expvar variable with the given
+// name. The Stats are recomputed every time the variable is read.
+//
+// Like expvar.Publish, it panics if the name is already registered.
+func Publish(name string, src Source) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return src.Stats()
+	}))
+}
+
+// ContentType is the Content-Type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector renders the statistics of a set of named filters in the
+// Prometheus text exposition format. It implements http.Handler so it can be
+// mounted directly as a scrape endpoint.
+//
+// A Collector is safe for concurrent use.
+type Collector struct {
+	mu      sync.RWMutex
+	sources map[string]Source
+}
+
+// NewCollector returns an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{sources: make(map[string]Source)}
+}
+
+// Register adds src under the given filter name, replacing any source
+// previously registered with that name.
+func (c *Collector) Register(name string, src Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sources[name] = src
+}
+
+// Unregister removes the source registered under name, if any.
+func (c *Collector) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.sources, name)
+}
+
+type metric struct {
+	name  string
+	typ   string
+	help  string
+	value func(s bitbloom.Stats) float64
+}
+
+var metricsTable = []metric{
+	{"bitbloom_bits", "gauge", "Size of the bit array (m).",
+		func(s bitbloom.Stats) float64 { return float64(s.M) }},
+	{"bitbloom_hash_functions", "gauge", "Number of hash functions (k).",
+		func(s bitbloom.Stats) float64 { return float64(s.K) }},
+	{"bitbloom_items", "gauge", "Number of items added, including duplicates.",
+		func(s bitbloom.Stats) float64 { return float64(s.Count) }},
+	{"bitbloom_set_bits", "gauge", "Number of bits set.",
+		func(s bitbloom.Stats) float64 { return float64(s.SetBits) }},
+	{"bitbloom_estimated_items", "gauge", "Estimated number of distinct items.",
+		func(s bitbloom.Stats) float64 { return s.EstimatedCount }},
+	{"bitbloom_estimated_fill_ratio", "gauge", "Theoretical fraction of bits set.",
+		func(s bitbloom.Stats) float64 { return s.EstimatedFillRatio }},
+	{"bitbloom_fill_ratio", "gauge", "Actual fraction of bits set.",
+		func(s bitbloom.Stats) float64 { return s.ActualFillRatio }},
+	{"bitbloom_false_positive_rate", "gauge", "Estimated false positive rate.",
+		func(s bitbloom.Stats) float64 { return s.FalsePositiveRate }},
+	{"bitbloom_memory_bytes", "gauge", "Memory used by the bit array in bytes.",
+		func(s bitbloom.Stats) float64 { return float64(s.MemoryUsage) }},
+	{"bitbloom_adds_total", "counter", "Number of Add calls.",
+		func(s bitbloom.Stats) float64 { return float64(s.Adds) }},
+	{"bitbloom_tests_total", "counter", "Number of Test calls.",
+		func(s bitbloom.Stats) float64 { return float64(s.Tests) }},
+}
+
+// labelEscaper escapes label values as required by the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteText writes the statistics of every registered filter to w in the
+// Prometheus text exposition format. Each filter is labelled with
+// filter="<name>", and filters are emitted in name order.
+func (c *Collector) WriteText(w io.Writer) error {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	stats := make([]bitbloom.Stats, len(names))
+	for i, name := range names {
+		stats[i] = c.sources[name].Stats()
+	}
+	c.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metricsTable {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+		for i, name := range names {
+			fmt.Fprintf(bw, "%s{filter=\"%s\"} %g\n", m.name, labelEscaper.Replace(name), m.value(stats[i]))
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP implements http.Handler by writing the exposition text.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := c.WriteText(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom"
+)
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	bf := bitbloom.NewWithParams(1000, 3)
+	bf.Add([]byte("foo"))
+	bf.Test([]byte("foo"))
+
+	c := NewCollector()
+	c.Register("users", bf)
+	c.Register(`we"ird`, bitbloom.NewWithParams(64, 1))
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE bitbloom_bits gauge\n",
+		`bitbloom_bits{filter="users"} 1000` + "\n",
+		`bitbloom_adds_total{filter="users"} 1` + "\n",
+		`bitbloom_tests_total{filter="users"} 1` + "\n",
+		`bitbloom_bits{filter="we\"ird"} 64` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollector_Unregister(t *testing.T) {
+	c := NewCollector()
+	c.Register("users", bitbloom.NewWithParams(1000, 3))
+	c.Unregister("users")
+
+	var sb strings.Builder
+	if err := c.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	if strings.Contains(sb.String(), "users") {
+		t.Errorf("Unregistered filter should not be exported")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	bf := bitbloom.NewWithParams(1000, 3)
+	bf.Add([]byte("foo"))
+	Publish("bitbloom_test_filter", bf)
+
+	v := expvar.Get("bitbloom_test_filter")
+	if v == nil {
+		t.Fatal("Expected expvar variable to be published")
+	}
+
+	var s bitbloom.Stats
+	if err := json.Unmarshal([]byte(v.String()), &s); err != nil {
+		t.Fatalf("Failed to decode expvar value: %v", err)
+	}
+	if s.M != 1000 || s.Count != 1 {
+		t.Errorf("Unexpected stats from expvar: %+v", s)
+	}
+}
@@ -0,0 +1,197 @@
+package bitbloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spaolacci/murmur3"
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+// PagedFilter is a Bloom filter whose bit array lives in a file, for
+// filters larger than memory. The file is split into fixed-size pages of
+// which only a bounded number are cached; dirty pages are written back when
+// evicted and on Sync or Close.
+//
+// Hashing is blocked: the first half of an item's 128-bit murmur3 hash
+// selects a page and the second half the k bits within it, so every Add or
+// Test reads or writes exactly one page. Because items are not spread over
+// the whole array, the false positive rate is slightly higher than that of
+// a classic filter of the same size; larger pages narrow the gap.
+//
+// It is safe for concurrent use by multiple goroutines.
+type PagedFilter struct {
+	mutex    sync.RWMutex
+	storage  *storage.Paged
+	m        uint64
+	k        uint64
+	pageBits uint64
+	numPages uint64
+	count    uint64
+
+	adds  atomic.Uint64
+	tests atomic.Uint64
+}
+
+// NewPaged creates or opens a paged filter at path sized for `n` items with
+// a false positive probability of `p`, using OptimalM and OptimalK with m
+// rounded up to whole pages. opts sets the page size and the number of
+// pages cached; the zero value uses storage.DefaultPageSize and
+// storage.DefaultCachePages.
+//
+// The file holds only the bit array, so an existing filter must be reopened
+// with the same n, p and page size. Its count is then estimated from the
+// number of bits set, which reads the whole file.
+func NewPaged(path string, n uint64, p float64, opts storage.PagedOptions) (*PagedFilter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+	if opts.PageSize == 0 {
+		opts.PageSize = storage.DefaultPageSize
+	}
+	if opts.PageSize <= 0 || opts.PageSize%8 != 0 {
+		return nil, fmt.Errorf("page size must be a positive multiple of 8, got %d", opts.PageSize)
+	}
+
+	n = max(n, 1)
+	pageBits := uint64(opts.PageSize) * 8
+	numPages := max((OptimalM(n, p)+pageBits-1)/pageBits, 1)
+	m := numPages * pageBits
+	k := min(OptimalK(m, n), pageBits)
+
+	s, err := storage.OpenPaged(path, m, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pf := &PagedFilter{
+		storage:  s,
+		m:        m,
+		k:        k,
+		pageBits: pageBits,
+		numPages: numPages,
+	}
+	if setBits := s.Count(); setBits > 0 {
+		pf.count = uint64(estimateCardinality(m, k, setBits) + 0.5)
+	}
+	if err := s.Err(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return pf, nil
+}
+
+// locate returns the first bit of the item's page and the two 32-bit values
+// from which its k positions within the page are derived.
+func (pf *PagedFilter) locate(item []byte) (base, a, b uint64) {
+	h1, h2 := murmur3.Sum128(item)
+	page, _ := bits.Mul64(h1, pf.numPages)
+	return page * pf.pageBits, h2 & math.MaxUint32, h2>>32 | 1
+}
+
+// position returns the i-th bit of an item located at base, a and b. The
+// sum is taken in 64 bits so that pages beyond 2^32 bits are reachable.
+func (pf *PagedFilter) position(base, a, b, i uint64) uint64 {
+	return base + (a+i*b)%pf.pageBits
+}
+
+// Add inserts an item into the filter.
+func (pf *PagedFilter) Add(item []byte) {
+	base, a, b := pf.locate(item)
+
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+
+	for i := range pf.k {
+		pf.storage.SetBit(pf.position(base, a, b, i))
+	}
+	pf.count++
+	pf.adds.Add(1)
+}
+
+// Test checks whether an item is possibly in the filter. If the filter's
+// file cannot be read, Test errs on the side of reporting items present;
+// the error is returned by Err.
+func (pf *PagedFilter) Test(item []byte) bool {
+	base, a, b := pf.locate(item)
+
+	pf.mutex.RLock()
+	defer pf.mutex.RUnlock()
+
+	pf.tests.Add(1)
+	for i := range pf.k {
+		if !pf.storage.GetBit(pf.position(base, a, b, i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// M returns the number of bits in the filter.
+func (pf *PagedFilter) M() uint64 {
+	return pf.m
+}
+
+// K returns the number of bits set per item.
+func (pf *PagedFilter) K() uint64 {
+	return pf.k
+}
+
+// Count returns the number of items added.
+func (pf *PagedFilter) Count() uint64 {
+	pf.mutex.RLock()
+	defer pf.mutex.RUnlock()
+
+	return pf.count
+}
+
+// Stats returns the filter's statistics. Counting the bits set reads every
+// page of the file that is not cached. MemoryUsage is the size of the page
+// cache.
+func (pf *PagedFilter) Stats() Stats {
+	pf.mutex.RLock()
+	defer pf.mutex.RUnlock()
+
+	setBits := pf.storage.Count()
+	fillRatio := float64(setBits) / float64(pf.m)
+
+	return Stats{
+		M:                  pf.m,
+		K:                  pf.k,
+		Count:              pf.count,
+		SetBits:            setBits,
+		EstimatedCount:     estimateCardinality(pf.m, pf.k, setBits),
+		EstimatedFillRatio: 1 - math.Exp(-float64(pf.k*pf.count)/float64(pf.m)),
+		ActualFillRatio:    fillRatio,
+		FalsePositiveRate:  math.Pow(fillRatio, float64(pf.k)),
+		MemoryUsage:        pf.storage.CachedPages() * int(pf.pageBits/8),
+		Adds:               pf.adds.Load(),
+		Tests:              pf.tests.Load(),
+	}
+}
+
+// Err returns the first I/O error encountered by the filter's storage.
+func (pf *PagedFilter) Err() error {
+	return pf.storage.Err()
+}
+
+// Sync writes dirty pages back to the file and flushes it to stable
+// storage.
+func (pf *PagedFilter) Sync() error {
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+
+	return pf.storage.Sync()
+}
+
+// Close syncs and closes the filter's file. The filter must not be used
+// afterwards.
+func (pf *PagedFilter) Close() error {
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+
+	return pf.storage.Close()
+}
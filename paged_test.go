@@ -0,0 +1,120 @@
+package bitbloom
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+func TestPagedFilter_AddTest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.pages")
+	opts := storage.PagedOptions{PageSize: 512, CachePages: 4}
+
+	pf, err := NewPaged(path, 10000, 0.01, opts)
+	if err != nil {
+		t.Fatalf("NewPaged failed: %v", err)
+	}
+	if pf.M()%(512*8) != 0 || pf.M() < OptimalM(10000, 0.01) {
+		t.Errorf("Expected m rounded up to whole pages, got %d", pf.M())
+	}
+
+	for i := range 10000 {
+		pf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	for i := range 10000 {
+		if !pf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range 10000 {
+		if pf.Test(fmt.Appendf(nil, "other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.02 {
+		t.Errorf("False positive rate %v exceeds 0.02", rate)
+	}
+
+	stats := pf.Stats()
+	if stats.Count != 10000 || stats.MemoryUsage > 4*512 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if err := pf.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	pf, err = NewPaged(path, 10000, 0.01, opts)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer pf.Close()
+	for i := range 10000 {
+		if !pf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d after reopening", i)
+		}
+	}
+	if c := pf.Count(); c < 9500 || c > 10500 {
+		t.Errorf("Expected an estimated count near 10000, got %d", c)
+	}
+}
+
+func TestPagedFilter_OnePagePerLookup(t *testing.T) {
+	pf, err := NewPaged(filepath.Join(t.TempDir(), "filter.pages"), 100000, 0.001,
+		storage.PagedOptions{PageSize: 256, CachePages: 1000})
+	if err != nil {
+		t.Fatalf("NewPaged failed: %v", err)
+	}
+	defer pf.Close()
+
+	for i := range 50 {
+		before := pf.storage.CachedPages()
+		pf.Add(fmt.Appendf(nil, "item-%d", i))
+		pf.Test(fmt.Appendf(nil, "other-%d", i))
+		if loaded := pf.storage.CachedPages() - before; loaded > 2 {
+			t.Fatalf("Add and Test loaded %d pages, want at most 2", loaded)
+		}
+	}
+}
+
+func TestPagedFilter_LargePagePositions(t *testing.T) {
+	// A 1 GiB page has 2^33 bits; positions computed in 32 bits would
+	// never reach beyond the first 2^32.
+	pf := &PagedFilter{k: 8, pageBits: 1 << 33, numPages: 1}
+	beyond := false
+	for i := range 100 {
+		base, a, b := pf.locate(fmt.Appendf(nil, "item-%d", i))
+		for j := range pf.k {
+			pos := pf.position(base, a, b, j)
+			if pos >= pf.pageBits {
+				t.Fatalf("Position %d beyond the page", pos)
+			}
+			beyond = beyond || pos >= 1<<32
+		}
+	}
+	if !beyond {
+		t.Error("Expected positions beyond 2^32 in a 2^33-bit page")
+	}
+}
+
+func TestNewPaged_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewPaged(filepath.Join(dir, "a"), 100, 0, storage.PagedOptions{}); err == nil {
+		t.Error("Expected error for invalid false positive rate")
+	}
+	if _, err := NewPaged(filepath.Join(dir, "b"), 100, 0.01, storage.PagedOptions{PageSize: 7}); err == nil {
+		t.Error("Expected error for invalid page size")
+	}
+
+	path := filepath.Join(dir, "c")
+	pf, _ := NewPaged(path, 100, 0.01, storage.PagedOptions{PageSize: 64})
+	pf.Close()
+	if _, err := NewPaged(path, 100000, 0.01, storage.PagedOptions{PageSize: 64}); err == nil {
+		t.Error("Expected error when reopening with different parameters")
+	}
+}
+
+var _ Filter = (*PagedFilter)(nil)
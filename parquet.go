@@ -0,0 +1,366 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Thrift compact protocol field types used by the Parquet BloomFilterHeader.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+)
+
+// parquetHeaderTail is the Thrift compact encoding of the algorithm, hash
+// and compression fields of a BloomFilterHeader for the only values the
+// Parquet specification defines: BLOCK, XXHASH and UNCOMPRESSED. Each is a
+// union whose field 1 is an empty struct.
+var parquetHeaderTail = []byte{
+	0x1c, 0x1c, 0x00, 0x00, // 2: algorithm = {1: BLOCK {}}
+	0x1c, 0x1c, 0x00, 0x00, // 3: hash = {1: XXHASH {}}
+	0x1c, 0x1c, 0x00, 0x00, // 4: compression = {1: UNCOMPRESSED {}}
+	0x00, // stop
+}
+
+// AppendParquetHeader appends the Thrift compact encoding of a Parquet
+// BloomFilterHeader describing a split block filter of numBytes bytes,
+// using the BLOCK algorithm, XXHASH hash and no compression.
+func AppendParquetHeader(dst []byte, numBytes int32) []byte {
+	dst = append(dst, 0x15) // 1: numBytes, i32
+	dst = binary.AppendUvarint(dst, uint64(uint32((numBytes<<1)^(numBytes>>31))))
+	return append(dst, parquetHeaderTail...)
+}
+
+// MarshalBinary encodes the filter as it is stored in a Parquet file: a
+// Thrift compact BloomFilterHeader followed by the bitset.
+func (sb *SplitBlockFilter) MarshalBinary() ([]byte, error) {
+	bitset := sb.Bitset()
+	if len(bitset) > sbbfMaxBytes {
+		return nil, fmt.Errorf("split block filter too large: %d bytes", len(bitset))
+	}
+
+	buf := AppendParquetHeader(make([]byte, 0, 32+len(bitset)), int32(len(bitset)))
+	return append(buf, bitset...), nil
+}
+
+// WriteTo writes the filter in the format of MarshalBinary.
+func (sb *SplitBlockFilter) WriteTo(w io.Writer) (int64, error) {
+	data, err := sb.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// UnmarshalSplitBlock decodes a split block filter stored in the Parquet
+// format produced by MarshalBinary. The data is copied.
+func UnmarshalSplitBlock(data []byte) (*SplitBlockFilter, error) {
+	numBytes, n, err := parseParquetHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)-n != int(numBytes) {
+		return nil, fmt.Errorf("parquet bloom filter bitset length mismatch: header says %d bytes, have %d",
+			numBytes, len(data)-n)
+	}
+	return SplitBlockFromBitset(data[n:])
+}
+
+// ReadSplitBlock reads a split block filter in the Parquet format from r,
+// consuming only the header and the bitset it describes. This is how a
+// Parquet reader loads the filter at a column chunk's bloom_filter_offset.
+func ReadSplitBlock(r io.Reader) (*SplitBlockFilter, error) {
+	// Read the header a byte at a time so r is never read past the bitset.
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &byteReader{r: r}
+	}
+
+	var header []byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading parquet bloom filter header: %w", err)
+		}
+		header = append(header, b)
+
+		numBytes, _, err := parseParquetHeader(header)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if len(header) > 256 {
+				return nil, fmt.Errorf("parquet bloom filter header too long")
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		bitset := make([]byte, numBytes)
+		if _, err := io.ReadFull(r, bitset); err != nil {
+			return nil, fmt.Errorf("reading parquet bloom filter bitset: %w", err)
+		}
+		return SplitBlockFromBitset(bitset)
+	}
+}
+
+// byteReader reads one byte at a time from an io.Reader without buffering
+// ahead.
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(b.r, b.buf[:]); err != nil {
+		return 0, err
+	}
+	return b.buf[0], nil
+}
+
+// parseParquetHeader decodes a Thrift compact BloomFilterHeader at the start
+// of data and returns numBytes and the length of the header. It returns
+// io.ErrUnexpectedEOF if data ends before the header does.
+func parseParquetHeader(data []byte) (numBytes int32, n int, err error) {
+	d := &thriftDecoder{data: data}
+	var seen [5]bool
+
+	var lastID int16
+	for {
+		typ, id, err := d.fieldHeader(&lastID)
+		if err != nil {
+			return 0, 0, err
+		}
+		if typ == 0 {
+			break
+		}
+
+		switch {
+		case id == 1 && typ == thriftI32:
+			v, err := d.i32()
+			if err != nil {
+				return 0, 0, err
+			}
+			numBytes = v
+		case id >= 2 && id <= 4 && typ == thriftStruct:
+			// Each union must select field 1, the only variant defined
+			// for the algorithm, hash and compression.
+			if err := d.expectEmptyVariant(); err != nil {
+				return 0, 0, fmt.Errorf("unsupported parquet bloom filter %s: %w",
+					[]string{"", "", "algorithm", "hash", "compression"}[id], err)
+			}
+		default:
+			if err := d.skip(typ, 0); err != nil {
+				return 0, 0, err
+			}
+			continue
+		}
+		seen[id] = true
+	}
+
+	for id := 1; id <= 4; id++ {
+		if !seen[id] {
+			return 0, 0, fmt.Errorf("parquet bloom filter header is missing required field %d", id)
+		}
+	}
+	if numBytes <= 0 || numBytes > sbbfMaxBytes || numBytes%sbbfBlockBytes != 0 {
+		return 0, 0, fmt.Errorf("invalid parquet bloom filter size %d", numBytes)
+	}
+	return numBytes, d.pos, nil
+}
+
+// thriftDecoder is a minimal Thrift compact protocol decoder, sufficient to
+// parse and skip the fields of a BloomFilterHeader.
+type thriftDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *thriftDecoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *thriftDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	switch {
+	case n == 0:
+		return 0, io.ErrUnexpectedEOF
+	case n < 0:
+		return 0, fmt.Errorf("invalid varint in parquet bloom filter header")
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *thriftDecoder) zigzag() (int64, error) {
+	v, err := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (d *thriftDecoder) i32() (int32, error) {
+	v, err := d.zigzag()
+	if err == nil && int64(int32(v)) != v {
+		err = fmt.Errorf("i32 out of range in parquet bloom filter header")
+	}
+	return int32(v), err
+}
+
+// fieldHeader reads a field header, returning a zero type at the end of a
+// struct.
+func (d *thriftDecoder) fieldHeader(lastID *int16) (typ byte, id int16, err error) {
+	b, err := d.byte()
+	if err != nil || b == 0 {
+		return 0, 0, err
+	}
+
+	typ = b & 0x0f
+	if delta := int16(b >> 4); delta != 0 {
+		id = *lastID + delta
+	} else {
+		v, err := d.zigzag()
+		if err != nil {
+			return 0, 0, err
+		}
+		id = int16(v)
+	}
+	*lastID = id
+	return typ, id, nil
+}
+
+// expectEmptyVariant reads a union whose selected field must be field 1
+// holding an empty struct, ignoring any fields added to that struct later.
+func (d *thriftDecoder) expectEmptyVariant() error {
+	var lastID int16
+	typ, id, err := d.fieldHeader(&lastID)
+	if err != nil {
+		return err
+	}
+	if typ != thriftStruct || id != 1 {
+		return fmt.Errorf("variant %d", id)
+	}
+	if err := d.skip(thriftStruct, 0); err != nil {
+		return err
+	}
+
+	typ, _, err = d.fieldHeader(&lastID)
+	if err != nil {
+		return err
+	}
+	if typ != 0 {
+		return fmt.Errorf("union with more than one field set")
+	}
+	return nil
+}
+
+// maxThriftDepth bounds nesting when skipping unknown fields.
+const maxThriftDepth = 32
+
+func (d *thriftDecoder) skip(typ byte, depth int) error {
+	if depth > maxThriftDepth {
+		return fmt.Errorf("parquet bloom filter header nested too deeply")
+	}
+
+	switch typ {
+	case thriftBoolTrue, thriftBoolFalse:
+		return nil
+	case thriftByte:
+		_, err := d.byte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := d.uvarint()
+		return err
+	case thriftDouble:
+		if len(d.data)-d.pos < 8 {
+			return io.ErrUnexpectedEOF
+		}
+		d.pos += 8
+		return nil
+	case thriftBinary:
+		n, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if uint64(len(d.data)-d.pos) < n {
+			return io.ErrUnexpectedEOF
+		}
+		d.pos += int(n)
+		return nil
+	case thriftList, thriftSet:
+		b, err := d.byte()
+		if err != nil {
+			return err
+		}
+		size, elem := uint64(b>>4), b&0x0f
+		if size == 15 {
+			if size, err = d.uvarint(); err != nil {
+				return err
+			}
+		}
+		for range size {
+			if err := d.skipElem(elem, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftMap:
+		size, err := d.uvarint()
+		if err != nil || size == 0 {
+			return err
+		}
+		kv, err := d.byte()
+		if err != nil {
+			return err
+		}
+		for range size {
+			if err := d.skipElem(kv>>4, depth); err != nil {
+				return err
+			}
+			if err := d.skipElem(kv&0x0f, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		var lastID int16
+		for {
+			typ, _, err := d.fieldHeader(&lastID)
+			if err != nil {
+				return err
+			}
+			if typ == 0 {
+				return nil
+			}
+			if err := d.skip(typ, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("unknown thrift type %d in parquet bloom filter header", typ)
+}
+
+// skipElem skips a collection element. Booleans in collections take a byte,
+// unlike boolean fields whose value is part of the field header.
+func (d *thriftDecoder) skipElem(typ byte, depth int) error {
+	if typ == thriftBoolTrue || typ == thriftBoolFalse {
+		_, err := d.byte()
+		return err
+	}
+	return d.skip(typ, depth+1)
+}
@@ -0,0 +1,88 @@
+package bitbloom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestAppendParquetHeader(t *testing.T) {
+	// numBytes = 1024 is the zigzag varint 0x80 0x10.
+	want := []byte{
+		0x15, 0x80, 0x10,
+		0x1c, 0x1c, 0x00, 0x00,
+		0x1c, 0x1c, 0x00, 0x00,
+		0x1c, 0x1c, 0x00, 0x00,
+		0x00,
+	}
+	if got := AppendParquetHeader(nil, 1024); !bytes.Equal(got, want) {
+		t.Errorf("AppendParquetHeader = %x, want %x", got, want)
+	}
+}
+
+func TestSplitBlock_MarshalRoundTrip(t *testing.T) {
+	sb, _ := NewSplitBlock(100, 0.01)
+	sb.Add([]byte("foo"))
+	sb.Add([]byte("bar"))
+
+	data, err := sb.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	decoded, err := UnmarshalSplitBlock(data)
+	if err != nil {
+		t.Fatalf("UnmarshalSplitBlock failed: %v", err)
+	}
+	if decoded.NumBytes() != sb.NumBytes() {
+		t.Errorf("Expected %d bytes, got %d", sb.NumBytes(), decoded.NumBytes())
+	}
+	if !decoded.Test([]byte("foo")) || !decoded.Test([]byte("bar")) {
+		t.Error("Expected items to be present after round trip")
+	}
+
+	// ReadSplitBlock must stop at the end of the bitset.
+	r := bytes.NewBuffer(append(data, "trailer"...))
+	if _, err := ReadSplitBlock(struct{ io.Reader }{r}); err != nil {
+		t.Fatalf("ReadSplitBlock failed: %v", err)
+	}
+	if r.String() != "trailer" {
+		t.Errorf("Expected trailer to remain unread, got %q", r.String())
+	}
+}
+
+func TestParseParquetHeader_FieldOrderAndUnknownFields(t *testing.T) {
+	header := []byte{
+		0x2c, 0x1c, 0x00, 0x00, // 2: algorithm
+		0x1c, 0x1c, 0x00, 0x00, // 3: hash
+		0x05, 0x02, 0x80, 0x10, // 1: numBytes, long form as the id decreases
+		0x3c, 0x1c, 0x00, 0x00, // 4: compression
+		0x18, 0x03, 'a', 'b', 'c', // 5: unknown binary
+		0x19, 0x25, 0x02, 0x04, // 6: unknown list<i32>
+		0x00,
+	}
+
+	numBytes, n, err := parseParquetHeader(header)
+	if err != nil {
+		t.Fatalf("parseParquetHeader failed: %v", err)
+	}
+	if numBytes != 1024 || n != len(header) {
+		t.Errorf("Expected numBytes=1024 n=%d, got numBytes=%d n=%d", len(header), numBytes, n)
+	}
+}
+
+func TestParseParquetHeader_Invalid(t *testing.T) {
+	valid := AppendParquetHeader(nil, 64)
+	tests := map[string][]byte{
+		"truncated":         valid[:len(valid)-1],
+		"missing field":     {0x15, 0x80, 0x01, 0x00},
+		"bad size":          AppendParquetHeader(nil, 33),
+		"negative size":     AppendParquetHeader(nil, -32),
+		"unknown algorithm": append([]byte{0x15, 0x80, 0x01, 0x1c, 0x2c, 0x00, 0x00}, valid[7:]...),
+	}
+	for name, data := range tests {
+		if _, err := UnmarshalSplitBlock(append(data, make([]byte, 64)...)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package bitbloom
+
+import "fmt"
+
+// NewPartitioned creates a partitioned Bloom filter for `n` items with a
+// false positive probability of `p`, sized with OptimalM and OptimalK and
+// with m rounded up to a multiple of k.
+//
+// A partitioned filter splits its bit array into k slices of m/k bits and
+// sets the i-th bit of an item in slice i, so the bits of one item never
+// collide with each other and every slice fills at the same rate. Its false
+// positive rate is asymptotically that of the classic layout, and Stats
+// reports the exact fill of each slice. It is the layout used by the layers
+// of a ScalableFilter.
+//
+// The result is a *BloomFilter with the usual API; filters can only be
+// merged or compared with filters of the same layout.
+func NewPartitioned(n uint64, p float64) (*BloomFilter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+
+	m := OptimalM(n, p)
+	return NewPartitionedWithParams(m, OptimalK(m, n)), nil
+}
+
+// NewPartitionedWithParams creates a partitioned Bloom filter with `k` hash
+// functions and at least `m` bits, rounded up to a multiple of k. Locations
+// within each slice are probed as in New.
+func NewPartitionedWithParams(m, k uint64) *BloomFilter {
+	k = max(k, 1)
+	m = max((m+k-1)/k, 1) * k
+
+	bf := newBloomFilter(m, k)
+	bf.setProbe(probePartitioned | defaultProbe)
+	bf.useSparse()
+	return bf
+}
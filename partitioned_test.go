@@ -0,0 +1,165 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNewPartitioned(t *testing.T) {
+	bf, err := NewPartitioned(1000, 0.01)
+	if err != nil {
+		t.Fatalf("NewPartitioned failed: %v", err)
+	}
+	if bf.m%bf.k != 0 || bf.m < OptimalM(1000, 0.01) {
+		t.Errorf("Expected m rounded up to a multiple of k, got m=%d k=%d", bf.m, bf.k)
+	}
+	if _, err := NewPartitioned(1000, 1); err == nil {
+		t.Error("Expected error for p = 1")
+	}
+
+	bf = NewPartitionedWithParams(10, 4)
+	if bf.m != 12 || bf.k != 4 {
+		t.Errorf("Expected m=12 k=4, got m=%d k=%d", bf.m, bf.k)
+	}
+}
+
+func TestPartitioned_OneBitPerSlice(t *testing.T) {
+	bf := NewPartitionedWithParams(700, 7)
+	bf.Add([]byte("alice"))
+
+	s := bf.Stats()
+	if len(s.SliceFillRatios) != 7 {
+		t.Fatalf("Expected 7 slice fill ratios, got %d", len(s.SliceFillRatios))
+	}
+	for i, fill := range s.SliceFillRatios {
+		if fill != 1.0/100 {
+			t.Errorf("Expected one bit set in slice %d, fill %v", i, fill)
+		}
+	}
+	if want := math.Pow(0.01, 7); math.Abs(s.FalsePositiveRate-want) > 1e-20 {
+		t.Errorf("Expected false positive rate %g, got %g", want, s.FalsePositiveRate)
+	}
+	if math.Abs(s.EstimatedCount-1) > 0.01 {
+		t.Errorf("Expected estimated count near 1, got %f", s.EstimatedCount)
+	}
+	if bf.FalsePositiveRate() != s.FalsePositiveRate {
+		t.Errorf("FalsePositiveRate %g disagrees with Stats %g", bf.FalsePositiveRate(), s.FalsePositiveRate)
+	}
+
+	classic := NewWithParams(700, 7)
+	classic.Add([]byte("alice"))
+	if classic.Stats().SliceFillRatios != nil {
+		t.Error("Expected no slice fill ratios for a classic filter")
+	}
+}
+
+func TestPartitioned_FalsePositiveRate(t *testing.T) {
+	bf, _ := NewPartitioned(10000, 0.01)
+	for i := range 10000 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	for i := range 10000 {
+		if !bf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range 100000 {
+		if bf.Test(fmt.Appendf(nil, "other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 100000; rate > 0.015 {
+		t.Errorf("False positive rate %f exceeds 1.5%%", rate)
+	}
+
+	s := bf.Stats()
+	for i, fill := range s.SliceFillRatios {
+		if math.Abs(fill-0.5) > 0.05 {
+			t.Errorf("Expected slice %d about half full, got %f", i, fill)
+		}
+	}
+}
+
+func TestPartitioned_Serialization(t *testing.T) {
+	bf, _ := NewPartitioned(1000, 0.01)
+	bf.Add([]byte("alice"))
+
+	data, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	decoded, err := UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !decoded.Equal(bf) || decoded.Stats().SliceFillRatios == nil {
+		t.Error("Expected the decoded filter to be partitioned and equal")
+	}
+	decoded.Add([]byte("bob"))
+	bf.Add([]byte("bob"))
+	if !decoded.Equal(bf) {
+		t.Error("Expected the decoded filter to place bits like the original")
+	}
+
+	view, err := View(data)
+	if err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+	if !view.Test([]byte("alice")) || view.Stats().SliceFillRatios == nil {
+		t.Error("Expected the view to read the partitioned layout")
+	}
+	thawed := view.Thaw()
+	thawed.Add([]byte("bob"))
+	if !thawed.Equal(bf) {
+		t.Error("Expected Thaw to keep the partitioned layout")
+	}
+
+	text, err := json.Marshal(bf)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	var fromJSON BloomFilter
+	if err := json.Unmarshal(text, &fromJSON); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if !fromJSON.Equal(bf) {
+		t.Errorf("Expected the JSON round trip to keep the layout: %s", text)
+	}
+}
+
+func TestPartitioned_InvalidHeader(t *testing.T) {
+	data, _ := NewPartitionedWithParams(12, 4).MarshalBinary()
+
+	bad := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(bad[8:16], packK(5, 0, probePartitioned))
+	if _, err := UnmarshalBinary(bad); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected ErrInvalidParams for m not a multiple of k, got %v", err)
+	}
+
+	binary.LittleEndian.PutUint64(bad[8:16], packK(4, 0, 0x80))
+	if _, err := UnmarshalBinary(bad); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme for unknown probing, got %v", err)
+	}
+}
+
+func TestPartitioned_LayoutMismatch(t *testing.T) {
+	partitioned := NewPartitionedWithParams(700, 7)
+	classic := NewWithParams(700, 7)
+
+	if err := classic.Merge(partitioned); err == nil {
+		t.Error("Expected error merging a partitioned filter into a classic one")
+	}
+	if classic.Equal(partitioned) {
+		t.Error("Expected filters of different layouts to differ")
+	}
+	d, _ := partitioned.Delta(0)
+	if err := classic.ApplyDelta(d); err == nil {
+		t.Error("Expected error applying a partitioned delta to a classic filter")
+	}
+}
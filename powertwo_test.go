@@ -0,0 +1,182 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+func TestNewPowerOfTwo(t *testing.T) {
+	bf, err := NewPowerOfTwo(1000, 0.01)
+	if err != nil {
+		t.Fatalf("NewPowerOfTwo failed: %v", err)
+	}
+	if bf.m != 16384 || bf.k != OptimalK(OptimalM(1000, 0.01), 1000) {
+		t.Errorf("Expected m = 16384 and the optimal k, got m=%d k=%d", bf.m, bf.k)
+	}
+	if _, err := NewPowerOfTwo(1000, 0); err == nil {
+		t.Error("Expected error for p = 0")
+	}
+
+	for m, want := range map[uint64]uint64{0: 1, 1: 1, 64: 64, 65: 128, 1000: 1024} {
+		if got := NewPowerOfTwoWithParams(m, 3).m; got != want {
+			t.Errorf("NewPowerOfTwoWithParams(%d, 3) has m = %d, expected %d", m, got, want)
+		}
+	}
+}
+
+func TestProbing_FalsePositiveRate(t *testing.T) {
+	pow2, _ := NewPowerOfTwo(10000, 0.01)
+	def, _ := New(10000, 0.01)
+	for name, bf := range map[string]*BloomFilter{"power of two": pow2, "default": def} {
+		for i := range 10000 {
+			bf.Add(fmt.Appendf(nil, "item-%d", i))
+		}
+		for i := range 10000 {
+			if !bf.Test(fmt.Appendf(nil, "item-%d", i)) {
+				t.Fatalf("%s: false negative for item-%d", name, i)
+			}
+		}
+
+		falsePositives := 0
+		for i := range 100000 {
+			if bf.Test(fmt.Appendf(nil, "other-%d", i)) {
+				falsePositives++
+			}
+		}
+		if rate := float64(falsePositives) / 100000; rate > 0.015 {
+			t.Errorf("%s: false positive rate %f exceeds 1.5%%", name, rate)
+		}
+	}
+}
+
+func TestProbing_DigestMatchesHasher(t *testing.T) {
+	// Frozen filters and durable logs place bits from a digest; they must
+	// agree with Add for every probing.
+	for name, bf := range map[string]*BloomFilter{
+		"default":      NewWithParams(1000, 7),
+		"power of two": NewPowerOfTwoWithParams(1000, 7),
+		"partitioned":  NewPartitionedWithParams(1000, 7),
+		"legacy":       NewWithStorage(storage.NewHeap(1000), 7),
+	} {
+		bf.Add([]byte("alice"))
+		other := bf.Clone()
+		other.Reset()
+		other.addDigest(hasher.SchemeMurmur.Sum([]byte("alice")))
+		if !other.Equal(bf) {
+			t.Errorf("%s: addDigest placed different bits than Add", name)
+		}
+		if !bf.Freeze().Test([]byte("alice")) {
+			t.Errorf("%s: frozen filter lost the item", name)
+		}
+	}
+}
+
+func TestProbing_LegacyFiltersDecode(t *testing.T) {
+	// A filter written before probing was recorded has a zero probe byte
+	// and must keep using (h1 + i*h2) mod m.
+	legacy := NewWithStorage(storage.NewHeap(1000), 7)
+	legacy.Add([]byte("alice"))
+	data, _ := legacy.MarshalBinary()
+	if _, _, p := unpackK(binary.LittleEndian.Uint64(data[8:16])); p != 0 {
+		t.Fatalf("Expected a zero probe byte, got %#x", uint8(p))
+	}
+
+	decoded, err := UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	decoded.Add([]byte("bob"))
+	legacy.Add([]byte("bob"))
+	if !decoded.Equal(legacy) {
+		t.Error("Expected the decoded filter to probe as the legacy one")
+	}
+	for _, h := range hasher.New().Hashes([]byte("bob"), 7, 1000) {
+		if !decoded.storage.GetBit(h) {
+			t.Errorf("Expected modulo location %d to be set", h)
+		}
+	}
+
+	bf := NewWithParams(1000, 7)
+	bf.Add([]byte("alice"))
+	data, _ = bf.MarshalBinary()
+	decoded, _ = UnmarshalBinary(data)
+	if decoded.probe != defaultProbe || !decoded.Equal(bf) {
+		t.Errorf("Expected the probing to round trip, got %#x", uint8(decoded.probe))
+	}
+}
+
+func TestProbing_InvalidHeader(t *testing.T) {
+	withProbe := func(m uint64, scheme hasher.Scheme, p probe) []byte {
+		data := make([]byte, headerSize+(m+63)/64*8)
+		binary.LittleEndian.PutUint64(data[0:8], m)
+		binary.LittleEndian.PutUint64(data[8:16], packK(4, scheme, p))
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"unknown reduction", withProbe(64, 0, 3<<probeReductionShift), ErrUnknownScheme},
+		{"other scheme", withProbe(64, hasher.SchemeBitsAndBlooms, defaultProbe), ErrUnknownScheme},
+		{"mask over odd size", withProbe(100, 0, probeMask), ErrInvalidParams},
+		{"mask over odd slices", withProbe(96, 0, probeMask|probePartitioned), ErrInvalidParams},
+	}
+	for _, tt := range tests {
+		if _, err := UnmarshalBinary(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: UnmarshalBinary error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := UnmarshalBinary(withProbe(128, 0, probeMask|probePartitioned)); err != nil {
+		t.Errorf("Expected mask probing over 4 slices of 32 bits, got %v", err)
+	}
+}
+
+func TestProbing_JSON(t *testing.T) {
+	bf := NewPowerOfTwoWithParams(1000, 5)
+	bf.Add([]byte("alice"))
+
+	text, err := json.Marshal(bf)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if !strings.Contains(string(text), `"probing":"enhanced-mask"`) {
+		t.Errorf("Expected the probing in %s", text)
+	}
+	var decoded BloomFilter
+	if err := json.Unmarshal(text, &decoded); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if !decoded.Equal(bf) {
+		t.Error("Expected the JSON round trip to keep the probing")
+	}
+
+	legacy, _ := json.Marshal(NewWithStorage(storage.NewHeap(64), 3))
+	if strings.Contains(string(legacy), "probing") {
+		t.Errorf("Expected no probing for a legacy filter: %s", legacy)
+	}
+	bad := `{"m":64,"k":3,"count":0,"hasher":"murmur3","probing":"division","bits":"AAAAAAAAAAA="}`
+	if err := json.Unmarshal([]byte(bad), &decoded); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme for an unknown probing, got %v", err)
+	}
+}
+
+func TestProbing_Mismatch(t *testing.T) {
+	legacy := NewWithStorage(storage.NewHeap(1024), 7)
+	pow2 := NewPowerOfTwoWithParams(1024, 7)
+	if err := legacy.Merge(pow2); err == nil {
+		t.Error("Expected error merging filters with different probing")
+	}
+	if legacy.Equal(pow2) {
+		t.Error("Expected filters with different probing to differ")
+	}
+}
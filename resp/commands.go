@@ -0,0 +1,320 @@
+package resp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/umang-sinha/bitbloom"
+)
+
+// Defaults used when BF.ADD or BF.MADD create a missing key.
+const (
+	DefaultErrorRate = 0.01
+	DefaultCapacity  = 100
+	DefaultExpansion = 2
+)
+
+var errFull = errors.New("ERR non scaling filter is full")
+
+// filter is a value in the keyspace: either a scalable filter or a fixed
+// size one created with NONSCALING.
+type filter struct {
+	scalable *bitbloom.ScalableFilter
+
+	// mu makes the capacity check and insertion of a non-scaling filter
+	// atomic.
+	mu       sync.Mutex
+	fixed    *bitbloom.BloomFilter
+	capacity uint64
+	items    uint64
+}
+
+func newFilter(errorRate float64, capacity, expansion uint64, nonScaling bool) (*filter, error) {
+	if nonScaling {
+		bf, err := bitbloom.New(capacity, errorRate)
+		if err != nil {
+			return nil, err
+		}
+		return &filter{fixed: bf, capacity: capacity}, nil
+	}
+
+	sf, err := bitbloom.NewScalable(capacity, errorRate, expansion)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{scalable: sf}, nil
+}
+
+// add inserts item and reports whether it was newly added, as BF.ADD does.
+func (f *filter) add(item []byte) (bool, error) {
+	if f.scalable != nil {
+		return !f.scalable.TestAndAdd(item), nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fixed.Test(item) {
+		return false, nil
+	}
+	if f.items >= f.capacity {
+		return false, errFull
+	}
+	f.fixed.Add(item)
+	f.items++
+	return true, nil
+}
+
+func (f *filter) test(item []byte) bool {
+	if f.scalable != nil {
+		return f.scalable.Test(item)
+	}
+	return f.fixed.Test(item)
+}
+
+type filterInfo struct {
+	capacity, size, filters, items, expansion int64
+	nonScaling                                bool
+}
+
+func (f *filter) info() filterInfo {
+	if f.scalable != nil {
+		return filterInfo{
+			capacity:  int64(f.scalable.Capacity()),
+			size:      int64(f.scalable.Stats().MemoryUsage),
+			filters:   int64(f.scalable.Layers()),
+			items:     int64(f.scalable.Count()),
+			expansion: int64(f.scalable.Expansion()),
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return filterInfo{
+		capacity:   int64(f.capacity),
+		size:       int64(f.fixed.MemoryUsage()),
+		filters:    1,
+		items:      int64(f.items),
+		nonScaling: true,
+	}
+}
+
+func (s *Server) lookup(key []byte) *filter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.filters[string(key)]
+}
+
+func (s *Server) lookupOrCreate(key []byte) (*filter, error) {
+	if f := s.lookup(key); f != nil {
+		return f, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.filters[string(key)]; ok {
+		return f, nil
+	}
+	f, err := newFilter(DefaultErrorRate, DefaultCapacity, DefaultExpansion, false)
+	if err != nil {
+		return nil, err
+	}
+	s.filters[string(key)] = f
+	return f, nil
+}
+
+// cmdReserve implements
+// BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING].
+func cmdReserve(s *Server, sess *session, args [][]byte) {
+	errorRate, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil {
+		sess.w.error("ERR bad error rate")
+		return
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		sess.w.error("ERR (0 < error rate range < 1)")
+		return
+	}
+	capacity, err := strconv.ParseUint(string(args[3]), 10, 64)
+	if err != nil {
+		sess.w.error("ERR bad capacity")
+		return
+	}
+	if capacity == 0 {
+		sess.w.error("ERR (capacity should be larger than 0)")
+		return
+	}
+	if capacity > s.opts.MaxCapacity {
+		sess.w.error("ERR capacity exceeds limit " + strconv.FormatUint(s.opts.MaxCapacity, 10))
+		return
+	}
+
+	expansion := uint64(DefaultExpansion)
+	expansionSet, nonScaling := false, false
+	for i := 4; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "EXPANSION":
+			if i+1 == len(args) {
+				sess.w.error("ERR no expansion")
+				return
+			}
+			i++
+			expansion, err = strconv.ParseUint(string(args[i]), 10, 64)
+			if err != nil || expansion < 1 {
+				sess.w.error("ERR (expansion should be greater or equal to 1)")
+				return
+			}
+			if expansion > s.opts.MaxExpansion {
+				sess.w.error("ERR expansion exceeds limit " + strconv.FormatUint(s.opts.MaxExpansion, 10))
+				return
+			}
+			expansionSet = true
+		case "NONSCALING":
+			nonScaling = true
+		default:
+			sess.w.error("ERR syntax error")
+			return
+		}
+	}
+	if nonScaling && expansionSet {
+		sess.w.error("ERR nonscaling filters cannot expand")
+		return
+	}
+
+	f, err := newFilter(errorRate, capacity, expansion, nonScaling)
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.filters[string(args[1])]; exists {
+		sess.w.error("ERR item exists")
+		return
+	}
+	s.filters[string(args[1])] = f
+	sess.w.simple("OK")
+}
+
+// cmdAdd implements BF.ADD key item.
+func cmdAdd(s *Server, sess *session, args [][]byte) {
+	f, err := s.lookupOrCreate(args[1])
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	added, err := f.add(args[2])
+	if err != nil {
+		sess.w.error(err.Error())
+		return
+	}
+	sess.w.bool(added)
+}
+
+// cmdMAdd implements BF.MADD key item [item ...]. An item that cannot be
+// added because a non-scaling filter is full is reported as an error in its
+// slot of the reply.
+func cmdMAdd(s *Server, sess *session, args [][]byte) {
+	f, err := s.lookupOrCreate(args[1])
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	items := args[2:]
+	sess.w.array(len(items))
+	for _, item := range items {
+		added, err := f.add(item)
+		if err != nil {
+			sess.w.error(err.Error())
+			continue
+		}
+		sess.w.bool(added)
+	}
+}
+
+// cmdExistsItem implements BF.EXISTS key item.
+func cmdExistsItem(s *Server, sess *session, args [][]byte) {
+	f := s.lookup(args[1])
+	sess.w.bool(f != nil && f.test(args[2]))
+}
+
+// cmdMExists implements BF.MEXISTS key item [item ...].
+func cmdMExists(s *Server, sess *session, args [][]byte) {
+	f := s.lookup(args[1])
+
+	items := args[2:]
+	sess.w.array(len(items))
+	for _, item := range items {
+		sess.w.bool(f != nil && f.test(item))
+	}
+}
+
+// cmdInfo implements BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION].
+func cmdInfo(s *Server, sess *session, args [][]byte) {
+	if len(args) > 3 {
+		sess.w.error("ERR wrong number of arguments for 'bf.info' command")
+		return
+	}
+
+	f := s.lookup(args[1])
+	if f == nil {
+		sess.w.error("ERR not found")
+		return
+	}
+	info := f.info()
+	w := sess.w
+
+	expansion := func() {
+		if info.nonScaling {
+			w.null()
+		} else {
+			w.int(info.expansion)
+		}
+	}
+
+	if len(args) == 3 {
+		field := strings.ToUpper(string(args[2]))
+		switch field {
+		case "CAPACITY", "SIZE", "FILTERS", "ITEMS", "EXPANSION":
+		default:
+			w.error("ERR Invalid information value")
+			return
+		}
+
+		w.array(1)
+		switch field {
+		case "CAPACITY":
+			w.int(info.capacity)
+		case "SIZE":
+			w.int(info.size)
+		case "FILTERS":
+			w.int(info.filters)
+		case "ITEMS":
+			w.int(info.items)
+		case "EXPANSION":
+			expansion()
+		}
+		return
+	}
+
+	w.mapHeader(5)
+	w.simple("Capacity")
+	w.int(info.capacity)
+	w.simple("Size")
+	w.int(info.size)
+	w.simple("Number of filters")
+	w.int(info.filters)
+	w.simple("Number of items inserted")
+	w.int(info.items)
+	w.simple("Expansion rate")
+	expansion()
+}
@@ -0,0 +1,189 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxArgs limits the number of arguments in a single command.
+	maxArgs = 1 << 20
+	// maxBulkLen limits the size of a single argument, as in Redis.
+	maxBulkLen = 512 << 20
+	// bulkChunk is the size of the buffer a bulk string is first read
+	// into. It grows as bytes arrive, so a length prefix alone cannot make
+	// the server allocate up to maxBulkLen.
+	bulkChunk = 64 << 10
+)
+
+// protocolError reports malformed input. The connection is closed after it
+// is returned to the client, since the stream can no longer be framed.
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// readCommand reads a command sent either as a RESP array of bulk strings or
+// as an inline command.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	if line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, f := range fields {
+			args[i] = []byte(f)
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, protocolError("invalid multibulk length")
+	}
+
+	args := make([][]byte, 0, min(max(n, 0), 1024))
+	for range n {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError(fmt.Sprintf("expected '$', got %q", line))
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, protocolError("invalid bulk length")
+		}
+
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads a bulk string of size bytes and its CRLF terminator,
+// at most doubling its buffer each time the buffer fills.
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	buf := make([]byte, 0, min(size+2, bulkChunk))
+	for len(buf) < size+2 {
+		n := min(size+2-len(buf), max(len(buf), bulkChunk))
+		buf = slices.Grow(buf, n)
+		read, err := io.ReadFull(r, buf[len(buf):len(buf)+n])
+		buf = buf[:len(buf)+read]
+		if err != nil {
+			return nil, err
+		}
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return nil, protocolError("bulk string not terminated by CRLF")
+	}
+	return buf[:size], nil
+}
+
+// readLine reads a line terminated by CRLF or LF, without the terminator.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, protocolError("line too long")
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// writer encodes replies in RESP2 or RESP3, depending on the protocol the
+// client negotiated with HELLO.
+type writer struct {
+	*bufio.Writer
+	proto int
+}
+
+func (w *writer) simple(s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) error(s string) {
+	w.WriteByte('-')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) int(n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+func (w *writer) bulk(s string) {
+	w.WriteByte('$')
+	w.WriteString(strconv.Itoa(len(s)))
+	w.WriteString("\r\n")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.WriteString("_\r\n")
+		return
+	}
+	w.WriteString("$-1\r\n")
+}
+
+// bool writes a RESP3 boolean, or the integers 1 and 0 in RESP2.
+func (w *writer) bool(b bool) {
+	if w.proto >= 3 {
+		if b {
+			w.WriteString("#t\r\n")
+		} else {
+			w.WriteString("#f\r\n")
+		}
+		return
+	}
+	if b {
+		w.int(1)
+	} else {
+		w.int(0)
+	}
+}
+
+func (w *writer) array(n int) {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(n))
+	w.WriteString("\r\n")
+}
+
+// mapHeader starts a RESP3 map of n pairs, or a flat array of 2n elements
+// in RESP2.
+func (w *writer) mapHeader(n int) {
+	if w.proto >= 3 {
+		w.WriteByte('%')
+		w.WriteString(strconv.Itoa(n))
+		w.WriteString("\r\n")
+		return
+	}
+	w.array(2 * n)
+}
@@ -0,0 +1,45 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand_LargeBulk(t *testing.T) {
+	arg := bytes.Repeat([]byte("x"), 3*bulkChunk+5)
+	in := fmt.Sprintf("*2\r\n$2\r\nhi\r\n$%d\r\n%s\r\n", len(arg), arg)
+	args, err := readCommand(bufio.NewReader(strings.NewReader(in)))
+	if err != nil {
+		t.Fatalf("readCommand failed: %v", err)
+	}
+	if len(args) != 2 || string(args[0]) != "hi" || !bytes.Equal(args[1], arg) {
+		t.Errorf("Expected both arguments back intact, got %d arguments", len(args))
+	}
+
+	bad := fmt.Sprintf("*1\r\n$%d\r\n%sxx", len(arg), arg)
+	if _, err := readCommand(bufio.NewReader(strings.NewReader(bad))); !errors.As(err, new(protocolError)) {
+		t.Errorf("Expected a protocol error for a missing CRLF, got %v", err)
+	}
+}
+
+func TestReadCommand_BulkLengthNotTrusted(t *testing.T) {
+	in := fmt.Sprintf("*1\r\n$%d\r\nabc", maxBulkLen)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := readCommand(bufio.NewReader(strings.NewReader(in)))
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected a truncated bulk string to fail, got %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("Expected a bulk length alone not to allocate, got %d bytes", allocated)
+	}
+}
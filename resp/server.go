@@ -0,0 +1,345 @@
+/*
+Package resp serves bitbloom filters over the Redis serialization protocol,
+implementing the RedisBloom commands so existing Redis clients can use
+bitbloom in place of a Redis server with the RedisBloom module:
+
+	BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+	BF.ADD key item
+	BF.MADD key item [item ...]
+	BF.EXISTS key item
+	BF.MEXISTS key item [item ...]
+	BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+
+Scaling filters are backed by bitbloom.ScalableFilter. BF.ADD and BF.MADD
+create a missing key with an error rate of 0.01, a capacity of 100 and an
+expansion of 2, as RedisBloom does. BF.RESERVE rejects a capacity or
+expansion beyond the limits of the Server's Options.
+
+Both RESP2 and RESP3 are supported. Connections start in RESP2 and switch
+with HELLO 3, after which boolean replies and BF.INFO use the RESP3 boolean
+and map types. PING, ECHO, HELLO, QUIT, SELECT, CLIENT, COMMAND, DEL, EXISTS,
+FLUSHDB and FLUSHALL are implemented to the extent clients need them.
+*/
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrServerClosed is returned by Serve after Close is called.
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Limits applied by BF.RESERVE when the Options fields are zero.
+const (
+	// DefaultMaxCapacity is about 1.2 GB of bits at an error rate of 0.01.
+	DefaultMaxCapacity  = 1 << 30
+	DefaultMaxExpansion = 1024
+)
+
+// Options configures a Server.
+type Options struct {
+	// MaxCapacity limits the capacity BF.RESERVE accepts, bounding the
+	// memory a single command can allocate.
+	MaxCapacity uint64
+	// MaxExpansion limits the expansion BF.RESERVE accepts, bounding the
+	// growth of a scaling filter each time it fills.
+	MaxExpansion uint64
+}
+
+// Server is a RESP listener hosting a keyspace of Bloom filters.
+type Server struct {
+	opts Options
+
+	mu      sync.RWMutex
+	filters map[string]*filter
+
+	connMu    sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer returns a Server with an empty keyspace and the default
+// limits.
+func NewServer() *Server {
+	return NewServerWithOptions(Options{})
+}
+
+// NewServerWithOptions returns a Server with an empty keyspace and the
+// given limits.
+func NewServerWithOptions(opts Options) *Server {
+	if opts.MaxCapacity == 0 {
+		opts.MaxCapacity = DefaultMaxCapacity
+	}
+	if opts.MaxExpansion == 0 {
+		opts.MaxExpansion = DefaultMaxExpansion
+	}
+	return &Server{
+		opts:      opts,
+		filters:   make(map[string]*filter),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on ln and serves each one in its own goroutine.
+// It returns ErrServerClosed after Close, or the error from Accept.
+func (s *Server) Serve(ln net.Listener) error {
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.connMu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.connMu.Lock()
+			closed := s.closed
+			delete(s.listeners, ln)
+			s.connMu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.connMu.Lock()
+		if s.closed {
+			s.connMu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.connMu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+
+			s.connMu.Lock()
+			delete(s.conns, conn)
+			s.connMu.Unlock()
+		}()
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Close stops all listeners, closes every open connection and waits for
+// their handlers to return.
+func (s *Server) Close() error {
+	s.connMu.Lock()
+	s.closed = true
+	var errs []error
+	for ln := range s.listeners {
+		errs = append(errs, ln.Close())
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connMu.Unlock()
+
+	s.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// session is the per-connection state.
+type session struct {
+	w    *writer
+	quit bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, 64*1024)
+	sess := &session{w: &writer{Writer: bufio.NewWriterSize(conn, 64*1024), proto: 2}}
+
+	for !sess.quit {
+		args, err := readCommand(r)
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				sess.w.error("ERR " + perr.Error())
+				sess.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.dispatch(sess, args)
+
+		// Flush once a pipelined batch has been handled.
+		if r.Buffered() == 0 || sess.quit {
+			if err := sess.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+type handler func(s *Server, sess *session, args [][]byte)
+
+// commandTable maps lower-case command names to their handlers and arity.
+// A positive arity is exact; a negative arity is a minimum, as in Redis.
+var commandTable map[string]struct {
+	fn    handler
+	arity int
+}
+
+func init() {
+	commandTable = map[string]struct {
+		fn    handler
+		arity int
+	}{
+		"ping":       {cmdPing, -1},
+		"echo":       {cmdEcho, 2},
+		"hello":      {cmdHello, -1},
+		"quit":       {cmdQuit, 1},
+		"select":     {cmdSelect, 2},
+		"client":     {cmdOK, -2},
+		"command":    {cmdCommand, -1},
+		"del":        {cmdDel, -2},
+		"exists":     {cmdExists, -2},
+		"flushdb":    {cmdFlush, -1},
+		"flushall":   {cmdFlush, -1},
+		"bf.reserve": {cmdReserve, -4},
+		"bf.add":     {cmdAdd, 3},
+		"bf.madd":    {cmdMAdd, -3},
+		"bf.exists":  {cmdExistsItem, 3},
+		"bf.mexists": {cmdMExists, -3},
+		"bf.info":    {cmdInfo, -2},
+	}
+}
+
+func (s *Server) dispatch(sess *session, args [][]byte) {
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commandTable[name]
+	if !ok {
+		sess.w.error("ERR unknown command '" + string(args[0]) + "'")
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		sess.w.error("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	cmd.fn(s, sess, args)
+}
+
+func cmdPing(s *Server, sess *session, args [][]byte) {
+	switch len(args) {
+	case 1:
+		sess.w.simple("PONG")
+	case 2:
+		sess.w.bulk(string(args[1]))
+	default:
+		sess.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(s *Server, sess *session, args [][]byte) {
+	sess.w.bulk(string(args[1]))
+}
+
+// cmdHello negotiates the protocol version. AUTH and SETNAME options are
+// accepted and ignored.
+func cmdHello(s *Server, sess *session, args [][]byte) {
+	if len(args) > 1 {
+		switch string(args[1]) {
+		case "2":
+			sess.w.proto = 2
+		case "3":
+			sess.w.proto = 3
+		default:
+			sess.w.error("NOPROTO unsupported protocol version")
+			return
+		}
+	}
+
+	w := sess.w
+	w.mapHeader(5)
+	w.bulk("server")
+	w.bulk("bitbloom")
+	w.bulk("proto")
+	w.int(int64(w.proto))
+	w.bulk("mode")
+	w.bulk("standalone")
+	w.bulk("role")
+	w.bulk("master")
+	w.bulk("modules")
+	w.array(0)
+}
+
+func cmdQuit(s *Server, sess *session, args [][]byte) {
+	sess.w.simple("OK")
+	sess.quit = true
+}
+
+func cmdSelect(s *Server, sess *session, args [][]byte) {
+	if string(args[1]) != "0" {
+		sess.w.error("ERR DB index is out of range")
+		return
+	}
+	sess.w.simple("OK")
+}
+
+func cmdOK(s *Server, sess *session, args [][]byte) {
+	sess.w.simple("OK")
+}
+
+func cmdCommand(s *Server, sess *session, args [][]byte) {
+	sess.w.array(0)
+}
+
+func cmdDel(s *Server, sess *session, args [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, key := range args[1:] {
+		if _, ok := s.filters[string(key)]; ok {
+			delete(s.filters, string(key))
+			n++
+		}
+	}
+	sess.w.int(n)
+}
+
+func cmdExists(s *Server, sess *session, args [][]byte) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, key := range args[1:] {
+		if _, ok := s.filters[string(key)]; ok {
+			n++
+		}
+	}
+	sess.w.int(n)
+}
+
+func cmdFlush(s *Server, sess *session, args [][]byte) {
+	s.mu.Lock()
+	s.filters = make(map[string]*filter)
+	s.mu.Unlock()
+
+	sess.w.simple("OK")
+}
@@ -0,0 +1,213 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is a minimal RESP client that returns raw replies, so tests can
+// check the exact wire encoding.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newTestServer(t *testing.T) *client {
+	t.Helper()
+	return newTestServerWithOptions(t, Options{})
+}
+
+func newTestServerWithOptions(t *testing.T, opts Options) *client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServerWithOptions(opts)
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(t *testing.T, args ...string) {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reply reads one complete reply and returns its raw encoding.
+func (c *client) reply(t *testing.T) string {
+	t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	switch line[0] {
+	case '$':
+		fmt.Sscanf(line[1:], "%d", &n)
+		if n < 0 {
+			return line
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			t.Fatal(err)
+		}
+		return line + string(buf)
+	case '*', '%':
+		fmt.Sscanf(line[1:], "%d", &n)
+		if line[0] == '%' {
+			n *= 2
+		}
+		for range n {
+			line += c.reply(t)
+		}
+	}
+	return line
+}
+
+func (c *client) do(t *testing.T, args ...string) string {
+	t.Helper()
+	c.send(t, args...)
+	return c.reply(t)
+}
+
+func expect(t *testing.T, c *client, want string, args ...string) {
+	t.Helper()
+	if got := c.do(t, args...); got != want {
+		t.Errorf("%v: expected %q, got %q", args, want, got)
+	}
+}
+
+func TestServer_AddExists(t *testing.T) {
+	c := newTestServer(t)
+
+	expect(t, c, "+PONG\r\n", "PING")
+	expect(t, c, ":1\r\n", "BF.ADD", "bf", "foo")
+	expect(t, c, ":0\r\n", "BF.ADD", "bf", "foo")
+	expect(t, c, ":1\r\n", "BF.EXISTS", "bf", "foo")
+	expect(t, c, ":0\r\n", "BF.EXISTS", "bf", "bar")
+	expect(t, c, ":0\r\n", "BF.EXISTS", "missing", "foo")
+	expect(t, c, "*3\r\n:1\r\n:0\r\n:1\r\n", "BF.MADD", "bf", "bar", "foo", "baz")
+	expect(t, c, "*3\r\n:1\r\n:1\r\n:0\r\n", "BF.MEXISTS", "bf", "bar", "baz", "qux")
+	expect(t, c, ":1\r\n", "EXISTS", "bf")
+	expect(t, c, ":1\r\n", "DEL", "bf")
+	expect(t, c, ":0\r\n", "BF.EXISTS", "bf", "foo")
+}
+
+func TestServer_Reserve(t *testing.T) {
+	c := newTestServer(t)
+
+	expect(t, c, "+OK\r\n", "BF.RESERVE", "bf", "0.01", "10", "EXPANSION", "4")
+	expect(t, c, "-ERR item exists\r\n", "BF.RESERVE", "bf", "0.01", "10")
+	expect(t, c, "-ERR (0 < error rate range < 1)\r\n", "BF.RESERVE", "x", "1.5", "10")
+	expect(t, c, "-ERR (capacity should be larger than 0)\r\n", "BF.RESERVE", "x", "0.01", "0")
+	expect(t, c, "-ERR nonscaling filters cannot expand\r\n", "BF.RESERVE", "x", "0.01", "10", "EXPANSION", "2", "NONSCALING")
+	expect(t, c, "-ERR syntax error\r\n", "BF.RESERVE", "x", "0.01", "10", "BOGUS")
+
+	for i := range 20 {
+		c.do(t, "BF.ADD", "bf", fmt.Sprintf("item-%d", i))
+	}
+	expect(t, c, "*1\r\n:2\r\n", "BF.INFO", "bf", "FILTERS")
+	expect(t, c, "*1\r\n:50\r\n", "BF.INFO", "bf", "CAPACITY")
+	expect(t, c, "*1\r\n:4\r\n", "BF.INFO", "bf", "EXPANSION")
+	expect(t, c, "-ERR Invalid information value\r\n", "BF.INFO", "bf", "BOGUS")
+	expect(t, c, "-ERR not found\r\n", "BF.INFO", "missing")
+}
+
+func TestServer_ReserveLimits(t *testing.T) {
+	c := newTestServer(t)
+	expect(t, c, "-ERR capacity exceeds limit 1073741824\r\n", "BF.RESERVE", "x", "0.01", "9223372036854775807")
+	expect(t, c, "-ERR expansion exceeds limit 1024\r\n", "BF.RESERVE", "x", "0.01", "10", "EXPANSION", "9223372036854775807")
+
+	c = newTestServerWithOptions(t, Options{MaxCapacity: 1000, MaxExpansion: 4})
+	expect(t, c, "-ERR capacity exceeds limit 1000\r\n", "BF.RESERVE", "x", "0.01", "1001", "NONSCALING")
+	expect(t, c, "-ERR expansion exceeds limit 4\r\n", "BF.RESERVE", "x", "0.01", "10", "EXPANSION", "5")
+	expect(t, c, "+OK\r\n", "BF.RESERVE", "x", "0.01", "1000", "EXPANSION", "4")
+}
+
+func TestServer_NonScaling(t *testing.T) {
+	c := newTestServer(t)
+
+	expect(t, c, "+OK\r\n", "BF.RESERVE", "bf", "0.001", "2", "NONSCALING")
+	expect(t, c, "*3\r\n:1\r\n:1\r\n-ERR non scaling filter is full\r\n", "BF.MADD", "bf", "a", "b", "c")
+	expect(t, c, "-ERR non scaling filter is full\r\n", "BF.ADD", "bf", "d")
+	expect(t, c, "*1\r\n$-1\r\n", "BF.INFO", "bf", "EXPANSION")
+	expect(t, c, "*1\r\n:2\r\n", "BF.INFO", "bf", "ITEMS")
+}
+
+func TestServer_RESP3(t *testing.T) {
+	c := newTestServer(t)
+
+	hello := c.do(t, "HELLO", "3")
+	if !strings.HasPrefix(hello, "%5\r\n") || !strings.Contains(hello, "$5\r\nproto\r\n:3\r\n") {
+		t.Errorf("Unexpected HELLO 3 reply: %q", hello)
+	}
+	expect(t, c, "#t\r\n", "BF.ADD", "bf", "foo")
+	expect(t, c, "*2\r\n#t\r\n#f\r\n", "BF.MEXISTS", "bf", "foo", "bar")
+
+	info := c.do(t, "BF.INFO", "bf")
+	if !strings.HasPrefix(info, "%5\r\n+Capacity\r\n:100\r\n") || !strings.Contains(info, "+Number of items inserted\r\n:1\r\n") {
+		t.Errorf("Unexpected RESP3 BF.INFO reply: %q", info)
+	}
+
+	expect(t, c, "-NOPROTO unsupported protocol version\r\n", "HELLO", "4")
+	hello = c.do(t, "HELLO", "2")
+	if !strings.HasPrefix(hello, "*10\r\n") {
+		t.Errorf("Unexpected HELLO 2 reply: %q", hello)
+	}
+	expect(t, c, ":1\r\n", "BF.EXISTS", "bf", "foo")
+}
+
+func TestServer_ProtocolErrors(t *testing.T) {
+	c := newTestServer(t)
+
+	expect(t, c, "-ERR unknown command 'NOPE'\r\n", "NOPE")
+	expect(t, c, "-ERR wrong number of arguments for 'bf.add' command\r\n", "BF.ADD", "bf")
+
+	// Inline commands are accepted.
+	io.WriteString(c.conn, "PING\r\n")
+	if got := c.reply(t); got != "+PONG\r\n" {
+		t.Errorf("Expected inline PING to succeed, got %q", got)
+	}
+
+	io.WriteString(c.conn, "*1\r\n!bogus\r\n")
+	if got := c.reply(t); !strings.HasPrefix(got, "-ERR Protocol error") {
+		t.Errorf("Expected protocol error, got %q", got)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("Expected connection to be closed after a protocol error, got %v", err)
+	}
+}
+
+func TestServer_Pipelining(t *testing.T) {
+	c := newTestServer(t)
+
+	for i := range 100 {
+		c.send(t, "BF.ADD", "bf", fmt.Sprintf("item-%d", i))
+	}
+	for range 100 {
+		if got := c.reply(t); got != ":1\r\n" && got != ":0\r\n" {
+			t.Fatalf("Unexpected pipelined reply %q", got)
+		}
+	}
+	expect(t, c, "+OK\r\n", "QUIT")
+}
@@ -0,0 +1,192 @@
+package bitbloom
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultTighteningRatio is the factor by which the false positive rate of
+// each new layer of a ScalableFilter is reduced.
+const DefaultTighteningRatio = 0.5
+
+// ScalableFilter is a Bloom filter that grows as items are added, following
+// Almeida et al., "Scalable Bloom Filters" (2007).
+//
+// Each layer is a partitioned filter, as in the paper, so that its fill and
+// false positive rate can be tracked exactly. It starts with a single layer
+// sized for the initial capacity. Once a layer
+// holds its capacity, a new layer is appended with its capacity multiplied by
+// the expansion factor and its false positive rate multiplied by the
+// tightening ratio. The first layer is created with p * (1 - ratio) so the
+// compound false positive rate stays below p however many layers are added.
+//
+// It is safe for concurrent use by multiple goroutines.
+type ScalableFilter struct {
+	mutex     sync.RWMutex
+	layers    []*BloomFilter
+	caps      []uint64
+	p         float64
+	ratio     float64
+	expansion uint64
+
+	adds  atomic.Uint64
+	tests atomic.Uint64
+}
+
+// NewScalable creates a ScalableFilter whose first layer holds `capacity`
+// items and whose compound false positive rate stays below `p`. Each new
+// layer holds `expansion` times as many items as the previous one.
+//
+// It returns an error if capacity is zero, p is not in the range (0,1), or
+// expansion is less than 1.
+func NewScalable(capacity uint64, p float64, expansion uint64) (*ScalableFilter, error) {
+	if capacity == 0 {
+		return nil, fmt.Errorf("capacity must be positive")
+	}
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+	if expansion < 1 {
+		return nil, fmt.Errorf("expansion must be at least 1")
+	}
+
+	sf := &ScalableFilter{
+		p:         p,
+		ratio:     DefaultTighteningRatio,
+		expansion: expansion,
+	}
+	sf.grow(capacity)
+	return sf, nil
+}
+
+// grow appends a layer holding capacity items. It must be called with the
+// write lock held.
+func (sf *ScalableFilter) grow(capacity uint64) {
+	p := sf.p * (1 - sf.ratio) * math.Pow(sf.ratio, float64(len(sf.layers)))
+	m := OptimalM(capacity, p)
+	sf.layers = append(sf.layers, NewPartitionedWithParams(m, OptimalK(m, capacity)))
+	sf.caps = append(sf.caps, capacity)
+}
+
+// Add inserts an item into the filter, adding a layer first if the current
+// one is full.
+func (sf *ScalableFilter) Add(item []byte) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	sf.add(item)
+}
+
+func (sf *ScalableFilter) add(item []byte) {
+	last := len(sf.layers) - 1
+	if sf.layers[last].count >= sf.caps[last] {
+		sf.grow(sf.caps[last] * sf.expansion)
+		last++
+	}
+	sf.layers[last].Add(item)
+	sf.adds.Add(1)
+}
+
+// Test checks whether an item is possibly in any layer of the filter.
+func (sf *ScalableFilter) Test(item []byte) bool {
+	sf.mutex.RLock()
+	defer sf.mutex.RUnlock()
+
+	sf.tests.Add(1)
+	return sf.test(item)
+}
+
+func (sf *ScalableFilter) test(item []byte) bool {
+	// Newer layers are larger and hold most items, so check them first.
+	for i := len(sf.layers) - 1; i >= 0; i-- {
+		if sf.layers[i].Test(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAndAdd adds an item unless it is possibly present already, and reports
+// whether it was. Unlike Add, it does not consume capacity for items that
+// the filter already reports as present.
+func (sf *ScalableFilter) TestAndAdd(item []byte) bool {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	sf.tests.Add(1)
+	if sf.test(item) {
+		return true
+	}
+	sf.add(item)
+	return false
+}
+
+// Layers returns the number of layers in the filter.
+func (sf *ScalableFilter) Layers() int {
+	sf.mutex.RLock()
+	defer sf.mutex.RUnlock()
+
+	return len(sf.layers)
+}
+
+// Capacity returns the number of items the filter can hold before it adds
+// another layer.
+func (sf *ScalableFilter) Capacity() uint64 {
+	sf.mutex.RLock()
+	defer sf.mutex.RUnlock()
+
+	var total uint64
+	for _, c := range sf.caps {
+		total += c
+	}
+	return total
+}
+
+// Count returns the number of items added to the filter. Items for which
+// TestAndAdd reported a possible match are not counted.
+func (sf *ScalableFilter) Count() uint64 {
+	sf.mutex.RLock()
+	defer sf.mutex.RUnlock()
+
+	var total uint64
+	for _, layer := range sf.layers {
+		total += layer.count
+	}
+	return total
+}
+
+// Expansion returns the growth factor of layer capacities.
+func (sf *ScalableFilter) Expansion() uint64 {
+	return sf.expansion
+}
+
+// Stats returns statistics aggregated over all layers. M, SetBits, Count
+// and MemoryUsage are sums, K is the number of hash functions of the newest
+// layer, and FalsePositiveRate is the compound rate of all layers.
+func (sf *ScalableFilter) Stats() Stats {
+	sf.mutex.RLock()
+	defer sf.mutex.RUnlock()
+
+	var s Stats
+	var kn float64
+	notFalsePositive := 1.0
+	for _, layer := range sf.layers {
+		ls := layer.Stats()
+		s.M += ls.M
+		s.K = ls.K
+		s.Count += ls.Count
+		s.SetBits += ls.SetBits
+		s.EstimatedCount += ls.EstimatedCount
+		s.MemoryUsage += ls.MemoryUsage
+		kn += float64(ls.K) * float64(ls.Count)
+		notFalsePositive *= 1 - ls.FalsePositiveRate
+	}
+	s.EstimatedFillRatio = 1 - math.Exp(-kn/float64(s.M))
+	s.ActualFillRatio = float64(s.SetBits) / float64(s.M)
+	s.FalsePositiveRate = 1 - notFalsePositive
+	s.Adds = sf.adds.Load()
+	s.Tests = sf.tests.Load()
+	return s
+}
@@ -0,0 +1,116 @@
+package bitbloom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestScalableFilter_Grows(t *testing.T) {
+	sf, err := NewScalable(100, 0.01, 2)
+	if err != nil {
+		t.Fatalf("NewScalable failed: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		sf.Add([]byte(fmt.Sprintf("item-%d", i)))
+	}
+
+	// 100 + 200 + 400 + 800 >= 1000
+	if sf.Layers() != 4 {
+		t.Errorf("Expected 4 layers, got %d", sf.Layers())
+	}
+	if sf.Capacity() != 1500 {
+		t.Errorf("Expected capacity 1500, got %d", sf.Capacity())
+	}
+	for i := 0; i < 1000; i++ {
+		if !sf.Test([]byte(fmt.Sprintf("item-%d", i))) {
+			t.Fatalf("Expected item-%d to be present", i)
+		}
+	}
+}
+
+func TestScalableFilter_FalsePositiveRate(t *testing.T) {
+	sf, _ := NewScalable(100, 0.01, 2)
+	for i := 0; i < 5000; i++ {
+		sf.Add([]byte(fmt.Sprintf("item-%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if sf.Test([]byte(fmt.Sprintf("other-%d", i))) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.02 {
+		t.Errorf("False positive rate %f exceeds expected bound", rate)
+	}
+
+	s := sf.Stats()
+	if s.Count != 5000 || s.Adds != 5000 {
+		t.Errorf("Unexpected counts in stats: %+v", s)
+	}
+	if s.FalsePositiveRate <= 0 || s.FalsePositiveRate >= 0.02 {
+		t.Errorf("Unexpected compound false positive rate %f", s.FalsePositiveRate)
+	}
+}
+
+func TestScalableFilter_TestAndAdd(t *testing.T) {
+	sf, _ := NewScalable(10, 0.01, 1)
+	if sf.TestAndAdd([]byte("foo")) {
+		t.Error("Expected foo to be absent before the first add")
+	}
+	if !sf.TestAndAdd([]byte("foo")) {
+		t.Error("Expected foo to be present after adding it")
+	}
+	if s := sf.Stats(); s.Count != 1 {
+		t.Errorf("Duplicate TestAndAdd should not consume capacity, count is %d", s.Count)
+	}
+}
+
+func TestScalableFilter_InvalidParams(t *testing.T) {
+	if _, err := NewScalable(0, 0.01, 2); err == nil {
+		t.Error("Expected error for zero capacity")
+	}
+	if _, err := NewScalable(100, 1, 2); err == nil {
+		t.Error("Expected error for invalid false positive rate")
+	}
+	if _, err := NewScalable(100, 0.01, 0); err == nil {
+		t.Error("Expected error for zero expansion")
+	}
+}
+
+func TestScalableFilter_Concurrent(t *testing.T) {
+	sf, _ := NewScalable(50, 0.01, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 500; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			sf.Add([]byte(fmt.Sprintf("item-%d", i)))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			sf.Test([]byte(fmt.Sprintf("item-%d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 500; i++ {
+		if !sf.Test([]byte(fmt.Sprintf("item-%d", i))) {
+			t.Fatalf("Expected item-%d to be present", i)
+		}
+	}
+}
+
+func TestScalableFilter_PartitionedLayers(t *testing.T) {
+	sf, _ := NewScalable(100, 0.01, 2)
+	for i := range 300 {
+		sf.Add([]byte(fmt.Sprintf("item-%d", i)))
+	}
+	for i, layer := range sf.layers {
+		if layer.probe&probePartitioned == 0 || layer.m%layer.k != 0 {
+			t.Errorf("Expected layer %d to be partitioned, m=%d k=%d", i, layer.m, layer.k)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+/*
+Package server hosts named Bloom filters behind a JSON REST API.
+
+Routes:
+
+	GET    /filters                   list filter names
+	PUT    /filters/{name}            create a filter from {"n","p"} or {"m","k"}
+	GET    /filters/{name}            filter statistics
+	DELETE /filters/{name}            delete a filter and its snapshot
+	POST   /filters/{name}/add        add {"keys": [...]}
+	POST   /filters/{name}/test       test {"keys": [...]}
+	GET    /filters/{name}/snapshot   download the filter in MarshalBinary format
+	PUT    /filters/{name}/snapshot   create or replace a filter from MarshalBinary data
+	GET    /metrics                   statistics in the Prometheus text format
+
+When a data directory is configured, every filter is persisted there as
+<name>.bloom on shutdown and reloaded by New.
+*/
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/umang-sinha/bitbloom"
+	"github.com/umang-sinha/bitbloom/internal/atomicfile"
+	"github.com/umang-sinha/bitbloom/metrics"
+)
+
+// snapshotExt is the file extension of persisted filters.
+const snapshotExt = ".bloom"
+
+// DefaultMaxBodyBytes limits request bodies, including uploaded snapshots,
+// when Options.MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 1 << 30
+
+// DefaultMaxBits limits the size of a filter created or uploaded, 1 GiB of
+// bits, when Options.MaxBits is zero. It matches DefaultMaxBodyBytes, so
+// any snapshot that fits in a request body can be uploaded.
+const DefaultMaxBits = 8 * DefaultMaxBodyBytes
+
+// DefaultShutdownTimeout bounds how long Serve waits for in-flight requests
+// when Options.ShutdownTimeout is zero.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Options configures a Server.
+type Options struct {
+	// DataDir is where filters are persisted on shutdown and loaded from on
+	// startup. If empty, filters only live in memory.
+	DataDir string
+	// MaxBodyBytes limits the size of request bodies.
+	MaxBodyBytes int64
+	// MaxBits limits the number of bits of a filter created or uploaded.
+	MaxBits uint64
+	// MaxK limits the number of hash functions of a filter created or
+	// uploaded. Zero means bitbloom.DefaultMaxK.
+	MaxK uint64
+	// ShutdownTimeout bounds how long Serve waits for in-flight requests.
+	ShutdownTimeout time.Duration
+}
+
+// Server hosts named Bloom filters. It implements http.Handler, so it can be
+// tested with httptest or mounted in an existing mux.
+type Server struct {
+	opts    Options
+	mux     *http.ServeMux
+	metrics *metrics.Collector
+
+	mu      sync.RWMutex
+	filters map[string]*bitbloom.BloomFilter
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]{0,127}$`)
+
+// New creates a Server. If opts.DataDir is set, the directory is created if
+// needed and any snapshots in it are loaded.
+func New(opts Options) (*Server, error) {
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if opts.MaxBits == 0 {
+		opts.MaxBits = DefaultMaxBits
+	}
+	if opts.MaxK == 0 {
+		opts.MaxK = bitbloom.DefaultMaxK
+	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	s := &Server{
+		opts:    opts,
+		mux:     http.NewServeMux(),
+		metrics: metrics.NewCollector(),
+		filters: make(map[string]*bitbloom.BloomFilter),
+	}
+	s.routes()
+
+	if opts.DataDir != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /filters", s.handleList)
+	s.mux.HandleFunc("PUT /filters/{name}", s.handleCreate)
+	s.mux.HandleFunc("GET /filters/{name}", s.handleStats)
+	s.mux.HandleFunc("DELETE /filters/{name}", s.handleDelete)
+	s.mux.HandleFunc("POST /filters/{name}/add", s.handleAdd)
+	s.mux.HandleFunc("POST /filters/{name}/test", s.handleTest)
+	s.mux.HandleFunc("GET /filters/{name}/snapshot", s.handleDownload)
+	s.mux.HandleFunc("PUT /filters/{name}/snapshot", s.handleUpload)
+	s.mux.Handle("GET /metrics", s.metrics)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve accepts connections on ln until ctx is cancelled. It then stops
+// accepting new requests, waits for in-flight ones to finish and persists
+// every filter to the data directory.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	hs := &http.Server{Handler: s}
+
+	errc := make(chan error, 1)
+	go func() { errc <- hs.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+
+	err := hs.Shutdown(shutdownCtx)
+	if saveErr := s.Save(); saveErr != nil {
+		err = errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// Filter returns the filter registered under name.
+func (s *Server) Filter(name string) (*bitbloom.BloomFilter, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bf, ok := s.filters[name]
+	return bf, ok
+}
+
+// Put registers bf under name, replacing any existing filter.
+func (s *Server) Put(name string, bf *bitbloom.BloomFilter) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid filter name %q", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filters[name] = bf
+	s.metrics.Register(name, bf)
+	return nil
+}
+
+// Save persists every filter to the data directory. It is a no-op if no
+// data directory is configured.
+func (s *Server) Save() error {
+	if s.opts.DataDir == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for name, bf := range s.filters {
+		data, err := bf.MarshalBinary()
+		if err == nil {
+			err = atomicfile.WriteFile(s.snapshotPath(name), data, 0o600)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("saving filter %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) load() error {
+	if err := os.MkdirAll(s.opts.DataDir, 0o755); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.opts.DataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), snapshotExt)
+		if !ok || entry.IsDir() || !validName.MatchString(name) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.opts.DataDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		bf, err := bitbloom.UnmarshalBinary(data)
+		if err != nil {
+			return fmt.Errorf("loading filter %q: %w", name, err)
+		}
+		s.filters[name] = bf
+		s.metrics.Register(name, bf)
+	}
+	return nil
+}
+
+func (s *Server) snapshotPath(name string) string {
+	return filepath.Join(s.opts.DataDir, name+snapshotExt)
+}
+
+type createRequest struct {
+	N uint64  `json:"n"`
+	P float64 `json:"p"`
+	M uint64  `json:"m"`
+	K uint64  `json:"k"`
+}
+
+type keysRequest struct {
+	Keys []string `json:"keys"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return false
+	}
+	return true
+}
+
+// lookup returns the filter named in the request path, writing a 404
+// response if there is none.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*bitbloom.BloomFilter, bool) {
+	name := r.PathValue("name")
+	bf, ok := s.Filter(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "filter %q not found", name)
+	}
+	return bf, ok
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.filters))
+	for name := range s.filters {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+
+	slices.Sort(names)
+	writeJSON(w, http.StatusOK, map[string][]string{"filters": names})
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !validName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "invalid filter name %q", name)
+		return
+	}
+
+	var req createRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	m, k := req.M, req.K
+	switch {
+	case req.N > 0 && req.M == 0 && req.K == 0:
+		if req.P <= 0 || req.P >= 1 {
+			writeError(w, http.StatusBadRequest, "false positive rate must be 0 < p < 1")
+			return
+		}
+		m = bitbloom.OptimalM(req.N, req.P)
+		k = bitbloom.OptimalK(m, req.N)
+	case req.N == 0 && req.M > 0 && req.K > 0:
+	default:
+		writeError(w, http.StatusBadRequest, "specify either n and p, or m and k")
+		return
+	}
+	if err := s.checkLimits(m, k); err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	bf := bitbloom.NewWithParams(m, k)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.filters[name]; exists {
+		writeError(w, http.StatusConflict, "filter %q already exists", name)
+		return
+	}
+	s.filters[name] = bf
+	s.metrics.Register(name, bf)
+	writeJSON(w, http.StatusCreated, bf.Stats())
+}
+
+// checkLimits returns a *bitbloom.LimitError if a filter of m bits and k
+// hash functions exceeds the server's limits.
+func (s *Server) checkLimits(m, k uint64) error {
+	if m > s.opts.MaxBits {
+		return &bitbloom.LimitError{Field: "m", Value: m, Limit: s.opts.MaxBits}
+	}
+	if k > s.opts.MaxK {
+		return &bitbloom.LimitError{Field: "k", Value: k, Limit: s.opts.MaxK}
+	}
+	return nil
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	bf, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, bf.Stats())
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.filters[name]; !ok {
+		writeError(w, http.StatusNotFound, "filter %q not found", name)
+		return
+	}
+	if s.opts.DataDir != "" {
+		if err := os.Remove(s.snapshotPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusInternalServerError, "removing snapshot: %v", err)
+			return
+		}
+	}
+	delete(s.filters, name)
+	s.metrics.Unregister(name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
+	bf, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	var req keysRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	for _, key := range req.Keys {
+		bf.Add([]byte(key))
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"added": len(req.Keys)})
+}
+
+func (s *Server) handleTest(w http.ResponseWriter, r *http.Request) {
+	bf, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	var req keysRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	results := make([]bool, len(req.Keys))
+	for i, key := range req.Keys {
+		results[i] = bf.Test([]byte(key))
+	}
+	writeJSON(w, http.StatusOK, map[string][]bool{"results": results})
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	bf, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := bf.MarshalBinary()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !validName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "invalid filter name %q", name)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "reading snapshot: %v", err)
+		return
+	}
+	opts := bitbloom.UnmarshalOptions{MaxBits: s.opts.MaxBits, MaxK: s.opts.MaxK, Strict: true}
+	bf, err := opts.Unmarshal(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid snapshot: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	_, existed := s.filters[name]
+	s.filters[name] = bf
+	s.metrics.Register(name, bf)
+	s.mu.Unlock()
+
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, bf.Stats())
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom"
+)
+
+func newTestServer(t *testing.T, dataDir string) (*Server, *httptest.Server) {
+	t.Helper()
+	s, err := New(Options{DataDir: dataDir})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func do(t *testing.T, method, url, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer_CreateAddTest(t *testing.T) {
+	_, ts := newTestServer(t, "")
+
+	if code := do(t, "PUT", ts.URL+"/filters/users", `{"n":1000,"p":0.01}`, nil); code != http.StatusCreated {
+		t.Fatalf("Expected 201 on create, got %d", code)
+	}
+	if code := do(t, "PUT", ts.URL+"/filters/users", `{"m":1024,"k":3}`, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 on duplicate create, got %d", code)
+	}
+
+	var added map[string]int
+	if code := do(t, "POST", ts.URL+"/filters/users/add", `{"keys":["alice","bob"]}`, &added); code != http.StatusOK {
+		t.Fatalf("Expected 200 on add, got %d", code)
+	}
+	if added["added"] != 2 {
+		t.Errorf("Expected 2 keys added, got %v", added)
+	}
+
+	var tested struct{ Results []bool }
+	do(t, "POST", ts.URL+"/filters/users/test", `{"keys":["alice","bob","mallory"]}`, &tested)
+	if len(tested.Results) != 3 || !tested.Results[0] || !tested.Results[1] || tested.Results[2] {
+		t.Errorf("Unexpected test results: %v", tested.Results)
+	}
+
+	var stats bitbloom.Stats
+	if code := do(t, "GET", ts.URL+"/filters/users", "", &stats); code != http.StatusOK {
+		t.Fatalf("Expected 200 on stats, got %d", code)
+	}
+	if stats.Count != 2 || stats.Adds != 2 || stats.Tests != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	var list struct{ Filters []string }
+	do(t, "GET", ts.URL+"/filters", "", &list)
+	if len(list.Filters) != 1 || list.Filters[0] != "users" {
+		t.Errorf("Unexpected filter list: %v", list.Filters)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	_, ts := newTestServer(t, "")
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/filters/missing", "", http.StatusNotFound},
+		{"POST", "/filters/missing/add", `{"keys":["a"]}`, http.StatusNotFound},
+		{"DELETE", "/filters/missing", "", http.StatusNotFound},
+		{"PUT", "/filters/.hidden", `{"m":64,"k":1}`, http.StatusBadRequest},
+		{"PUT", "/filters/bad", `{"n":10,"p":2}`, http.StatusBadRequest},
+		{"PUT", "/filters/bad", `{"n":10,"m":10}`, http.StatusBadRequest},
+		{"PUT", "/filters/bad", `{"bogus":1}`, http.StatusBadRequest},
+		{"PUT", "/filters/bad/snapshot", "garbage", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := do(t, tt.method, ts.URL+tt.path, tt.body, nil); code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, code)
+		}
+	}
+}
+
+func TestServer_Limits(t *testing.T) {
+	s, err := New(Options{MaxBits: 1 << 16, MaxK: 8})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	for _, body := range []string{
+		`{"m":4611686018427387904,"k":3}`,
+		`{"m":1024,"k":9}`,
+		`{"n":1000000,"p":0.01}`,
+	} {
+		var resp struct{ Error string }
+		if code := do(t, "PUT", ts.URL+"/filters/big", body, &resp); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, code)
+		}
+		if !strings.Contains(resp.Error, "exceeds limit") {
+			t.Errorf("%s: expected a limit error, got %q", body, resp.Error)
+		}
+	}
+	if code := do(t, "PUT", ts.URL+"/filters/ok", `{"m":65536,"k":8}`, nil); code != http.StatusCreated {
+		t.Errorf("Expected 201 at the limits, got %d", code)
+	}
+
+	big, _ := bitbloom.NewWithParams(1<<17, 3).MarshalBinary()
+	// A bit set beyond m is only rejected by strict decoding.
+	trailing, _ := bitbloom.NewWithParams(100, 3).MarshalBinary()
+	trailing[len(trailing)-1] = 0x80
+	for name, data := range map[string][]byte{"too large": big, "trailing bits": trailing} {
+		resp, err := http.DefaultClient.Do(mustRequest(t, "PUT", ts.URL+"/filters/up/snapshot", data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 on upload, got %d", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_SnapshotRoundTrip(t *testing.T) {
+	_, ts := newTestServer(t, "")
+
+	bf := bitbloom.NewWithParams(1024, 3)
+	bf.Add([]byte("foo"))
+	data, _ := bf.MarshalBinary()
+
+	resp, err := http.DefaultClient.Do(mustRequest(t, "PUT", ts.URL+"/filters/up/snapshot", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 on upload, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/filters/up/snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(downloaded, data) {
+		t.Error("Downloaded snapshot does not match the uploaded one")
+	}
+}
+
+func mustRequest(t *testing.T, method, url string, body []byte) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestServer_Delete(t *testing.T) {
+	dir := t.TempDir()
+	s, ts := newTestServer(t, dir)
+
+	do(t, "PUT", ts.URL+"/filters/tmp", `{"m":64,"k":1}`, nil)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if code := do(t, "DELETE", ts.URL+"/filters/tmp", "", nil); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 on delete, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tmp.bloom")); !os.IsNotExist(err) {
+		t.Errorf("Expected snapshot to be removed, got %v", err)
+	}
+	if _, ok := s.Filter("tmp"); ok {
+		t.Error("Deleted filter should not be registered")
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	_, ts := newTestServer(t, "")
+	do(t, "PUT", ts.URL+"/filters/users", `{"m":1024,"k":3}`, nil)
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `bitbloom_bits{filter="users"} 1024`) {
+		t.Errorf("Expected metrics for the filter, got:\n%s", body)
+	}
+}
+
+func TestServer_ShutdownPersistsAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(Options{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+
+	url := "http://" + ln.Addr().String()
+	do(t, "PUT", url+"/filters/users", `{"n":100,"p":0.01}`, nil)
+	do(t, "POST", url+"/filters/users/add", `{"keys":["alice"]}`, nil)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+
+	reloaded, err := New(Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("Reloading failed: %v", err)
+	}
+	bf, ok := reloaded.Filter("users")
+	if !ok {
+		t.Fatal("Expected filter to be reloaded from the data directory")
+	}
+	if !bf.Test([]byte("alice")) {
+		t.Error("Reloaded filter should contain the added key")
+	}
+}
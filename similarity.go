@@ -0,0 +1,167 @@
+package bitbloom
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"unsafe"
+
+	"github.com/umang-sinha/bitbloom/bitset"
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+// Similarity estimates the similarity of the sets of items held by two
+// compatible filters, from 0 for disjoint sets to 1 for equal ones.
+// Jaccard, Dice and Cosine are Similarity functions.
+type Similarity func(a, b *BloomFilter) (float64, error)
+
+// Jaccard estimates the Jaccard index |A ∩ B| / |A ∪ B| of the sets of
+// items held by a and b, which must have the same m, k, hashing scheme and
+// layout.
+//
+// Raw bit overlap overstates the similarity, since items of either set
+// also share bits by chance. Instead, the sizes of A, B and A ∪ B are
+// estimated from the bits set in a, b and their union with the
+// Swamidass-Baldi formula, and |A ∩ B| follows as |A| + |B| - |A ∪ B|.
+// The estimate is 0 if both filters are empty.
+func Jaccard(a, b *BloomFilter) (float64, error) {
+	return similarity(a, b, func(na, nb, ni, nu float64) float64 {
+		return ni / nu
+	})
+}
+
+// Dice estimates the Sørensen-Dice coefficient 2|A ∩ B| / (|A| + |B|) of
+// the sets of items held by a and b, corrected as Jaccard is.
+func Dice(a, b *BloomFilter) (float64, error) {
+	return similarity(a, b, func(na, nb, ni, nu float64) float64 {
+		return 2 * ni / (na + nb)
+	})
+}
+
+// Cosine estimates the cosine similarity |A ∩ B| / sqrt(|A| |B|) of the
+// sets of items held by a and b, corrected as Jaccard is. The estimate is
+// 0 if either filter is empty.
+func Cosine(a, b *BloomFilter) (float64, error) {
+	return similarity(a, b, func(na, nb, ni, nu float64) float64 {
+		return ni / math.Sqrt(na*nb)
+	})
+}
+
+// similarity estimates the sizes of the sets held by a and b, their
+// intersection and their union, and returns measure of them, or 0 if the
+// measure is undefined.
+func similarity(a, b *BloomFilter, measure func(na, nb, ni, nu float64) float64) (float64, error) {
+	// Lock in order of address, so that concurrent calls on the same pair
+	// cannot deadlock behind a waiting writer.
+	first, second := a, b
+	if uintptr(unsafe.Pointer(b)) < uintptr(unsafe.Pointer(a)) {
+		first, second = b, a
+	}
+	first.mutex.RLock()
+	defer first.mutex.RUnlock()
+	if second != first {
+		second.mutex.RLock()
+		defer second.mutex.RUnlock()
+	}
+
+	if err := a.compatible(b.m, b.k, b.scheme, b.probe); err != nil {
+		return 0, err
+	}
+	ba, err := a.bitSet()
+	if err != nil {
+		return 0, err
+	}
+	bb, err := b.bitSet()
+	if err != nil {
+		return 0, err
+	}
+
+	na, nb, nu := overlap(ba, bb, a.m, a.k, a.probe)
+	ni := max(na+nb-nu, 0)
+
+	s := measure(na, nb, ni, nu)
+	if math.IsNaN(s) {
+		return 0, nil
+	}
+	return s, nil
+}
+
+// bitSet returns the filter's bits. Filters held in memory share their
+// bitset; others are read into a new one. It is called with the read lock
+// held.
+func (bf *BloomFilter) bitSet() (*bitset.BitSet, error) {
+	if h, ok := bf.storage.(*storage.Heap); ok {
+		return h.BitSet(), nil
+	}
+	return bitset.NewFromData(bf.storage.Words(), bf.m)
+}
+
+// overlap estimates the number of distinct items in filters of m bits and
+// k hash functions with bits a and b, and in their union, without forming
+// the union. A partitioned filter is estimated per slice, as Stats does.
+func overlap(a, b *bitset.BitSet, m, k uint64, p probe) (na, nb, nu float64) {
+	if p&probePartitioned == 0 {
+		return estimateCardinality(m, k, uint64(a.Count())),
+			estimateCardinality(m, k, uint64(b.Count())),
+			estimateCardinality(m, k, uint64(a.UnionCount(b)))
+	}
+
+	wa, wb := a.Data(), b.Data()
+	size := m / k
+	for i := range k {
+		from, to := i*size, (i+1)*size
+		na += estimateCardinality(size, 1, countRange(wa, from, to))
+		nb += estimateCardinality(size, 1, countRange(wb, from, to))
+		nu += estimateCardinality(size, 1, unionCountRange(wa, wb, from, to))
+	}
+	return na / float64(k), nb / float64(k), nu / float64(k)
+}
+
+// compatible returns an error unless bf has the given parameters, hashing
+// scheme and probing. It is called with the read lock held.
+func (bf *BloomFilter) compatible(m, k uint64, scheme hasher.Scheme, probe probe) error {
+	if bf.probe != probe {
+		return fmt.Errorf("cannot compare filters with different probing: %#x vs %#x", uint8(bf.probe), uint8(probe))
+	}
+	if bf.m != m || bf.k != k {
+		return fmt.Errorf("cannot compare filters with different parameters: m=%d k=%d vs m=%d k=%d",
+			bf.m, bf.k, m, k)
+	}
+	if bf.scheme != scheme {
+		return fmt.Errorf("cannot compare filters with different hashing schemes: %s vs %s",
+			bf.scheme, scheme)
+	}
+	return nil
+}
+
+// Match is the similarity of one of the filters ranked by
+// RankBySimilarity to the query filter.
+type Match struct {
+	// Index is the position of the filter in the slice ranked.
+	Index int
+	// Score is the estimated similarity.
+	Score float64
+}
+
+// RankBySimilarity returns the similarity of each of filters to bf,
+// estimated by sim (Jaccard, Dice or Cosine), from the most similar
+// to the least. Filters of equal similarity keep their order.
+//
+// It returns an error, naming the filter, if any of filters is not
+// compatible with bf.
+func (bf *BloomFilter) RankBySimilarity(filters []*BloomFilter, sim Similarity) ([]Match, error) {
+	matches := make([]Match, len(filters))
+	for i, f := range filters {
+		score, err := sim(bf, f)
+		if err != nil {
+			return nil, fmt.Errorf("filter %d: %w", i, err)
+		}
+		matches[i] = Match{Index: i, Score: score}
+	}
+	slices.SortStableFunc(matches, func(x, y Match) int {
+		return cmp.Compare(y.Score, x.Score)
+	})
+	return matches, nil
+}
@@ -0,0 +1,148 @@
+package bitbloom
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// overlapping returns two filters built by newFilter holding 1000 items
+// each, of which shared are in both.
+func overlapping(t *testing.T, newFilter func(n uint64, p float64) (*BloomFilter, error), shared int) (*BloomFilter, *BloomFilter) {
+	t.Helper()
+	a, err := newFilter(5000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+	b, _ := newFilter(5000, 0.01)
+	for i := range 1000 {
+		a.Add(fmt.Appendf(nil, "item-%d", i))
+		b.Add(fmt.Appendf(nil, "item-%d", i+1000-shared))
+	}
+	return a, b
+}
+
+func TestSimilarity_Estimates(t *testing.T) {
+	for name, newFilter := range map[string]func(uint64, float64) (*BloomFilter, error){
+		"classic":     New,
+		"partitioned": NewPartitioned,
+	} {
+		// 500 shared of 1000 each: |A ∩ B| = 500, |A ∪ B| = 1500.
+		a, b := overlapping(t, newFilter, 500)
+		for _, tc := range []struct {
+			name string
+			sim  Similarity
+			want float64
+		}{
+			{"Jaccard", Jaccard, 1.0 / 3},
+			{"Dice", Dice, 0.5},
+			{"Cosine", Cosine, 0.5},
+		} {
+			got, err := tc.sim(a, b)
+			if err != nil {
+				t.Fatalf("%s %s failed: %v", name, tc.name, err)
+			}
+			if math.Abs(got-tc.want) > 0.03 {
+				t.Errorf("%s: expected %s near %g, got %g", name, tc.name, tc.want, got)
+			}
+			if same, _ := tc.sim(a, a); math.Abs(same-1) > 1e-9 {
+				t.Errorf("%s: expected %s of a filter with itself to be 1, got %g", name, tc.name, same)
+			}
+		}
+	}
+}
+
+func TestSimilarity_Corrected(t *testing.T) {
+	a, b := overlapping(t, New, 0)
+	got, _ := Jaccard(a, b)
+
+	// Disjoint sets still share bits by chance.
+	var both, either int
+	for i := range a.m {
+		x, y := a.storage.GetBit(i), b.storage.GetBit(i)
+		if x && y {
+			both++
+		}
+		if x || y {
+			either++
+		}
+	}
+	rawJaccard := float64(both) / float64(either)
+	if rawJaccard < 0.01 || got > 0.01 {
+		t.Errorf("Expected the raw bit overlap %g to overstate the corrected estimate %g for disjoint sets", rawJaccard, got)
+	}
+}
+
+func TestSimilarity_NoAllocations(t *testing.T) {
+	a, b := overlapping(t, New, 500)
+	allocs := testing.AllocsPerRun(10, func() {
+		Jaccard(a, b)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected Jaccard not to allocate, got %v allocations", allocs)
+	}
+}
+
+func TestSimilarity_Empty(t *testing.T) {
+	a, _ := New(1000, 0.01)
+	b, _ := New(1000, 0.01)
+	for _, sim := range []Similarity{Jaccard, Dice, Cosine} {
+		if s, err := sim(a, b); err != nil || s != 0 {
+			t.Errorf("Expected 0 for empty filters, got %g, %v", s, err)
+		}
+	}
+	b.Add([]byte("alice"))
+	if s, _ := Cosine(a, b); s != 0 {
+		t.Errorf("Expected cosine 0 against an empty filter, got %g", s)
+	}
+}
+
+func TestSimilarity_Incompatible(t *testing.T) {
+	a, _ := New(1000, 0.01)
+	for name, b := range map[string]*BloomFilter{
+		"size":        NewWithParams(a.m*2, a.k),
+		"hashes":      NewWithParams(a.m, a.k+1),
+		"partitioned": NewPartitionedWithParams(a.m-a.m%a.k, a.k),
+		"legacy":      newBloomFilter(a.m, a.k),
+	} {
+		if _, err := Jaccard(a, b); err == nil {
+			t.Errorf("Expected error comparing filters of different %s", name)
+		}
+	}
+}
+
+func TestBloomFilter_RankBySimilarity(t *testing.T) {
+	query, _ := New(5000, 0.01)
+	for i := range 1000 {
+		query.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+
+	// Candidate i shares 250*shares[i] of its 1000 items with the query.
+	shares := []int{1, 3, 0, 2}
+	candidates := make([]*BloomFilter, len(shares))
+	for i, shared := range shares {
+		candidates[i], _ = New(5000, 0.01)
+		for j := range 1000 {
+			candidates[i].Add(fmt.Appendf(nil, "item-%d", j+1000-250*shared))
+		}
+	}
+
+	matches, err := query.RankBySimilarity(candidates, Jaccard)
+	if err != nil {
+		t.Fatalf("RankBySimilarity failed: %v", err)
+	}
+	want := []int{1, 3, 0, 2}
+	for i, m := range matches {
+		if m.Index != want[i] {
+			t.Fatalf("Expected ranking %v, got %+v", want, matches)
+		}
+		if s, _ := Jaccard(query, candidates[m.Index]); s != m.Score {
+			t.Errorf("Expected score %g for filter %d, got %g", s, m.Index, m.Score)
+		}
+	}
+
+	other, _ := New(100, 0.01)
+	if _, err := query.RankBySimilarity(append(candidates, other), Dice); err == nil {
+		t.Error("Expected error ranking an incompatible filter")
+	}
+}
@@ -0,0 +1,56 @@
+package bitbloom
+
+import "github.com/umang-sinha/bitbloom/storage"
+
+const (
+	// sparseMinBits is the size from which New and the other constructors
+	// start a filter on sparse storage.
+	sparseMinBits = 1 << 20
+	// sparseMaxFill is the inverse of the fill ratio beyond which a sparse
+	// filter moves to dense storage. Sparse storage takes about 16 bits per
+	// bit set, so it stays smaller up to a fill of 1/16, but reads and
+	// writes are slower.
+	sparseMaxFill = 64
+)
+
+// useSparse starts a large, empty filter on sparse storage, so that it
+// takes memory in proportion to the bits set until it fills up. It is
+// called by constructors before the filter is shared.
+func (bf *BloomFilter) useSparse() {
+	if bf.m >= sparseMinBits {
+		bf.storage = storage.NewSparse(bf.m)
+	}
+}
+
+// Densify moves the filter to a plain bit array now, if it is held in the
+// compressed form that large filters start in; see Stats.Sparse. Call it
+// after construction to opt out of the compressed form where the speed of
+// Add and Test, or of operations that read the whole bit array such as
+// Merge and MarshalBinary, matters more than memory. A filter never moves
+// back to the compressed form.
+func (bf *BloomFilter) Densify() {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	bf.heap()
+}
+
+// densify moves a sparse filter to dense storage once more than
+// 1/sparseMaxFill of its bits are set. It is called with the write lock
+// held after bits are set.
+func (bf *BloomFilter) densify() {
+	if s, ok := bf.storage.(*storage.Sparse); ok && s.Count() > bf.m/sparseMaxFill {
+		bf.storage = s.Heap()
+	}
+}
+
+// heap returns the filter's storage if it is held in memory, moving a
+// sparse filter to dense storage first. It is called with the write lock
+// held.
+func (bf *BloomFilter) heap() (*storage.Heap, bool) {
+	if s, ok := bf.storage.(*storage.Sparse); ok {
+		bf.storage = s.Heap()
+	}
+	h, ok := bf.storage.(*storage.Heap)
+	return h, ok
+}
@@ -0,0 +1,121 @@
+package bitbloom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+func TestBloomFilter_SparseUntilFilled(t *testing.T) {
+	bf, _ := New(1_000_000, 0.01)
+	if s := bf.Stats(); !s.Sparse || s.MemoryUsage > 1024*1024/8 {
+		t.Fatalf("Expected an empty large filter to be sparse, got sparse=%v with %d bytes", s.Sparse, s.MemoryUsage)
+	}
+	small, _ := New(1000, 0.01)
+	if small.Stats().Sparse {
+		t.Error("Expected a small filter to be dense")
+	}
+
+	for i := range 1000 {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	s := bf.Stats()
+	if !s.Sparse || s.MemoryUsage >= int(bf.m/8)/10 {
+		t.Errorf("Expected 1000 items to stay sparse in well under %d bytes, got sparse=%v with %d bytes",
+			bf.m/8, s.Sparse, s.MemoryUsage)
+	}
+	if s.SetBits != bf.storage.Count() || bf.MemoryUsage() != s.MemoryUsage {
+		t.Error("Expected Stats to agree with the filter")
+	}
+
+	for i := 1000; i < 30000; i++ {
+		bf.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	if _, ok := bf.storage.(*storage.Heap); !ok || bf.Stats().Sparse {
+		t.Fatal("Expected the filter to move to dense storage once filled")
+	}
+	if bf.MemoryUsage() != int((bf.m+63)/64*8) {
+		t.Errorf("Expected a dense filter to use %d bytes, got %d", (bf.m+63)/64*8, bf.MemoryUsage())
+	}
+	for i := range 30000 {
+		if !bf.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d after moving to dense storage", i)
+		}
+	}
+}
+
+func TestBloomFilter_SparseOperations(t *testing.T) {
+	a, _ := New(1_000_000, 0.01)
+	b, _ := New(1_000_000, 0.01)
+	a.Add([]byte("alice"))
+	b.Add([]byte("bob"))
+
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	decoded, err := UnmarshalBinary(data)
+	if err != nil || !decoded.Equal(a) {
+		t.Fatalf("Expected a sparse filter to round trip, got %v", err)
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !a.Test([]byte("bob")) || !a.Stats().Sparse {
+		t.Error("Expected a sparse merge to keep both items and stay sparse")
+	}
+	if !a.Freeze().Test([]byte("alice")) || !a.Snapshot().Test([]byte("bob")) || !a.Clone().Equal(a) {
+		t.Error("Expected copies of a sparse filter to hold its items")
+	}
+
+	// Tracking changes needs the dense words.
+	replica := NewWithParams(a.m, a.k)
+	d, err := a.Delta(0)
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if a.Stats().Sparse {
+		t.Error("Expected Delta to move the filter to dense storage")
+	}
+	if err := replica.ApplyDelta(d); err != nil || !replica.Equal(a) {
+		t.Errorf("Expected a sparse replica to apply the delta, got %v", err)
+	}
+
+	a.Reset()
+	b.Reset()
+	if a.Test([]byte("alice")) || b.Test([]byte("bob")) {
+		t.Error("Expected Reset to clear sparse and dense filters")
+	}
+}
+
+func TestBloomFilter_FoldSparse(t *testing.T) {
+	bf := NewPowerOfTwoWithParams(1<<22, 7)
+	bf.Add([]byte("alice"))
+	if !bf.Stats().Sparse {
+		t.Fatal("Expected a large power-of-two filter to start sparse")
+	}
+	if _, err := bf.Fold(1 << 10); err != nil {
+		t.Fatalf("Fold failed: %v", err)
+	}
+	if bf.m != 1<<12 || !bf.Test([]byte("alice")) {
+		t.Errorf("Expected alice in a folded filter of 4096 bits, got m=%d", bf.m)
+	}
+}
+
+func TestBloomFilter_Densify(t *testing.T) {
+	bf, _ := New(1_000_000, 0.01)
+	bf.Add([]byte("alice"))
+	bf.Densify()
+	if _, ok := bf.storage.(*storage.Heap); !ok || bf.Stats().Sparse {
+		t.Fatal("Expected Densify to move the filter to dense storage")
+	}
+	if !bf.Test([]byte("alice")) {
+		t.Error("Expected Densify to keep the filter's items")
+	}
+	bf.Densify()
+	if bf.MemoryUsage() != int((bf.m+63)/64*8) {
+		t.Errorf("Expected a dense filter to use %d bytes, got %d", (bf.m+63)/64*8, bf.MemoryUsage())
+	}
+}
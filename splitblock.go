@@ -0,0 +1,217 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Filter is the interface shared by the Bloom filter variants in this
+// package.
+type Filter interface {
+	Add(item []byte)
+	Test(item []byte) bool
+}
+
+// Split block Bloom filter parameters from the Parquet specification.
+const (
+	sbbfBlockWords = 8                  // 32-bit words per block
+	sbbfBlockBytes = sbbfBlockWords * 4 // 256 bits
+	sbbfMinBytes   = sbbfBlockBytes     // one block
+	sbbfMaxBytes   = 128 * 1024 * 1024  // default maximum in parquet-mr and Arrow
+	sbbfBitsPerKey = sbbfBlockWords     // one bit per word
+	sbbfMaxNumBits = sbbfMaxBytes * 8   // upper bound for sizing
+	sbbfSaltShift  = 27                 // keeps the top 5 bits of a 32-bit product
+)
+
+var sbbfSalt = [sbbfBlockWords]uint32{
+	0x47b6137b, 0x44974d91, 0x8824ad5b, 0xa2b7289d,
+	0x705495c7, 0x2df1424b, 0x9efc4947, 0x5c6bfb31,
+}
+
+// SplitBlockFilter is a split block Bloom filter (SBBF) as specified for
+// Parquet column Bloom filters.
+//
+// The bitset is divided into 256-bit blocks of eight 32-bit words. An item
+// is hashed with xxHash64; the upper 32 bits of the hash select a block and
+// the lower 32 bits, multiplied by eight salt constants, set one bit in each
+// word of that block. Every lookup therefore touches a single cache line.
+//
+// It is safe for concurrent use by multiple goroutines.
+type SplitBlockFilter struct {
+	mutex  sync.RWMutex
+	blocks []uint32
+	count  uint64
+
+	adds  atomic.Uint64
+	tests atomic.Uint64
+}
+
+// SplitBlockBytes returns the number of bytes Parquet implementations
+// allocate for a split block filter holding `ndv` distinct values with a
+// false positive probability of `fpp`:
+//
+//	bits = -8 * ndv / ln(1 - fpp^(1/8))
+//
+// The result is rounded up to a power of two between 32 bytes and 128 MiB.
+func SplitBlockBytes(ndv uint64, fpp float64) uint64 {
+	numBits := -sbbfBitsPerKey * float64(ndv) / math.Log(1-math.Pow(fpp, 1.0/sbbfBitsPerKey))
+	if math.IsNaN(numBits) || numBits > sbbfMaxNumBits {
+		numBits = sbbfMaxNumBits
+	}
+
+	numBytes := uint64(math.Ceil(numBits / 8))
+	numBytes = min(max(numBytes, sbbfMinBytes), sbbfMaxBytes)
+	if numBytes&(numBytes-1) != 0 {
+		numBytes = 1 << bits.Len64(numBytes)
+	}
+	return numBytes
+}
+
+// NewSplitBlock creates a split block Bloom filter sized for `ndv` distinct
+// values with a false positive probability of `fpp`, as SplitBlockBytes.
+//
+// It returns an error if the probability is not in the range (0,1).
+func NewSplitBlock(ndv uint64, fpp float64) (*SplitBlockFilter, error) {
+	if fpp <= 0 || fpp >= 1 {
+		return nil, fmt.Errorf("false positive rate must be 0 < p < 1")
+	}
+	return NewSplitBlockWithBytes(SplitBlockBytes(ndv, fpp))
+}
+
+// NewSplitBlockWithBytes creates a split block Bloom filter with a bitset of
+// numBytes bytes, which must be a positive multiple of 32.
+func NewSplitBlockWithBytes(numBytes uint64) (*SplitBlockFilter, error) {
+	if numBytes == 0 || numBytes%sbbfBlockBytes != 0 {
+		return nil, fmt.Errorf("split block filter size must be a positive multiple of %d bytes, got %d",
+			sbbfBlockBytes, numBytes)
+	}
+	return &SplitBlockFilter{blocks: make([]uint32, numBytes/4)}, nil
+}
+
+// HashSplitBlock returns the hash used by split block filters for an item:
+// xxHash64 with a seed of zero. For Parquet columns the item is the plain
+// encoding of the value, e.g. the raw bytes of a BYTE_ARRAY or the
+// little-endian bytes of an INT64.
+func HashSplitBlock(item []byte) uint64 {
+	return xxhash.Sum64(item)
+}
+
+func (sb *SplitBlockFilter) block(h uint64) []uint32 {
+	numBlocks := uint64(len(sb.blocks) / sbbfBlockWords)
+	i := ((h >> 32) * numBlocks) >> 32
+	return sb.blocks[i*sbbfBlockWords : (i+1)*sbbfBlockWords]
+}
+
+func sbbfMask(i int, key uint32) uint32 {
+	return 1 << ((key * sbbfSalt[i]) >> sbbfSaltShift)
+}
+
+// Add inserts an item into the filter.
+func (sb *SplitBlockFilter) Add(item []byte) {
+	sb.AddHash(HashSplitBlock(item))
+}
+
+// AddHash inserts an item given its xxHash64 hash, as computed by
+// HashSplitBlock.
+func (sb *SplitBlockFilter) AddHash(h uint64) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	block := sb.block(h)
+	key := uint32(h)
+	for i := range block {
+		block[i] |= sbbfMask(i, key)
+	}
+
+	sb.count++
+	sb.adds.Add(1)
+}
+
+// Test checks whether an item is possibly in the filter.
+func (sb *SplitBlockFilter) Test(item []byte) bool {
+	return sb.TestHash(HashSplitBlock(item))
+}
+
+// TestHash checks whether an item is possibly in the filter given its
+// xxHash64 hash, as computed by HashSplitBlock.
+func (sb *SplitBlockFilter) TestHash(h uint64) bool {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	sb.tests.Add(1)
+	block := sb.block(h)
+	key := uint32(h)
+	for i := range block {
+		if block[i]&sbbfMask(i, key) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NumBytes returns the size of the filter's bitset in bytes.
+func (sb *SplitBlockFilter) NumBytes() int {
+	return len(sb.blocks) * 4
+}
+
+// Stats returns a consistent snapshot of the filter's statistics. K is the
+// eight bits set per item, and the false positive rate is approximated
+// as for a classic filter with the same fill ratio.
+func (sb *SplitBlockFilter) Stats() Stats {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	var setBits uint64
+	for _, w := range sb.blocks {
+		setBits += uint64(bits.OnesCount32(w))
+	}
+	m := uint64(len(sb.blocks)) * 32
+	fillRatio := float64(setBits) / float64(m)
+
+	return Stats{
+		M:                  m,
+		K:                  sbbfBlockWords,
+		Count:              sb.count,
+		SetBits:            setBits,
+		EstimatedCount:     estimateCardinality(m, sbbfBlockWords, setBits),
+		EstimatedFillRatio: 1 - math.Exp(-float64(sbbfBlockWords*sb.count)/float64(m)),
+		ActualFillRatio:    fillRatio,
+		FalsePositiveRate:  math.Pow(fillRatio, sbbfBlockWords),
+		MemoryUsage:        len(sb.blocks) * 4,
+		Adds:               sb.adds.Load(),
+		Tests:              sb.tests.Load(),
+	}
+}
+
+// Bitset returns the filter's bitset as it is stored in a Parquet file:
+// the blocks' 32-bit words in little-endian order.
+func (sb *SplitBlockFilter) Bitset() []byte {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	buf := make([]byte, len(sb.blocks)*4)
+	for i, w := range sb.blocks {
+		binary.LittleEndian.PutUint32(buf[i*4:], w)
+	}
+	return buf
+}
+
+// SplitBlockFromBitset creates a split block Bloom filter from a bitset in
+// the Parquet layout, as returned by Bitset. The length must be a positive
+// multiple of 32 bytes. The data is copied.
+func SplitBlockFromBitset(data []byte) (*SplitBlockFilter, error) {
+	sb, err := NewSplitBlockWithBytes(uint64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for i := range sb.blocks {
+		sb.blocks[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return sb, nil
+}
@@ -0,0 +1,125 @@
+package bitbloom
+
+import (
+	"fmt"
+	"math/bits"
+	"testing"
+)
+
+func TestSplitBlockBytes(t *testing.T) {
+	tests := []struct {
+		ndv  uint64
+		fpp  float64
+		want uint64
+	}{
+		{0, 0.01, 32},
+		{1, 0.5, 32},
+		{1000, 0.01, 2048},
+		{1000000, 0.01, 2 * 1024 * 1024},
+		{1 << 40, 0.01, 128 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		if got := SplitBlockBytes(tt.ndv, tt.fpp); got != tt.want {
+			t.Errorf("SplitBlockBytes(%d, %v) = %d, want %d", tt.ndv, tt.fpp, got, tt.want)
+		}
+	}
+}
+
+func TestNewSplitBlock_Invalid(t *testing.T) {
+	if _, err := NewSplitBlock(100, 0); err == nil {
+		t.Error("Expected error for invalid false positive rate")
+	}
+	for _, n := range []uint64{0, 31, 48} {
+		if _, err := NewSplitBlockWithBytes(n); err == nil {
+			t.Errorf("Expected error for %d bytes", n)
+		}
+	}
+}
+
+func TestHashSplitBlock(t *testing.T) {
+	// xxHash64 of the empty input with seed 0.
+	if got := HashSplitBlock(nil); got != 0xef46db3751d8e999 {
+		t.Errorf("HashSplitBlock(nil) = %#x, want 0xef46db3751d8e999", got)
+	}
+}
+
+func TestSplitBlock_AddHashSetsOneBitPerWord(t *testing.T) {
+	sb, err := NewSplitBlockWithBytes(4 * sbbfBlockBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The upper half selects block (0xc0000000 * 4) >> 32 = 3.
+	h := uint64(0xc0000000)<<32 | 0x12345678
+	sb.AddHash(h)
+
+	for i, w := range sb.blocks {
+		block := i / sbbfBlockWords
+		if block != 3 {
+			if w != 0 {
+				t.Errorf("word %d in block %d is set", i, block)
+			}
+			continue
+		}
+		want := uint32(1) << ((uint32(0x12345678) * sbbfSalt[i%sbbfBlockWords]) >> 27)
+		if w != want || bits.OnesCount32(w) != 1 {
+			t.Errorf("word %d = %#x, want %#x", i, w, want)
+		}
+	}
+	if !sb.TestHash(h) {
+		t.Error("Expected added hash to be present")
+	}
+}
+
+func TestSplitBlock_AddTest(t *testing.T) {
+	sb, err := NewSplitBlock(1000, 0.01)
+	if err != nil {
+		t.Fatalf("NewSplitBlock failed: %v", err)
+	}
+
+	for i := range 1000 {
+		sb.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+	for i := range 1000 {
+		if !sb.Test(fmt.Appendf(nil, "item-%d", i)) {
+			t.Fatalf("False negative for item-%d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range 10000 {
+		if sb.Test(fmt.Appendf(nil, "other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.02 {
+		t.Errorf("False positive rate %v exceeds 0.02", rate)
+	}
+
+	stats := sb.Stats()
+	if stats.Count != 1000 || stats.M != 2048*8 || stats.K != 8 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestSplitBlock_Bitset(t *testing.T) {
+	sb, _ := NewSplitBlockWithBytes(64)
+	sb.Add([]byte("foo"))
+
+	other, err := SplitBlockFromBitset(sb.Bitset())
+	if err != nil {
+		t.Fatalf("SplitBlockFromBitset failed: %v", err)
+	}
+	if !other.Test([]byte("foo")) {
+		t.Error("Expected foo to be present after round trip")
+	}
+
+	sb.blocks[0] = 0x04030201
+	if got := sb.Bitset()[:4]; string(got) != "\x01\x02\x03\x04" {
+		t.Errorf("Expected little-endian words, got %x", got)
+	}
+}
+
+var _ Filter = (*SplitBlockFilter)(nil)
+var _ Filter = (*BloomFilter)(nil)
+var _ Filter = (*BIP37Filter)(nil)
@@ -0,0 +1,162 @@
+package bitbloom
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/umang-sinha/bitbloom/storage"
+)
+
+// Stats is a point-in-time snapshot of a Bloom filter's parameters,
+// fill state and usage counters.
+//
+// All fields are computed from a single pass under one lock acquisition,
+// so they are mutually consistent.
+type Stats struct {
+	// M is the size of the bit array.
+	M uint64 `json:"m"`
+	// K is the number of hash functions.
+	K uint64 `json:"k"`
+	// Count is the number of Add calls recorded in the filter, including
+	// duplicates. It survives serialization.
+	Count uint64 `json:"count"`
+	// SetBits is the number of bits currently set.
+	SetBits uint64 `json:"set_bits"`
+	// EstimatedCount estimates the number of distinct items inserted,
+	// derived from the number of set bits.
+	EstimatedCount float64 `json:"estimated_count"`
+	// EstimatedFillRatio is the theoretical fill ratio, see EstimatedFillRatio.
+	EstimatedFillRatio float64 `json:"estimated_fill_ratio"`
+	// ActualFillRatio is the fraction of bits set, see ActualFillRatio.
+	ActualFillRatio float64 `json:"actual_fill_ratio"`
+	// FalsePositiveRate is the current false positive estimate, see FalsePositiveRate.
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	// MemoryUsage is the memory held by the bit array in bytes, see
+	// MemoryUsage.
+	MemoryUsage int `json:"memory_usage"`
+	// Sparse reports whether the bit array is still held in the compressed
+	// form used for large filters with few bits set.
+	Sparse bool `json:"sparse,omitempty"`
+	// Adds and Tests count calls to Add and Test on this instance since it
+	// was created. Unlike Count they are not serialized.
+	Adds  uint64 `json:"adds"`
+	Tests uint64 `json:"tests"`
+	// SliceFillRatios is the fraction of bits set in each of the k slices
+	// of a partitioned filter, and nil for other filters.
+	SliceFillRatios []float64 `json:"slice_fill_ratios,omitempty"`
+}
+
+// Stats returns a consistent snapshot of the filter's statistics.
+//
+// It is cheaper than calling EstimatedFillRatio, ActualFillRatio,
+// FalsePositiveRate and MemoryUsage separately, as the bit array is only
+// counted once.
+func (bf *BloomFilter) Stats() Stats {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+
+	setBits := bf.storage.Count()
+	fillRatio := float64(setBits) / float64(bf.m)
+
+	s := Stats{
+		M:                  bf.m,
+		K:                  bf.k,
+		Count:              bf.count,
+		SetBits:            setBits,
+		EstimatedCount:     estimateCardinality(bf.m, bf.k, setBits),
+		EstimatedFillRatio: 1 - math.Exp(-float64(bf.k*bf.count)/float64(bf.m)),
+		ActualFillRatio:    fillRatio,
+		FalsePositiveRate:  math.Pow(fillRatio, float64(bf.k)),
+		MemoryUsage:        bf.memoryUsage(),
+		Adds:               bf.adds.Load(),
+		Tests:              bf.tests.Load(),
+	}
+	_, s.Sparse = bf.storage.(*storage.Sparse)
+	if bf.probe&probePartitioned != 0 {
+		s.partition(bf.storage.Words())
+	}
+	return s
+}
+
+// partition replaces the estimates made for the classic layout with exact
+// per-slice ones for a partitioned filter with the given words. Each slice
+// holds one bit of every item, so its fill gives the count directly and the
+// false positive rate is the product of the slice fills.
+func (s *Stats) partition(words []uint64) {
+	size := s.M / s.K
+	s.SliceFillRatios = make([]float64, s.K)
+	s.FalsePositiveRate = 1
+	s.EstimatedCount = 0
+	for i := range s.K {
+		x := countRange(words, i*size, (i+1)*size)
+		s.SliceFillRatios[i] = float64(x) / float64(size)
+		s.FalsePositiveRate *= s.SliceFillRatios[i]
+		s.EstimatedCount += estimateCardinality(size, 1, x) / float64(s.K)
+	}
+}
+
+// partitionedFPR returns the false positive rate of a partitioned filter
+// of m bits and k slices with the given words.
+func partitionedFPR(words []uint64, m, k uint64) float64 {
+	size := m / k
+	p := 1.0
+	for i := range k {
+		p *= float64(countRange(words, i*size, (i+1)*size)) / float64(size)
+	}
+	return p
+}
+
+// wordsFPR returns the false positive rate of a filter of m bits and k
+// hash functions with the given words and probing.
+func wordsFPR(words []uint64, m, k uint64, p probe) float64 {
+	if p&probePartitioned != 0 {
+		return partitionedFPR(words, m, k)
+	}
+	return math.Pow(float64(countRange(words, 0, m))/float64(m), float64(k))
+}
+
+// countRange returns the number of bits set in words from bit from up to,
+// but not including, bit to.
+func countRange(words []uint64, from, to uint64) uint64 {
+	var count uint64
+	for from < to {
+		w := words[from/64] >> (from % 64)
+		span := min(64-from%64, to-from)
+		if span < 64 {
+			w &= 1<<span - 1
+		}
+		count += uint64(bits.OnesCount64(w))
+		from += span
+	}
+	return count
+}
+
+// unionCountRange returns the number of bits set in a or b from bit from
+// up to, but not including, bit to.
+func unionCountRange(a, b []uint64, from, to uint64) uint64 {
+	var count uint64
+	for from < to {
+		w := (a[from/64] | b[from/64]) >> (from % 64)
+		span := min(64-from%64, to-from)
+		if span < 64 {
+			w &= 1<<span - 1
+		}
+		count += uint64(bits.OnesCount64(w))
+		from += span
+	}
+	return count
+}
+
+// estimateCardinality estimates the number of distinct items in a filter
+// with x of its m bits set, using the Swamidass-Baldi formula:
+//
+//	n* = -(m / k) * ln(1 - x / m)
+//
+// A saturated filter (x == m) has no finite estimate; it is reported as if
+// a single bit were still clear.
+func estimateCardinality(m, k, x uint64) float64 {
+	if x >= m {
+		x = m - 1
+	}
+	return -float64(m) / float64(k) * math.Log1p(-float64(x)/float64(m))
+}
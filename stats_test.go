@@ -0,0 +1,51 @@
+package bitbloom
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestBloomFilter_Stats(t *testing.T) {
+	bf := NewWithParams(10000, 4)
+	for i := 0; i < 500; i++ {
+		bf.Add([]byte(fmt.Sprintf("item-%d", i)))
+	}
+	bf.Test([]byte("item-1"))
+	bf.Test([]byte("missing"))
+
+	s := bf.Stats()
+	if s.M != 10000 || s.K != 4 || s.Count != 500 {
+		t.Errorf("Unexpected parameters in stats: %+v", s)
+	}
+	if s.Adds != 500 || s.Tests != 2 {
+		t.Errorf("Expected 500 adds and 2 tests, got %d and %d", s.Adds, s.Tests)
+	}
+	if s.ActualFillRatio != bf.ActualFillRatio() {
+		t.Errorf("ActualFillRatio mismatch: %v != %v", s.ActualFillRatio, bf.ActualFillRatio())
+	}
+	if s.EstimatedFillRatio != bf.EstimatedFillRatio() {
+		t.Errorf("EstimatedFillRatio mismatch: %v != %v", s.EstimatedFillRatio, bf.EstimatedFillRatio())
+	}
+	if s.FalsePositiveRate != bf.FalsePositiveRate() {
+		t.Errorf("FalsePositiveRate mismatch: %v != %v", s.FalsePositiveRate, bf.FalsePositiveRate())
+	}
+	if s.MemoryUsage != bf.MemoryUsage() {
+		t.Errorf("MemoryUsage mismatch: %d != %d", s.MemoryUsage, bf.MemoryUsage())
+	}
+	if math.Abs(s.EstimatedCount-500) > 25 {
+		t.Errorf("Expected estimated count close to 500, got %f", s.EstimatedCount)
+	}
+}
+
+func TestBloomFilter_StatsSaturated(t *testing.T) {
+	bf := NewWithParams(64, 8)
+	for i := 0; i < 1000; i++ {
+		bf.Add([]byte(fmt.Sprintf("item-%d", i)))
+	}
+
+	s := bf.Stats()
+	if math.IsInf(s.EstimatedCount, 0) || math.IsNaN(s.EstimatedCount) {
+		t.Errorf("Expected finite estimate for saturated filter, got %f", s.EstimatedCount)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"unsafe"
+)
+
+// littleEndian reports whether the host stores integers little-endian, in
+// which case a suitably aligned byte slice can be read as words in place.
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+func wordsLen(n uint64) uint64 {
+	return (n + 63) / 64
+}
+
+func getBit(data []byte, n, pos uint64) bool {
+	return pos < n && data[pos/8]&(1<<(pos%8)) != 0
+}
+
+func setBit(data []byte, n, pos uint64) {
+	if pos < n {
+		data[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+// countBytes returns the number of bits set in data.
+func countBytes(data []byte) uint64 {
+	var count uint64
+	for len(data) >= 8 {
+		count += uint64(bits.OnesCount64(binary.LittleEndian.Uint64(data)))
+		data = data[8:]
+	}
+	for _, b := range data {
+		count += uint64(bits.OnesCount8(b))
+	}
+	return count
+}
+
+// bytesAsWords returns data, whose length must be a multiple of 8, as
+// little-endian words. The result aliases data when the host is
+// little-endian and data is 8-byte aligned, and is a copy otherwise.
+func bytesAsWords(data []byte) []uint64 {
+	if len(data) == 0 {
+		return nil
+	}
+	if littleEndian && uintptr(unsafe.Pointer(&data[0]))%8 == 0 {
+		return unsafe.Slice((*uint64)(unsafe.Pointer(&data[0])), len(data)/8)
+	}
+
+	words := make([]uint64, len(data)/8)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+	return words
+}
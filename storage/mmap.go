@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// maxMapSize is the largest mapping a byte slice can address.
+const maxMapSize = math.MaxInt
+
+// Mmap stores bits in a file mapped into memory, so a filter can be larger
+// than the heap and is persisted by the operating system. Changes reach the
+// file when the kernel writes back the mapping, or on Sync and Close.
+type Mmap struct {
+	file *os.File
+	data []byte
+	n    uint64
+}
+
+// OpenMmap maps the file at path as a storage of n bits, creating it if it
+// does not exist. An existing file must hold exactly ceil(n/64) 64-bit
+// words; a new or empty one is extended to that size with all bits unset.
+func OpenMmap(path string, n uint64) (*Mmap, error) {
+	if n == 0 {
+		return nil, fmt.Errorf("storage: cannot map an empty bit array")
+	}
+	size := wordsLen(n) * 8
+	if size > uint64(maxMapSize) {
+		return nil, fmt.Errorf("storage: %d bytes is too large to map", size)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	if err := prepareFile(f, int64(size)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	data, err := mmap(f, int(size))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("storage: mapping %s: %w", path, err)
+	}
+	return &Mmap{file: f, data: data, n: n}, nil
+}
+
+// prepareFile extends an empty file to size bytes, or checks that an
+// existing one has that size.
+func prepareFile(f *os.File, size int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	switch info.Size() {
+	case size:
+		return nil
+	case 0:
+		if err := f.Truncate(size); err != nil {
+			return fmt.Errorf("storage: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("storage: %s has %d bytes, expected %d", f.Name(), info.Size(), size)
+}
+
+func (s *Mmap) SetBit(pos uint64) {
+	setBit(s.data, s.n, pos)
+}
+
+func (s *Mmap) GetBit(pos uint64) bool {
+	return getBit(s.data, s.n, pos)
+}
+
+func (s *Mmap) Count() uint64 {
+	return countBytes(s.data)
+}
+
+// Words returns the mapped words. On little-endian hosts they alias the
+// mapping, so writes to the slice modify the file; otherwise they are a
+// copy.
+func (s *Mmap) Words() []uint64 {
+	return bytesAsWords(s.data)
+}
+
+func (s *Mmap) Len() uint64 {
+	return s.n
+}
+
+func (s *Mmap) Clear() {
+	clear(s.data)
+}
+
+// Sync flushes changes to the file to stable storage.
+func (s *Mmap) Sync() error {
+	if err := msync(s.data); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	return nil
+}
+
+// Close flushes changes and unmaps the file. The storage must not be used
+// afterwards, and slices returned by Words become invalid.
+func (s *Mmap) Close() error {
+	err := s.Sync()
+	if uerr := munmap(s.data); err == nil && uerr != nil {
+		err = fmt.Errorf("storage: %w", uerr)
+	}
+	s.data = nil
+	if cerr := s.file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("storage: %w", cerr)
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd)
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("memory-mapped storage is not supported on this platform")
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(data []byte) error {
+	return errMmapUnsupported
+}
+
+func msync(data []byte) error {
+	return errMmapUnsupported
+}
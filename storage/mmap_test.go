@@ -0,0 +1,46 @@
+//go:build linux || darwin || freebsd
+
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	path := tempPath(t)
+	s, err := OpenMmap(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenMmap failed: %v", err)
+	}
+	exercise(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != 128 || data[0] != 0x81 || data[1] != 0x01 {
+		t.Errorf("Unexpected file contents %x", data[:2])
+	}
+
+	s, err = OpenMmap(path, 1000)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer s.Close()
+	if !s.GetBit(999) || s.Count() != 8 {
+		t.Error("Bits were not persisted")
+	}
+	checkClear(t, s)
+}
+
+func TestOpenMmap_SizeMismatch(t *testing.T) {
+	path := tempPath(t)
+	os.WriteFile(path, make([]byte, 64), 0o644)
+	if _, err := OpenMmap(path, 1000); err == nil {
+		t.Error("Expected error for a file of the wrong size")
+	}
+	if _, err := OpenMmap(tempPath(t), 0); err == nil {
+		t.Error("Expected error for an empty bit array")
+	}
+}
@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Defaults for PagedOptions.
+const (
+	DefaultPageSize   = 4096
+	DefaultCachePages = 1024
+)
+
+// PagedOptions configures a Paged storage.
+type PagedOptions struct {
+	// PageSize is the size of a page in bytes. It must be a multiple of 8
+	// and defaults to DefaultPageSize.
+	PageSize int
+
+	// CachePages is the number of pages kept in memory. It defaults to
+	// DefaultCachePages.
+	CachePages int
+}
+
+// Paged stores bits in a file divided into fixed-size pages, of which at
+// most a bounded number are cached in memory. The least recently used page
+// is evicted when the cache is full, and written back first if it was
+// modified.
+//
+// Reads and writes cannot report I/O errors, so the first one is recorded
+// and returned by Err, Sync and Close. After an error GetBit reports every
+// bit as set, so a filter using the storage errs towards false positives
+// rather than false negatives.
+//
+// It is safe for concurrent use by multiple goroutines.
+type Paged struct {
+	mutex    sync.Mutex
+	file     *os.File
+	n        uint64
+	pageSize uint64
+	numPages uint64
+	maxPages int
+	pages    map[uint64]*list.Element
+	lru      list.List // of *page, most recently used first
+	err      error
+}
+
+type page struct {
+	index uint64
+	data  []byte
+	dirty bool
+}
+
+// OpenPaged opens the file at path as a paged storage of n bits, creating
+// it if it does not exist. The file holds ceil(n/64) little-endian 64-bit
+// words padded with zeros to a whole number of pages. An existing file must
+// have exactly that size; a new or empty one is extended to it.
+func OpenPaged(path string, n uint64, opts PagedOptions) (*Paged, error) {
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	if opts.CachePages == 0 {
+		opts.CachePages = DefaultCachePages
+	}
+	if opts.PageSize < 0 || opts.PageSize%8 != 0 {
+		return nil, fmt.Errorf("storage: page size must be a positive multiple of 8, got %d", opts.PageSize)
+	}
+	if opts.CachePages < 0 {
+		return nil, fmt.Errorf("storage: cache size must be positive, got %d pages", opts.CachePages)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("storage: cannot page an empty bit array")
+	}
+
+	pageSize := uint64(opts.PageSize)
+	numPages := (wordsLen(n)*8 + pageSize - 1) / pageSize
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	if err := prepareFile(f, int64(numPages*pageSize)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Paged{
+		file:     f,
+		n:        n,
+		pageSize: pageSize,
+		numPages: numPages,
+		maxPages: opts.CachePages,
+		pages:    make(map[uint64]*list.Element),
+	}, nil
+}
+
+// page returns the page holding byte offset off, loading it if needed.
+// It returns nil if the page cannot be loaded. The caller holds the mutex.
+func (s *Paged) page(off uint64) *page {
+	index := off / s.pageSize
+	if e, ok := s.pages[index]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*page)
+	}
+
+	for s.lru.Len() >= s.maxPages {
+		s.evict(s.lru.Back())
+	}
+
+	p := &page{index: index, data: make([]byte, s.pageSize)}
+	if _, err := s.file.ReadAt(p.data, int64(index*s.pageSize)); err != nil {
+		s.setErr(fmt.Errorf("storage: reading page %d: %w", index, err))
+		return nil
+	}
+	s.pages[index] = s.lru.PushFront(p)
+	return p
+}
+
+// evict writes back the page if it is dirty and drops it from the cache.
+func (s *Paged) evict(e *list.Element) {
+	p := e.Value.(*page)
+	s.writeBack(p)
+	s.lru.Remove(e)
+	delete(s.pages, p.index)
+}
+
+func (s *Paged) writeBack(p *page) {
+	if !p.dirty {
+		return
+	}
+	if _, err := s.file.WriteAt(p.data, int64(p.index*s.pageSize)); err != nil {
+		s.setErr(fmt.Errorf("storage: writing page %d: %w", p.index, err))
+		return
+	}
+	p.dirty = false
+}
+
+func (s *Paged) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// SetBit sets the bit at pos, loading its page if it is not cached.
+func (s *Paged) SetBit(pos uint64) {
+	if pos >= s.n {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if p := s.page(pos / 8); p != nil {
+		off := pos/8 - p.index*s.pageSize
+		mask := byte(1) << (pos % 8)
+		if p.data[off]&mask == 0 {
+			p.data[off] |= mask
+			p.dirty = true
+		}
+	}
+}
+
+// GetBit reports whether the bit at pos is set, loading its page if it is
+// not cached.
+func (s *Paged) GetBit(pos uint64) bool {
+	if pos >= s.n {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return true
+	}
+	p := s.page(pos / 8)
+	if p == nil {
+		return true
+	}
+	return p.data[pos/8-p.index*s.pageSize]&(1<<(pos%8)) != 0
+}
+
+// forEachPage calls fn with the contents of every page in order, without
+// loading them into the cache. The caller holds the mutex.
+func (s *Paged) forEachPage(fn func(data []byte)) error {
+	buf := make([]byte, s.pageSize)
+	for index := range s.numPages {
+		if e, ok := s.pages[index]; ok {
+			fn(e.Value.(*page).data)
+			continue
+		}
+		if _, err := s.file.ReadAt(buf, int64(index*s.pageSize)); err != nil {
+			return fmt.Errorf("storage: reading page %d: %w", index, err)
+		}
+		fn(buf)
+	}
+	return nil
+}
+
+// Count returns the number of bits set, reading every page from the file
+// that is not cached. It returns Len if a page cannot be read.
+func (s *Paged) Count() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var count uint64
+	err := s.forEachPage(func(data []byte) {
+		count += countBytes(data)
+	})
+	if err != nil {
+		s.setErr(err)
+		return s.n
+	}
+	return count
+}
+
+// Words returns a copy of the storage's words, reading every page from the
+// file that is not cached. It returns nil if a page cannot be read.
+func (s *Paged) Words() []uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	buf := make([]byte, 0, s.numPages*s.pageSize)
+	err := s.forEachPage(func(data []byte) {
+		buf = append(buf, data...)
+	})
+	if err != nil {
+		s.setErr(err)
+		return nil
+	}
+	return bytesAsWords(buf[:wordsLen(s.n)*8])
+}
+
+func (s *Paged) Len() uint64 {
+	return s.n
+}
+
+// Clear unsets every bit, discarding the cached pages and zeroing the
+// file by truncating it.
+func (s *Paged) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return
+	}
+	clear(s.pages)
+	s.lru.Init()
+	err := s.file.Truncate(0)
+	if err == nil {
+		err = s.file.Truncate(int64(s.numPages * s.pageSize))
+	}
+	if err != nil {
+		s.setErr(fmt.Errorf("storage: %w", err))
+	}
+}
+
+// CachedPages returns the number of pages currently held in memory.
+func (s *Paged) CachedPages() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lru.Len()
+}
+
+// Err returns the first I/O error encountered, if any.
+func (s *Paged) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+// Sync writes every dirty page back to the file and flushes the file to
+// stable storage. It returns the first I/O error encountered by the
+// storage, including earlier ones.
+func (s *Paged) Sync() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.sync()
+}
+
+func (s *Paged) sync() error {
+	if s.file == nil {
+		return errPagedClosed
+	}
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		s.writeBack(e.Value.(*page))
+	}
+	if err := s.file.Sync(); err != nil {
+		s.setErr(fmt.Errorf("storage: %w", err))
+	}
+	return s.err
+}
+
+var errPagedClosed = errors.New("storage: paged storage is closed")
+
+// Close writes back dirty pages, flushes and closes the file. The storage
+// must not be used afterwards.
+func (s *Paged) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.sync()
+	if s.file != nil {
+		if cerr := s.file.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("storage: %w", cerr)
+		}
+		s.file = nil
+	}
+	s.pages = nil
+	s.lru.Init()
+	return err
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPaged(t *testing.T) {
+	path := tempPath(t)
+	s, err := OpenPaged(path, 1000, PagedOptions{PageSize: 16, CachePages: 2})
+	if err != nil {
+		t.Fatalf("OpenPaged failed: %v", err)
+	}
+	exercise(t, s)
+
+	if s.CachedPages() > 2 {
+		t.Errorf("Cache holds %d pages, limit is 2", s.CachedPages())
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != 128 || data[0] != 0x81 || data[1] != 0x01 {
+		t.Errorf("Unexpected file contents %x", data[:2])
+	}
+
+	s, err = OpenPaged(path, 1000, PagedOptions{PageSize: 16, CachePages: 2})
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer s.Close()
+	if !s.GetBit(999) || s.Count() != 8 {
+		t.Error("Bits were not persisted")
+	}
+	checkClear(t, s)
+}
+
+func TestPaged_EvictionWritesBack(t *testing.T) {
+	path := tempPath(t)
+	s, _ := OpenPaged(path, 64*8, PagedOptions{PageSize: 8, CachePages: 1})
+	defer s.Close()
+
+	s.SetBit(0)
+	s.SetBit(64) // evicts the first page
+
+	data, _ := os.ReadFile(path)
+	if data[0] != 1 {
+		t.Error("Evicted dirty page was not written back")
+	}
+	if data[8] != 0 {
+		t.Error("Cached page was written before eviction or Sync")
+	}
+
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if data[8] != 1 {
+		t.Error("Sync did not write back the dirty page")
+	}
+}
+
+func TestPaged_Errors(t *testing.T) {
+	if _, err := OpenPaged(tempPath(t), 100, PagedOptions{PageSize: 12}); err == nil {
+		t.Error("Expected error for a page size that is not a multiple of 8")
+	}
+
+	path := tempPath(t)
+	s, _ := OpenPaged(path, 1000, PagedOptions{PageSize: 16, CachePages: 1})
+	s.file.Close() // force I/O errors
+
+	if !s.GetBit(500) {
+		t.Error("Expected GetBit to report set bits after an I/O error")
+	}
+	if s.Err() == nil {
+		t.Error("Expected the I/O error to be recorded")
+	}
+	if err := s.Sync(); err == nil {
+		t.Error("Expected Sync to return the recorded error")
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import "github.com/umang-sinha/bitbloom/bitset"
+
+// Sparse stores bits in memory in a compressed form that takes about two
+// bytes per bit set, rather than one bit per bit of its length. It suits
+// large arrays with few bits set, and grows slower to update and read than
+// Heap as more bits are set. BloomFilter starts filters of 2^20 bits or
+// more on a Sparse storage and moves them to a Heap once they fill up, or
+// when BloomFilter.Densify is called.
+type Sparse struct {
+	bs *bitset.Sparse
+}
+
+// NewSparse returns a sparse storage of n bits, all unset.
+func NewSparse(n uint64) *Sparse {
+	return &Sparse{bs: bitset.NewSparse(n)}
+}
+
+func (s *Sparse) SetBit(pos uint64) {
+	s.bs.Set(pos)
+}
+
+func (s *Sparse) GetBit(pos uint64) bool {
+	return s.bs.Get(pos)
+}
+
+// Count returns the number of bits set without scanning them.
+func (s *Sparse) Count() uint64 {
+	return uint64(s.bs.Count())
+}
+
+// Words returns a dense copy of the storage's words.
+func (s *Sparse) Words() []uint64 {
+	return s.bs.Data()
+}
+
+func (s *Sparse) Len() uint64 {
+	return s.bs.Size()
+}
+
+func (s *Sparse) Clear() {
+	s.bs.Clear()
+}
+
+// MemoryUsage returns the approximate memory held by the storage in bytes.
+func (s *Sparse) MemoryUsage() int {
+	return s.bs.MemoryUsage()
+}
+
+// Heap returns an in-memory storage holding the same bits in dense form.
+func (s *Sparse) Heap() *Heap {
+	h, _ := NewHeapFromWords(s.bs.Data(), s.bs.Size())
+	return h
+}
@@ -0,0 +1,149 @@
+/*
+Package storage provides the bit arrays that back bitbloom filters.
+
+A Storage holds a fixed number of bits. Heap keeps them in memory, Sparse in
+memory in a compressed form for arrays with few bits set, Mmap in a
+memory-mapped file, Paged in a file accessed through a bounded page cache,
+and View reads them from a byte slice without copying. Any of them can back a
+filter:
+
+	s, err := storage.OpenMmap("users.bits", bitbloom.OptimalM(1e9, 0.001))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+
+	bf := bitbloom.NewWithStorage(s, 10)
+
+The file-backed storages store bit i in byte i/8 at position i%8, which is
+the layout of the little-endian words written by MarshalBinary.
+*/
+package storage
+
+import (
+	"fmt"
+
+	"github.com/umang-sinha/bitbloom/bitset"
+)
+
+// Storage is a fixed-size array of bits.
+//
+// Positions at or beyond Len are ignored by SetBit and reported as unset by
+// GetBit. Implementations must allow concurrent calls to GetBit, Count and
+// Words; callers serialize SetBit with respect to all other calls, as
+// BloomFilter does.
+type Storage interface {
+	// SetBit sets the bit at pos.
+	SetBit(pos uint64)
+
+	// GetBit reports whether the bit at pos is set.
+	GetBit(pos uint64) bool
+
+	// Count returns the number of bits set.
+	Count() uint64
+
+	// Words returns the bits as little-endian ordered 64-bit words: bit i
+	// is bit i%64 of word i/64. Storages that keep their words in memory
+	// return them without copying, and writes to the slice modify the
+	// storage; others return a copy.
+	Words() []uint64
+
+	// Len returns the number of bits.
+	Len() uint64
+
+	// Clear unsets every bit.
+	Clear()
+}
+
+// Heap stores bits in memory.
+type Heap struct {
+	bs *bitset.BitSet
+}
+
+// NewHeap returns an in-memory storage of n bits, all unset.
+func NewHeap(n uint64) *Heap {
+	return &Heap{bs: bitset.New(n)}
+}
+
+// NewHeapFromWords returns an in-memory storage of n bits using words,
+// which must hold exactly ceil(n/64) words. The slice is not copied.
+func NewHeapFromWords(words []uint64, n uint64) (*Heap, error) {
+	bs, err := bitset.NewFromData(words, n)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	return &Heap{bs: bs}, nil
+}
+
+func (h *Heap) SetBit(pos uint64) {
+	h.bs.Set(pos)
+}
+
+func (h *Heap) GetBit(pos uint64) bool {
+	return h.bs.Get(pos)
+}
+
+func (h *Heap) Count() uint64 {
+	return uint64(h.bs.Count())
+}
+
+// Words returns the storage's words without copying.
+func (h *Heap) Words() []uint64 {
+	return h.bs.Data()
+}
+
+// BitSet returns the bitset holding the storage's bits, for read-only use
+// of its set algebra without copying.
+func (h *Heap) BitSet() *bitset.BitSet {
+	return h.bs
+}
+
+func (h *Heap) Len() uint64 {
+	return h.bs.Size()
+}
+
+// Clear unsets every bit. If changes are tracked, versions up to the
+// current one can no longer be brought up to date by Changes; see Cleared.
+func (h *Heap) Clear() {
+	h.bs.Clear()
+}
+
+// Or sets the bits of mask in word i.
+func (h *Heap) Or(i int, mask uint64) {
+	h.bs.OrWord(i, mask)
+}
+
+// Track starts recording which words of the storage change, for Changes.
+// It costs one more word of memory per word of bits.
+func (h *Heap) Track() {
+	h.bs.Track()
+}
+
+// Version returns the last version closed by Changes, or 0 if none has
+// been.
+func (h *Heap) Version() uint64 {
+	return h.bs.Version()
+}
+
+// Cleared returns the last version closed before the storage was most
+// recently cleared, or 0. Changes cannot express cleared bits, so a
+// replica at a version from 1 to Cleared must be rebuilt from scratch.
+func (h *Heap) Cleared() uint64 {
+	return h.bs.Cleared()
+}
+
+// Share returns the storage's words for read-only use by a snapshot. The
+// next change to the storage copies the words first, so the returned slice
+// never changes. Writes through the slice returned by Words are not
+// changes in this sense and must not be made while it is shared.
+func (h *Heap) Share() []uint64 {
+	return h.bs.Share()
+}
+
+// Changes calls fn with the index and value of every word that changed
+// after version since, then closes the current version and returns it.
+// Words with bits set when Track was called count as changed in version 1.
+// Track must have been called.
+func (h *Heap) Changes(since uint64, fn func(i int, w uint64)) uint64 {
+	return h.bs.Changes(since, fn)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// exercise checks the behaviour common to every writable Storage.
+func exercise(t *testing.T, s Storage) {
+	t.Helper()
+
+	if s.Len() != 1000 {
+		t.Fatalf("Expected 1000 bits, got %d", s.Len())
+	}
+
+	positions := []uint64{0, 7, 8, 63, 64, 511, 512, 999}
+	for _, pos := range positions {
+		s.SetBit(pos)
+	}
+	s.SetBit(1000) // out of range, ignored
+
+	for _, pos := range positions {
+		if !s.GetBit(pos) {
+			t.Errorf("Bit %d should be set", pos)
+		}
+	}
+	for _, pos := range []uint64{1, 62, 998, 1000, 5000} {
+		if s.GetBit(pos) {
+			t.Errorf("Bit %d should not be set", pos)
+		}
+	}
+	if s.Count() != uint64(len(positions)) {
+		t.Errorf("Expected %d bits set, got %d", len(positions), s.Count())
+	}
+
+	words := s.Words()
+	if len(words) != 16 {
+		t.Fatalf("Expected 16 words, got %d", len(words))
+	}
+	if words[0] != 1|1<<7|1<<8|1<<63 || words[1] != 1 || words[15] != 1<<(999%64) {
+		t.Errorf("Unexpected words %x", words)
+	}
+}
+
+// checkClear checks that Clear unsets every bit of a storage.
+func checkClear(t *testing.T, s Storage) {
+	t.Helper()
+
+	s.Clear()
+	if s.Count() != 0 || s.GetBit(999) {
+		t.Errorf("Expected no bits set after Clear, got %d", s.Count())
+	}
+	s.SetBit(5)
+	if !s.GetBit(5) || s.Count() != 1 {
+		t.Error("Expected bits to be settable after Clear")
+	}
+}
+
+func TestHeap(t *testing.T) {
+	h := NewHeap(1000)
+	exercise(t, h)
+	checkClear(t, h)
+}
+
+func TestSparse(t *testing.T) {
+	s := NewSparse(1000)
+	exercise(t, s)
+
+	h := s.Heap()
+	s.SetBit(1)
+	if !h.GetBit(999) || h.GetBit(1) || h.Count() != 8 {
+		t.Error("Expected Heap to copy the bits set so far")
+	}
+	s.Words()[0] = 0
+	if !s.GetBit(0) {
+		t.Error("Expected Words to return a copy")
+	}
+	checkClear(t, s)
+}
+
+func TestNewHeapFromWords(t *testing.T) {
+	words := make([]uint64, 16)
+	words[1] = 1
+	h, err := NewHeapFromWords(words, 1000)
+	if err != nil {
+		t.Fatalf("NewHeapFromWords failed: %v", err)
+	}
+	if !h.GetBit(64) {
+		t.Error("Expected bit 64 to be set")
+	}
+
+	// Words aliases the storage.
+	h.Words()[0] = 1
+	if !h.GetBit(0) {
+		t.Error("Expected writes to Words to modify the storage")
+	}
+
+	if _, err := NewHeapFromWords(words[:15], 1000); err == nil {
+		t.Error("Expected error for wrong word count")
+	}
+}
+
+func tempPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "bits")
+}
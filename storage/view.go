@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// View is a read-only storage over a byte slice holding little-endian
+// words, such as the bitset of a filter serialized with MarshalBinary. It
+// does not copy the slice, which must not be modified while the view is in
+// use.
+type View struct {
+	data []byte
+	n    uint64
+}
+
+// NewView returns a read-only storage of n bits over data, which must hold
+// exactly ceil(n/64) little-endian 64-bit words.
+func NewView(data []byte, n uint64) (*View, error) {
+	if uint64(len(data)) != wordsLen(n)*8 {
+		return nil, fmt.Errorf("storage: view of %d bits needs %d bytes, got %d", n, wordsLen(n)*8, len(data))
+	}
+	return &View{data: data, n: n}, nil
+}
+
+// SetBit panics, as a View is read-only.
+func (v *View) SetBit(pos uint64) {
+	panic("storage: SetBit on read-only View")
+}
+
+// Clear panics, as a View is read-only.
+func (v *View) Clear() {
+	panic("storage: Clear on read-only View")
+}
+
+func (v *View) GetBit(pos uint64) bool {
+	return getBit(v.data, v.n, pos)
+}
+
+func (v *View) Count() uint64 {
+	return countBytes(v.data)
+}
+
+// Words returns the view's words. They alias the underlying slice if it is
+// 8-byte aligned on a little-endian host and must not be modified.
+func (v *View) Words() []uint64 {
+	return bytesAsWords(v.data)
+}
+
+func (v *View) Len() uint64 {
+	return v.n
+}
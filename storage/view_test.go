@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	h := NewHeap(100)
+	h.SetBit(3)
+	h.SetBit(70)
+
+	// Offset by one byte to exercise the unaligned path.
+	buf := make([]byte, 17)
+	data := buf[1:]
+	for i, w := range h.Words() {
+		for j := range 8 {
+			data[i*8+j] = byte(w >> (8 * j))
+		}
+	}
+
+	v, err := NewView(data, 100)
+	if err != nil {
+		t.Fatalf("NewView failed: %v", err)
+	}
+	if !v.GetBit(3) || !v.GetBit(70) || v.GetBit(4) || v.GetBit(200) {
+		t.Error("Unexpected bits in view")
+	}
+	if v.Count() != 2 || v.Len() != 100 {
+		t.Errorf("Expected 2 of 100 bits set, got %d of %d", v.Count(), v.Len())
+	}
+	if words := v.Words(); words[0] != 1<<3 || words[1] != 1<<6 {
+		t.Errorf("Unexpected words %x", words)
+	}
+
+	if _, err := NewView(data[:8], 100); err == nil {
+		t.Error("Expected error for short data")
+	}
+}
+
+func TestView_SetBitPanics(t *testing.T) {
+	v, _ := NewView(make([]byte, 8), 64)
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected SetBit to panic")
+		}
+	}()
+	v.SetBit(0)
+}
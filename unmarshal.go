@@ -0,0 +1,121 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/umang-sinha/bitbloom/internal/hasher"
+)
+
+// DefaultMaxK is the largest number of hash functions accepted when
+// UnmarshalOptions.MaxK is zero. It is far beyond any useful value, but
+// keeps a corrupt header from turning every Test into a near-endless loop.
+const DefaultMaxK = 4096
+
+// Errors returned when decoding a serialized filter. Returned errors wrap
+// one of them with details; test for them with errors.Is.
+var (
+	// ErrTruncated reports data too short for the header.
+	ErrTruncated = errors.New("data too short for header")
+	// ErrInvalidParams reports a zero m or k, or a partitioned filter whose
+	// m is not a multiple of k.
+	ErrInvalidParams = errors.New("invalid parameters in serialized data")
+	// ErrUnknownScheme reports a hashing scheme or probing this version
+	// cannot decode.
+	ErrUnknownScheme = errors.New("unknown hashing scheme")
+	// ErrLengthMismatch reports a bitset whose length does not match m.
+	ErrLengthMismatch = errors.New("bitset data length mismatch")
+	// ErrLimitExceeded reports a filter beyond the limits of the
+	// UnmarshalOptions. The error is a *LimitError.
+	ErrLimitExceeded = errors.New("serialized filter exceeds limit")
+	// ErrTrailingBits reports bits set beyond m in the final word, which
+	// only strict decoding rejects.
+	ErrTrailingBits = errors.New("bits set beyond m")
+)
+
+// LimitError is returned when a serialized filter exceeds a limit of the
+// UnmarshalOptions. It matches ErrLimitExceeded with errors.Is.
+type LimitError struct {
+	// Field is "m" or "k".
+	Field string
+	Value uint64
+	Limit uint64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s = %d, limit %d", ErrLimitExceeded, e.Field, e.Value, e.Limit)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// UnmarshalOptions bounds and validates the decoding of filters in the
+// format produced by MarshalBinary, for data from untrusted sources. The
+// zero value decodes like UnmarshalBinary.
+//
+// All checks are made on the header and the input before anything is
+// allocated, so the memory used is bounded by MaxBits and the input size.
+type UnmarshalOptions struct {
+	// MaxBits is the largest m accepted. Zero means no limit other than
+	// the length of the data.
+	MaxBits uint64
+
+	// MaxK is the largest number of hash functions accepted. Zero means
+	// DefaultMaxK.
+	MaxK uint64
+
+	// Strict rejects non-canonical encodings: bits set beyond m in the
+	// final word, and trailing bytes after the bitset.
+	Strict bool
+}
+
+// decodeHeader validates the MarshalBinary encoding in data and returns its
+// header and the bytes of the bitset words.
+func (o UnmarshalOptions) decodeHeader(data []byte) (header, []byte, error) {
+	if len(data) < headerSize {
+		return header{}, nil, fmt.Errorf("%w: %d bytes", ErrTruncated, len(data))
+	}
+
+	var h header
+	h.m = binary.LittleEndian.Uint64(data[0:8])
+	h.k, h.scheme, h.probe = unpackK(binary.LittleEndian.Uint64(data[8:16]))
+	h.count = binary.LittleEndian.Uint64(data[16:24])
+
+	if h.m == 0 || h.k == 0 {
+		return header{}, nil, fmt.Errorf("%w: m = %d, k = %d", ErrInvalidParams, h.m, h.k)
+	}
+	if o.MaxBits != 0 && h.m > o.MaxBits {
+		return header{}, nil, &LimitError{Field: "m", Value: h.m, Limit: o.MaxBits}
+	}
+	maxK := o.MaxK
+	if maxK == 0 {
+		maxK = DefaultMaxK
+	}
+	if h.k > maxK {
+		return header{}, nil, &LimitError{Field: "k", Value: h.k, Limit: maxK}
+	}
+	if _, err := hasher.ForScheme(h.scheme); err != nil {
+		return header{}, nil, fmt.Errorf("%w %d", ErrUnknownScheme, uint8(h.scheme))
+	}
+	if err := h.checkProbe(); err != nil {
+		return header{}, nil, err
+	}
+
+	expectedWords := (h.m + 63) / 64
+	actualBytes := uint64(len(data) - headerSize)
+	if actualBytes/8 != expectedWords || (o.Strict && actualBytes%8 != 0) {
+		return header{}, nil, fmt.Errorf("%w: m = %d needs %d bytes, got %d",
+			ErrLengthMismatch, h.m, expectedWords*8, actualBytes)
+	}
+	bitset := data[headerSize : headerSize+expectedWords*8]
+
+	if o.Strict && h.m%64 != 0 {
+		last := binary.LittleEndian.Uint64(bitset[len(bitset)-8:])
+		if last>>(h.m%64) != 0 {
+			return header{}, nil, fmt.Errorf("%w: final word %#x for m = %d", ErrTrailingBits, last, h.m)
+		}
+	}
+	return h, bitset, nil
+}
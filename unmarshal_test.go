@@ -0,0 +1,104 @@
+package bitbloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestUnmarshalOptions_Errors(t *testing.T) {
+	valid, _ := NewWithParams(100, 3).MarshalBinary()
+
+	withHeader := func(m, k uint64, bitsetBytes int) []byte {
+		data := make([]byte, headerSize+bitsetBytes)
+		binary.LittleEndian.PutUint64(data[0:], m)
+		binary.LittleEndian.PutUint64(data[8:], k)
+		return data
+	}
+	trailing := append([]byte(nil), valid...)
+	trailing[len(trailing)-1] = 0x80 // bit 127, beyond m = 100
+
+	tests := []struct {
+		name string
+		opts UnmarshalOptions
+		data []byte
+		want error
+	}{
+		{"truncated", UnmarshalOptions{}, valid[:20], ErrTruncated},
+		{"zero k", UnmarshalOptions{}, withHeader(100, 0, 16), ErrInvalidParams},
+		{"huge m", UnmarshalOptions{}, withHeader(1<<63, 3, 0), ErrLengthMismatch},
+		{"m over limit", UnmarshalOptions{MaxBits: 64}, valid, ErrLimitExceeded},
+		{"k over default limit", UnmarshalOptions{}, withHeader(64, DefaultMaxK+1, 8), ErrLimitExceeded},
+		{"k over limit", UnmarshalOptions{MaxK: 2}, valid, ErrLimitExceeded},
+		{"unknown scheme", UnmarshalOptions{}, withHeader(64, 3|0xff<<56, 8), ErrUnknownScheme},
+		{"trailing bits", UnmarshalOptions{Strict: true}, trailing, ErrTrailingBits},
+		{"trailing bytes", UnmarshalOptions{Strict: true}, append(valid, 0), ErrLengthMismatch},
+	}
+	for _, tt := range tests {
+		if _, err := tt.opts.Unmarshal(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Unmarshal error = %v, want %v", tt.name, err, tt.want)
+		}
+		if _, err := tt.opts.View(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: View error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	var limitErr *LimitError
+	_, err := UnmarshalOptions{MaxBits: 64}.Unmarshal(valid)
+	if !errors.As(err, &limitErr) || limitErr.Field != "m" || limitErr.Value != 100 || limitErr.Limit != 64 {
+		t.Errorf("Expected a LimitError for m, got %v", err)
+	}
+
+	// The lenient default accepts what strict decoding rejects.
+	if _, err := UnmarshalBinary(trailing); err != nil {
+		t.Errorf("UnmarshalBinary rejected trailing bits: %v", err)
+	}
+	if _, err := (UnmarshalOptions{Strict: true, MaxBits: 100, MaxK: 3}).Unmarshal(valid); err != nil {
+		t.Errorf("Strict decoding rejected a canonical filter: %v", err)
+	}
+}
+
+func TestUnmarshalBinary_HugeMDoesNotAllocate(t *testing.T) {
+	data := make([]byte, headerSize)
+	binary.LittleEndian.PutUint64(data[0:], 1<<63)
+	binary.LittleEndian.PutUint64(data[8:], 3)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		UnmarshalBinary(data)
+	})
+	if allocs > 5 {
+		t.Errorf("Rejecting a huge m allocated %v times", allocs)
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	for _, bf := range []*BloomFilter{NewWithParams(100, 3), NewWithParams(128, 1), NewBitsAndBlooms(70, 4)} {
+		bf.Add([]byte("foo"))
+		data, _ := bf.MarshalBinary()
+		f.Add(data)
+	}
+
+	opts := UnmarshalOptions{MaxBits: 1 << 16, MaxK: 64, Strict: true}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bf, err := opts.Unmarshal(data)
+		view, verr := opts.View(data)
+		if (err == nil) != (verr == nil) {
+			t.Fatalf("Unmarshal and View disagree: %v vs %v", err, verr)
+		}
+		if err != nil {
+			return
+		}
+
+		// A strictly decoded filter is canonical and re-encodes to the
+		// same bytes.
+		out, _ := bf.MarshalBinary()
+		if string(out) != string(data) {
+			t.Fatalf("Round trip changed the encoding")
+		}
+		for _, item := range []string{"", "foo", "bar"} {
+			if bf.Test([]byte(item)) != view.Test([]byte(item)) {
+				t.Fatalf("Unmarshal and View answer differently for %q", item)
+			}
+		}
+	})
+}